@@ -0,0 +1,241 @@
+package kafun
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/transform"
+	"golang.org/x/xerrors"
+)
+
+// Archive はダウンロード済みの測定データのファイルを置いたディレクトリを DataSource として扱う。
+// APIのサービス終了後も、Client と同じ Search で過去のデータを検索できる。
+//
+// ディレクトリ以下の次の形式のファイルを読み込む。文字コードはUTF-8ないしはShift-JIS。
+//
+//   - .json: APIレスポンスないしは kafun コマンドが出力したJSON配列
+//   - .jsonl, .ndjson: 1行に1件の測定データのJSON
+//   - .csv: APIのJSONキー(SKT_CDなど)ないしは環境省が公開したCSVのヘッダ付きのCSV (ParseCSV を参照)
+type Archive struct {
+	Dir string // 測定データのファイルを置いたディレクトリ
+
+	// DecodeMode はJSONの項目に不正な値があった場合の扱い。ゼロ値は DecodeStrict。
+	DecodeMode DecodeMode
+
+	// OnRowError はCSVの読み込めない行ごとに呼ばれる。読み込めない行は読み飛ばして検索を続ける。
+	// nil の場合は何もせずに読み飛ばす。
+	OnRowError func(path string, err *RowError)
+}
+
+var _ DataSource = (*Archive)(nil)
+
+// NewArchive は dir を読む Archive を作成する。
+func NewArchive(dir string) (*Archive, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to open archive directory: %s: %v", dir, err)
+	}
+	if !info.IsDir() {
+		return nil, xerrors.Errorf("archive is not a directory: %s", dir)
+	}
+
+	return &Archive{Dir: dir}, nil
+}
+
+// Search は DataSource の実装。検索条件に合う測定データを測定日時、測定局コードの順で返す。
+func (a *Archive) Search(ctx context.Context, param *SearchParam) (SokuteiData, error) {
+	if err := validateSearchParam(param); err != nil {
+		return nil, err
+	}
+
+	// 年月の範囲を検証する
+	if _, err := splitSearchParam(param, false); err != nil {
+		return nil, err
+	}
+
+	var stations map[string]bool
+	if len(param.SokuteikyokuCode) != 0 {
+		stations = make(map[string]bool)
+		for _, code := range strings.Split(param.SokuteikyokuCode, ",") {
+			stations[strings.TrimSpace(code)] = true
+		}
+	}
+
+	response := SokuteiData{}
+	err := a.each(ctx, func(hsd *HourlySokuteiData) error {
+		if matchSearchParam(hsd, param, stations) {
+			response = append(response, hsd)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response.SortByTime()
+
+	return response, nil
+}
+
+// each はディレクトリ以下のすべての測定データをファイルごとに読み込んで fn に渡す。
+func (a *Archive) each(ctx context.Context, fn func(*HourlySokuteiData) error) error {
+	return filepath.Walk(a.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		data, rowErrors, err := readArchiveFile(path, a.DecodeMode)
+		if err != nil {
+			return err
+		}
+		if a.OnRowError != nil {
+			for _, rowErr := range rowErrors {
+				a.OnRowError(path, rowErr)
+			}
+		}
+
+		for _, hsd := range data {
+			if err := fn(hsd); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// matchSearchParam は測定データが検索条件に合うかどうかを返す。
+// stations は測定局コードの集合で、nil の場合は測定局で絞り込まない。
+func matchSearchParam(hsd *HourlySokuteiData, param *SearchParam, stations map[string]bool) bool {
+	if hsd.TodofukenCode != param.TodofukenCode {
+		return false
+	}
+
+	if stations != nil && !stations[hsd.SokuteikyokuCode] {
+		return false
+	}
+
+	if len(hsd.SokuteiNengappi) < 6 {
+		return false
+	}
+	ym := hsd.SokuteiNengappi[:6]
+
+	if ym < param.StartYM {
+		return false
+	}
+
+	return len(param.EndYM) == 0 || ym <= param.EndYM
+}
+
+// readArchiveFile は拡張子に応じてファイルを読み込む。対応していない拡張子のファイルは読み飛ばす。
+// CSVの読み込めない行は読み飛ばし、行単位のエラーとして返す。
+func readArchiveFile(path string, mode DecodeMode) (SokuteiData, []*RowError, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".json", ".jsonl", ".ndjson", ".csv":
+	default:
+		return nil, nil, nil
+	}
+
+	b, err := readUTF8File(path)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to read archive file: %s: %w", path, err)
+	}
+
+	var data SokuteiData
+	var rowErrors []*RowError
+	if ext == ".csv" {
+		data, rowErrors, err = ParseCSV(bytes.NewReader(b))
+	} else {
+		data, err = decodeArchiveJSON(b, mode)
+	}
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to decode archive file: %s: %w", path, err)
+	}
+
+	return data, rowErrors, nil
+}
+
+// readUTF8File はファイルを読み込み、UTF-8でない場合はShift-JISとみなしてUTF-8に変換する。
+func readUTF8File(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if utf8.Valid(b) {
+		return bytes.TrimPrefix(b, []byte("\xef\xbb\xbf")), nil
+	}
+
+	b, _, err = transform.Bytes(japanese.ShiftJIS.NewDecoder(), b)
+	return b, err
+}
+
+// decodeArchiveJSON はJSON配列ないしは1行に1件のJSONを mode に従ってデコードする。
+func decodeArchiveJSON(b []byte, mode DecodeMode) (SokuteiData, error) {
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+
+	if trimmed[0] == '[' {
+		var records []json.RawMessage
+		if err := json.Unmarshal(trimmed, &records); err != nil {
+			return nil, &DecodeError{Index: -1, Err: err}
+		}
+
+		data := make(SokuteiData, 0, len(records))
+		for index, record := range records {
+			hsd, err := decodeRecord(record, mode)
+			if err != nil {
+				return nil, wrapRecordDecodeError(index, err)
+			}
+			data = append(data, hsd)
+		}
+		return data, nil
+	}
+
+	var data SokuteiData
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for index := 0; scanner.Scan(); {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		hsd, err := decodeRecord(line, mode)
+		if err != nil {
+			return nil, wrapRecordDecodeError(index, err)
+		}
+		data = append(data, hsd)
+		index++
+	}
+
+	return data, scanner.Err()
+}
+
+// wrapRecordDecodeError はレコード単位のデコードエラーにレコードの位置を設定する。
+func wrapRecordDecodeError(index int, err error) error {
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		return &DecodeError{Index: index, Field: decodeErr.Field, Err: decodeErr.Err}
+	}
+
+	return &DecodeError{Index: index, Err: err}
+}
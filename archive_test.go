@@ -0,0 +1,260 @@
+package kafun
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeArchiveFixture はテスト用のアーカイブディレクトリを作成する。
+func writeArchiveFixture(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func archiveFixtureFiles(t *testing.T) map[string][]byte {
+	t.Helper()
+	csvSJIS, _ := encodeUTF8ToSJIS(t, []byte(strings.Join([]string{
+		"SKT_CD,AMeDAS_CD,SKT_NNGP,SKT_HH,SKT_NM,SKT_TYPE,TDFKN_CD,TDFKN_NM,SKCHSN_CD,SKCHSN_NM,KFN_NUM,AMeDAS_WD,AMeDAS_TP",
+		"00000002,00000,20210301,01,テスト観測所2,0,13,東京都,000000,テスト市,5,00,",
+		"00000001,00000,20210201,02,テスト観測所1,0,13,東京都,000000,テスト市,3,00,10.5",
+	}, "\n")))
+	return map[string][]byte{
+		// kafun コマンドの出力形式(数値はクォートされない)
+		"2021/output.json": []byte(`[
+			{"SKT_CD": "00000001", "AMeDAS_CD": "00000", "SKT_NNGP": "20210201", "SKT_HH": "01", "SKT_NM": "テスト観測所1",
+			 "SKT_TYPE": "0", "TDFKN_CD": "13", "TDFKN_NM": "東京都", "SKCHSN_CD": "000000", "SKCHSN_NM": "テスト市",
+			 "KFN_NUM": 2, "AMeDAS_WD": "00", "AMeDAS_WS": 1}
+		]`),
+		"2021/rows.jsonl": []byte(strings.Join([]string{
+			`{"SKT_CD": "00000001", "SKT_NNGP": "20210401", "SKT_HH": "01", "TDFKN_CD": "13", "KFN_NUM": "7"}`,
+			``,
+			`{"SKT_CD": "00000003", "SKT_NNGP": "20210201", "SKT_HH": "01", "TDFKN_CD": "14", "KFN_NUM": "1"}`,
+		}, "\n")),
+		"2021/rows.csv": csvSJIS,
+		"README.txt":    []byte("ignored"),
+	}
+}
+
+func TestArchive_Search(t *testing.T) {
+	dir := writeArchiveFixture(t, archiveFixtureFiles(t))
+	type want struct {
+		keys    []string
+		wantErr bool
+	}
+	tests := []struct {
+		name  string
+		param *SearchParam
+		want  want
+	}{
+		{
+			name:  "standard case: whole period sorted by time and station",
+			param: &SearchParam{StartYM: "202101", EndYM: "202112", TodofukenCode: "13"},
+			want: want{
+				keys: []string{
+					"20210201-01-00000001",
+					"20210201-02-00000001",
+					"20210301-01-00000002",
+					"20210401-01-00000001",
+				},
+			},
+		},
+		{
+			name:  "standard case: filter by month range",
+			param: &SearchParam{StartYM: "202103", EndYM: "202103", TodofukenCode: "13"},
+			want: want{
+				keys: []string{"20210301-01-00000002"},
+			},
+		},
+		{
+			name:  "standard case: open end and stations",
+			param: &SearchParam{StartYM: "202103", TodofukenCode: "13", SokuteikyokuCode: "00000001"},
+			want: want{
+				keys: []string{"20210401-01-00000001"},
+			},
+		},
+		{
+			name:  "standard case: other prefecture",
+			param: &SearchParam{StartYM: "202101", TodofukenCode: "14"},
+			want: want{
+				keys: []string{"20210201-01-00000003"},
+			},
+		},
+		{
+			name:  "error case: invalid parameter",
+			param: &SearchParam{StartYM: "202103", EndYM: "202102", TodofukenCode: "13"},
+			want: want{
+				wantErr: true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewArchive(dir)
+			if err != nil {
+				t.Fatalf("NewArchive() error = %v", err)
+			}
+
+			got, err := a.Search(ctx, tt.param)
+			if (err != nil) != tt.want.wantErr {
+				t.Fatalf("Search() error = %v, wantErr %v", err, tt.want.wantErr)
+			}
+
+			var keys []string
+			for _, hsd := range got {
+				keys = append(keys, hsd.SokuteiNengappi+"-"+hsd.SokuteiJikoku+"-"+hsd.SokuteikyokuCode)
+			}
+			if !reflect.DeepEqual(keys, tt.want.keys) {
+				t.Errorf("Search() got = %v, want %v", keys, tt.want.keys)
+			}
+		})
+	}
+}
+
+func TestArchive_Search_decodeValues(t *testing.T) {
+	a, _ := NewArchive(writeArchiveFixture(t, archiveFixtureFiles(t)))
+	got, err := a.Search(ctx, &SearchParam{StartYM: "202102", EndYM: "202102", TodofukenCode: "13"})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+
+	want := SokuteiData{
+		{
+			SokuteikyokuCode:     "00000001",
+			AMeDASCode:           "00000",
+			SokuteiNengappi:      "20210201",
+			SokuteiJikoku:        "01",
+			SokuteikyokuName:     "テスト観測所1",
+			SokuteiType:          "0",
+			TodofukenCode:        "13",
+			TodofukenName:        "東京都",
+			SokuteiShichosonCode: "000000",
+			SokuteiShichosonName: "テスト市",
+			KafunNum:             2,
+			AMeDASWindDirect:     "00",
+			AMeDASWindSpeed:      intPointerHelper(t, 1),
+		},
+		{
+			SokuteikyokuCode:     "00000001",
+			AMeDASCode:           "00000",
+			SokuteiNengappi:      "20210201",
+			SokuteiJikoku:        "02",
+			SokuteikyokuName:     "テスト観測所1",
+			SokuteiType:          "0",
+			TodofukenCode:        "13",
+			TodofukenName:        "東京都",
+			SokuteiShichosonCode: "000000",
+			SokuteiShichosonName: "テスト市",
+			KafunNum:             3,
+			AMeDASWindDirect:     "00",
+			AMeDASTemperature:    float64PointerHelper(t, 10.5),
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Search() got = %v, want %v", got, want)
+	}
+}
+
+func TestArchive_Search_decodeError(t *testing.T) {
+	dir := writeArchiveFixture(t, map[string][]byte{
+		"broken.jsonl": []byte(`{"SKT_CD": "00000001", "SKT_NNGP": "20210201", "SKT_HH": "1", "TDFKN_CD": "13", "KFN_NUM": "1"}` + "\n" +
+			`{"SKT_CD": "00000001", "SKT_NNGP": "20210201", "SKT_HH": "2", "TDFKN_CD": "13", "KFN_NUM": "invalid"}`),
+	})
+	a, _ := NewArchive(dir)
+
+	_, err := a.Search(ctx, &SearchParam{StartYM: "202102", TodofukenCode: "13"})
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("Search() error = %v, want *DecodeError", err)
+	}
+	if decodeErr.Index != 1 || decodeErr.Field != "KFN_NUM" {
+		t.Errorf("Search() error = %#v, want index 1 field KFN_NUM", decodeErr)
+	}
+}
+
+func TestArchive_Search_csvRowError(t *testing.T) {
+	dir := writeArchiveFixture(t, map[string][]byte{
+		"rows.csv": []byte(strings.Join([]string{
+			"SKT_CD,SKT_NNGP,SKT_HH,TDFKN_CD,KFN_NUM",
+			"00000001,20210201,01,13,1",
+			"00000001,20210201,02,13,invalid",
+			"00000001,20210201,03,13,3",
+		}, "\n")),
+	})
+	a, _ := NewArchive(dir)
+	var rowErrors []*RowError
+	a.OnRowError = func(path string, err *RowError) {
+		if filepath.Base(path) != "rows.csv" {
+			t.Errorf("OnRowError() path = %v, want rows.csv", path)
+		}
+		rowErrors = append(rowErrors, err)
+	}
+
+	got, err := a.Search(ctx, &SearchParam{StartYM: "202102", TodofukenCode: "13"})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	var hours []string
+	for _, hsd := range got {
+		hours = append(hours, hsd.SokuteiJikoku)
+	}
+	if !reflect.DeepEqual(hours, []string{"01", "03"}) {
+		t.Errorf("Search() hours = %v, want [01 03]", hours)
+	}
+	if len(rowErrors) != 1 || rowErrors[0].Line != 3 || rowErrors[0].Field != "KFN_NUM" {
+		t.Errorf("OnRowError() errors = %v, want line 3 field KFN_NUM", rowErrors)
+	}
+}
+
+func TestNewArchive(t *testing.T) {
+	dir := writeArchiveFixture(t, map[string][]byte{"file.json": []byte(`[]`)})
+	tests := []struct {
+		name    string
+		dir     string
+		wantErr bool
+	}{
+		{name: "standard case: directory", dir: dir},
+		{name: "error case: not exist", dir: filepath.Join(dir, "none"), wantErr: true},
+		{name: "error case: not directory", dir: filepath.Join(dir, "file.json"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewArchive(tt.dir); (err != nil) != tt.wantErr {
+				t.Errorf("NewArchive() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCLI_Run_archive(t *testing.T) {
+	t.Parallel()
+	dir := writeArchiveFixture(t, archiveFixtureFiles(t))
+	stdOut := new(bytes.Buffer)
+	c := &CLI{
+		OutStream: stdOut,
+		ErrStream: new(bytes.Buffer),
+		Endpoint:  "http://127.0.0.1:0",
+	}
+
+	args := []string{"kafun", "-archive", dir, "-startYM", "202103", "-todofukenCode", "13"}
+	if got := c.Run(args); got != ExitCodeOK {
+		t.Fatalf("Run() return code = %v, want %v", got, ExitCodeOK)
+	}
+	if !strings.Contains(stdOut.String(), `"SKT_NM": "テスト観測所2"`) {
+		t.Errorf("Run() stdout = %v, want archived data", stdOut.String())
+	}
+}
@@ -0,0 +1,211 @@
+package kafun
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// キャッシュファイルの拡張子。
+const cacheFileExt = ".gob"
+
+// DefaultCacheDir はデフォルトのキャッシュディレクトリを返す。
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	return filepath.Join(dir, "kafun")
+}
+
+// CacheEntry はキャッシュファイルの情報を表す。
+type CacheEntry struct {
+	Key       string    // キャッシュのキー(リクエストURL)
+	Path      string    // キャッシュファイルのパス
+	Size      int64     // キャッシュファイルのバイト数
+	Records   int       // キャッシュしている測定データの件数
+	CreatedAt time.Time // キャッシュした日時。読み込めないキャッシュファイルの場合はファイルの更新日時
+	Expired   bool      // TTL を過ぎているかどうか
+
+	// Err は読み込めないキャッシュファイルの場合の原因。Key と Records は空になり、Get では使われない。
+	Err error
+}
+
+// キャッシュファイルの先頭に書き込むヘッダ。ヘッダの後に SokuteiData が続く。
+type cacheHeader struct {
+	Key       string
+	Records   int
+	CreatedAt time.Time
+}
+
+// DiskCache はディレクトリにファイルとして検索結果を保存する Cache。
+// ファイル名はキーのSHA-256ハッシュで、ひとつのキーがひとつのファイルに対応する。
+type DiskCache struct {
+	Dir string        // キャッシュディレクトリ
+	TTL time.Duration // キャッシュの有効期間。0の場合は期限切れにならない
+}
+
+// NewDiskCache は dir をキャッシュディレクトリとする DiskCache を作成する。ディレクトリがない場合は作成する。
+func NewDiskCache(dir string, ttl time.Duration) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, xerrors.Errorf("failed to create cache directory: %s: %v", dir, err)
+	}
+
+	return &DiskCache{Dir: dir, TTL: ttl}, nil
+}
+
+// Get は Cache の実装。期限切れのキャッシュや読み込めないキャッシュはないものとして扱う。
+func (d *DiskCache) Get(key string) (SokuteiData, bool) {
+	f, err := os.Open(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	decoder := gob.NewDecoder(f)
+
+	var header cacheHeader
+	if err := decoder.Decode(&header); err != nil || header.Key != key || d.expired(header.CreatedAt) {
+		return nil, false
+	}
+
+	data := SokuteiData{}
+	if err := decoder.Decode(&data); err != nil {
+		return nil, false
+	}
+
+	return data, true
+}
+
+// Set は Cache の実装。書き込み途中のファイルが読まれないように一時ファイルに書いてから置き換える。
+func (d *DiskCache) Set(key string, data SokuteiData) error {
+	f, err := ioutil.TempFile(d.Dir, "tmp-*")
+	if err != nil {
+		return xerrors.Errorf("failed to create cache file: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	encoder := gob.NewEncoder(f)
+	header := cacheHeader{Key: key, Records: len(data), CreatedAt: time.Now()}
+	if err := encoder.Encode(header); err != nil {
+		f.Close()
+		return xerrors.Errorf("failed to write cache header: %v", err)
+	}
+	if err := encoder.Encode(data); err != nil {
+		f.Close()
+		return xerrors.Errorf("failed to write cache data: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return xerrors.Errorf("failed to close cache file: %v", err)
+	}
+
+	return os.Rename(f.Name(), d.path(key))
+}
+
+// Entries はキャッシュファイルの一覧を作成日時の順で返す。
+// 壊れたファイルなど読み込めないキャッシュファイルは、Err を設定したエントリとして返す。
+func (d *DiskCache) Entries() ([]*CacheEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(d.Dir, "*"+cacheFileExt))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*CacheEntry, 0, len(paths))
+	for _, path := range paths {
+		entries = append(entries, d.readEntry(path))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+
+	return entries, nil
+}
+
+// Purge はキャッシュファイルを削除し、削除した件数を返す。
+// expiredOnly が true の場合は期限切れのキャッシュと読み込めないキャッシュファイルだけを削除する。
+func (d *DiskCache) Purge(expiredOnly bool) (int, error) {
+	entries, err := d.Entries()
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, entry := range entries {
+		if expiredOnly && !entry.Expired && entry.Err == nil {
+			continue
+		}
+		if err := os.Remove(entry.Path); err != nil {
+			return purged, xerrors.Errorf("failed to remove cache file: %s: %v", entry.Path, err)
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// readEntry はキャッシュファイルのヘッダを読み込む。読み込めない場合は Err を設定したエントリを返す。
+func (d *DiskCache) readEntry(path string) *CacheEntry {
+	entry := &CacheEntry{Path: path}
+	if info, err := os.Stat(path); err == nil {
+		entry.Size = info.Size()
+		entry.CreatedAt = info.ModTime()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		entry.Err = xerrors.Errorf("failed to open cache file: %s: %v", path, err)
+		return entry
+	}
+	defer f.Close()
+
+	var header cacheHeader
+	if err := gob.NewDecoder(f).Decode(&header); err != nil {
+		entry.Err = xerrors.Errorf("failed to read cache file: %s: %v", path, err)
+		return entry
+	}
+
+	entry.Key = header.Key
+	entry.Records = header.Records
+	entry.CreatedAt = header.CreatedAt
+	entry.Expired = d.expired(header.CreatedAt)
+
+	return entry
+}
+
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.Dir, hex.EncodeToString(sum[:])+cacheFileExt)
+}
+
+func (d *DiskCache) expired(createdAt time.Time) bool {
+	return d.TTL > 0 && time.Since(createdAt) > d.TTL
+}
+
+// isCompletedPeriod は分割済みのパラメータの期間が先月以前で、今後データが変わらないかどうかを返す。
+// 終了年月が指定されていない場合は期間が終わっていないものとして扱う。
+func isCompletedPeriod(param *SearchParam, now time.Time) bool {
+	ym := strings.TrimSpace(param.EndYM)
+	if len(ym) == 0 {
+		return false
+	}
+
+	end, err := time.ParseInLocation(yearMonthLayout, ym, jst)
+	if err != nil {
+		return false
+	}
+
+	now = now.In(jst)
+	currentMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, jst)
+
+	return end.Before(currentMonth)
+}
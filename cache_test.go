@@ -0,0 +1,181 @@
+package kafun
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDiskCache_GetSet(t *testing.T) {
+	data := SokuteiData{
+		&HourlySokuteiData{
+			SokuteikyokuCode:  "00000000",
+			SokuteiNengappi:   "20210201",
+			SokuteiJikoku:     "01",
+			KafunNum:          4,
+			AMeDASWindSpeed:   intPointerHelper(t, 1),
+			AMeDASTemperature: float64PointerHelper(t, 15.4),
+		},
+	}
+	type fields struct {
+		ttl time.Duration
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		key     string
+		getKey  string
+		want    SokuteiData
+		wantHit bool
+	}{
+		{
+			name:    "standard case: hit",
+			key:     "http://example.com/data_search?Start_YM=202102",
+			getKey:  "http://example.com/data_search?Start_YM=202102",
+			want:    data,
+			wantHit: true,
+		},
+		{
+			name:   "standard case: miss other key",
+			key:    "http://example.com/data_search?Start_YM=202102",
+			getKey: "http://example.com/data_search?Start_YM=202103",
+		},
+		{
+			name:   "standard case: expired",
+			fields: fields{ttl: time.Nanosecond},
+			key:    "http://example.com/data_search?Start_YM=202102",
+			getKey: "http://example.com/data_search?Start_YM=202102",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDiskCache(t.TempDir(), tt.fields.ttl)
+			if err != nil {
+				t.Fatalf("NewDiskCache() error = %v", err)
+			}
+			if err := d.Set(tt.key, data); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			time.Sleep(time.Millisecond)
+
+			got, ok := d.Get(tt.getKey)
+			if ok != tt.wantHit {
+				t.Fatalf("Get() ok = %v, want %v", ok, tt.wantHit)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Get() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiskCache_EntriesPurge(t *testing.T) {
+	d, _ := NewDiskCache(t.TempDir(), time.Hour)
+	d.Set("key1", SokuteiData{&HourlySokuteiData{}})
+	d.Set("key2", SokuteiData{&HourlySokuteiData{}, &HourlySokuteiData{}})
+
+	entries, err := d.Entries()
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Key != "key1" || entries[1].Records != 2 || entries[1].Expired {
+		t.Errorf("Entries() got = %+v, %+v", entries[0], entries[1])
+	}
+
+	if purged, err := d.Purge(true); err != nil || purged != 0 {
+		t.Errorf("Purge(expiredOnly) got = %v, %v, want 0, nil", purged, err)
+	}
+	if purged, err := d.Purge(false); err != nil || purged != 2 {
+		t.Errorf("Purge() got = %v, %v, want 2, nil", purged, err)
+	}
+	if entries, _ := d.Entries(); len(entries) != 0 {
+		t.Errorf("Entries() after purge got = %v, want empty", entries)
+	}
+}
+
+func TestDiskCache_EntriesPurge_invalid(t *testing.T) {
+	dir := t.TempDir()
+	d, _ := NewDiskCache(dir, time.Hour)
+	d.Set("key1", SokuteiData{&HourlySokuteiData{}})
+	broken := filepath.Join(dir, "broken"+cacheFileExt)
+	if err := os.WriteFile(broken, []byte("not gob"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := d.Entries()
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	var invalid []string
+	for _, entry := range entries {
+		if entry.Err != nil {
+			invalid = append(invalid, entry.Path)
+		}
+	}
+	if len(entries) != 2 || !reflect.DeepEqual(invalid, []string{broken}) {
+		t.Errorf("Entries() got = %v, want one invalid entry %s", entries, broken)
+	}
+
+	if purged, err := d.Purge(true); err != nil || purged != 1 {
+		t.Errorf("Purge(expiredOnly) got = %v, %v, want 1, nil", purged, err)
+	}
+	if _, err := os.Stat(broken); !os.IsNotExist(err) {
+		t.Errorf("Purge(expiredOnly) did not remove %s", broken)
+	}
+	if _, ok := d.Get("key1"); !ok {
+		t.Errorf("Get() after purge got = false, want true")
+	}
+}
+
+func Test_isCompletedPeriod(t *testing.T) {
+	// 2021-03-01 00:30 JST は UTC ではまだ2月
+	now := time.Date(2021, 2, 28, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		param *SearchParam
+		want  bool
+	}{
+		{name: "standard case: past month", param: &SearchParam{StartYM: "202102", EndYM: "202102"}, want: true},
+		{name: "standard case: current month in JST", param: &SearchParam{StartYM: "202103", EndYM: "202103"}, want: false},
+		{name: "standard case: future month", param: &SearchParam{StartYM: "202104", EndYM: "202104"}, want: false},
+		{name: "standard case: open period", param: &SearchParam{StartYM: "202101"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCompletedPeriod(tt.param, now); got != tt.want {
+				t.Errorf("isCompletedPeriod() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_Search_diskCache(t *testing.T) {
+	var requests int32
+	handler := monthlyHandler(t, "")
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		handler(w, r)
+	}))
+	defer testServer.Close()
+
+	cache, _ := NewDiskCache(t.TempDir(), 0)
+	c, _ := NewClient(testServer.URL, WithCache(cache))
+
+	// 過去の月はキャッシュされ、当月は毎回取得される
+	currentYM := time.Now().In(jst).Format(yearMonthLayout)
+	for _, ym := range []string{"202102", "202102", currentYM, currentYM} {
+		param := &SearchParam{StartYM: ym, EndYM: ym, TodofukenCode: "13"}
+		if _, err := c.Search(ctx, param); err != nil {
+			t.Fatalf("Search() error = %v", err)
+		}
+	}
+
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Errorf("Search() requests = %v, want 3", got)
+	}
+}
@@ -2,10 +2,16 @@ package kafun
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
 )
 
 // 終了コードの状態。
@@ -14,96 +20,549 @@ const (
 	ExitCodeParseFlagError         // コマンドラインフラグのパースエラー終了
 	ExitCodeInitializeError        // 初期化のエラー終了
 	ExitCodeAPIRequestError        // APIリクエストエラー終了
+	ExitCodeValidationError        // 検索パラメータの検証エラー終了
+	ExitCodeDecodeError            // APIレスポンスのデコードエラー終了
+	ExitCodeCacheError             // キャッシュの操作エラー終了
+	ExitCodeImportError            // CSVの取り込みエラー終了
+	ExitCodeOutputError            // 出力のエラー終了
+	ExitCodeStationError           // 測定局の一覧の作成エラー終了
+	ExitCodeGapError               // 欠測の検査で完全性が下限未満の測定局がある終了
 )
 
-// コマンドラインフラグ
-var (
+// cliFlags は1回のコマンド実行のコマンドラインフラグの値を表す。
+type cliFlags struct {
 	startYM          string // 取得開始の年月を指定するフラグ
 	endYM            string // 取得終了の年月を指定するフラグ
 	todofukenCode    string // 都道府県コードを指定するフラグ
 	sokuteikyokuCode string // 測定局コードを指定するフラグ
-)
+	catalogPath      string // 測定局名の解決に使う測定局の一覧を指定するフラグ
+
+	useCache bool          // 検索結果をディスクにキャッシュするかを指定するフラグ
+	cacheDir string        // キャッシュディレクトリを指定するフラグ
+	cacheTTL time.Duration // キャッシュの有効期間を指定するフラグ
+
+	archiveDir string // APIの代わりに読むダウンロード済みデータのディレクトリを指定するフラグ
+
+	decode     string     // 不正な値の項目の扱いを指定するフラグ
+	decodeMode DecodeMode // decode を解釈した値
+
+	fill string // 欠測の時間の補い方を指定するフラグ
+	qc   bool   // 花粉数の品質管理をするかを指定するフラグ
+
+	output outputOptions // 出力形式を指定するフラグ
+}
 
 // CLI はコマンドを作成するさいの入出力を表す。
+// Run は呼び出しごとに独立した状態を持つので、同じ CLI を並行して実行できる。
 type CLI struct {
 	OutStream io.Writer // 出力のストリーム
 	ErrStream io.Writer // エラー出力のストリーム
+
+	// Endpoint はAPIのベースURL。空文字列の場合は DefaultEndpoint を使う。
+	Endpoint string
+
+	// NewClient はAPIクライアントを作成する関数。nil の場合はパッケージの NewClient を使う。
+	NewClient func(endpoint string) (*Client, error)
 }
 
 // Run はコマンドを実行する関数
 func (c *CLI) Run(args []string) int {
+	// サブコマンドの実行
+	if len(args) > 1 {
+		switch args[1] {
+		case "cache":
+			return c.runCache(args[1:])
+		case "import":
+			return c.runImport(args[1:])
+		case "stations":
+			return c.runStations(args[1:])
+		case "season":
+			return c.runSeason(args[1:])
+		case "gaps":
+			return c.runGaps(args[1:])
+		case "qc":
+			return c.runQC(args[1:])
+		case "stats":
+			return c.runStats(args[1:])
+		}
+	}
+
+	var f cliFlags
+
 	// コマンドライン引数をパース
 	flags := flag.NewFlagSet("kafun", flag.ContinueOnError)
 	flags.SetOutput(c.ErrStream)
+	f.setSearchFlags(flags)
+	flags.StringVar(
+		&f.output.format,
+		"format",
+		FormatJSON,
+		"出力形式 (json, ndjson, csv, tsv, table)",
+	)
+	flags.BoolVar(
+		&f.output.noHeader,
+		"noHeader",
+		false,
+		"csv, tsv, table でヘッダ行を出力しない",
+	)
+	flags.StringVar(
+		&f.output.fields,
+		"fields",
+		"",
+		"出力する項目。JSONキーないしはフィールド名をカンマ区切りで指定 (例: SKT_NNGP,SKT_HH,KFN_NUM)",
+	)
+	flags.StringVar(
+		&f.output.fieldNames,
+		"fieldNames",
+		FieldNamesAPI,
+		"項目名の形式 (api: SKT_CD など, go: SokuteikyokuCode など)",
+	)
+	flags.BoolVar(
+		&f.output.timestamp,
+		"timestamp",
+		false,
+		"測定日時 (RFC 3339) の timestamp 項目を追加する",
+	)
+	flags.BoolVar(
+		&f.output.level,
+		"level",
+		false,
+		"花粉数の段階 (少ない, やや多い, 多い, 非常に多い, 極めて多い) の level 項目を追加する",
+	)
+	flags.StringVar(
+		&f.output.levelThresholds,
+		"levelThresholds",
+		"",
+		"やや多い, 多い, 非常に多い, 極めて多い の下限の花粉数をカンマ区切りで指定 (default: 10,31,51,101)",
+	)
+	flags.IntVar(
+		&f.output.width,
+		"width",
+		0,
+		"table で切り詰める表示幅。0の場合は切り詰めない (default: 端末の表示幅ないしは環境変数 COLUMNS)",
+	)
 	flags.StringVar(
-		&startYM,
+		&f.fill,
+		"fill",
+		"",
+		"欠測の時間の測定データを補う。項目ごとの補い方をカンマ区切りで指定 (例: kafun=zero,temperature=linear,precipitation=zero)。null の場合は値を補わない",
+	)
+	flags.BoolVar(
+		&f.qc,
+		"qc",
+		false,
+		"花粉数の急増、一定値、近隣との乖離を検査し、疑わしい理由の qc 項目を追加する",
+	)
+	flags.BoolVar(
+		&f.output.sjis,
+		"sjis",
+		false,
+		"Shift-JISで出力する",
+	)
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return ExitCodeParseFlagError
+	}
+
+	// コマンドライン引数が指定されていない場合は用例を表示
+	if len(args) == 1 {
+		flags.Usage()
+		return ExitCodeOK
+	}
+
+	if !isFlagSet(flags, "width") {
+		f.output.width = terminalWidth(c.OutStream)
+	}
+
+	var policy FillPolicy
+	if len(f.fill) != 0 {
+		p, err := ParseFillPolicy(f.fill)
+		if err != nil {
+			fmt.Fprintf(c.ErrStream, "invalid fill: %v\n", err)
+			return ExitCodeParseFlagError
+		}
+		policy = p
+		f.output.filled = true
+	}
+	f.output.qc = f.qc
+
+	writer, err := newRecordWriter(c.OutStream, &f.output)
+	if err != nil {
+		fmt.Fprintf(c.ErrStream, "failed to initialize output: %v\n", err)
+		return ExitCodeParseFlagError
+	}
+
+	// 測定データの検索
+	source, param, code := c.prepareSearch(&f)
+	if code != ExitCodeOK {
+		return code
+	}
+
+	if len(f.fill) != 0 || f.qc {
+		// 欠測の時間を補うには、また品質管理で前後の時間や他の測定局と比べるにはすべての測定データが必要になる
+		if code := c.writeProcessed(&f, source, param, policy, writer); code != ExitCodeOK {
+			return code
+		}
+	} else if code, err := c.search(source, param, writer, isStreamingFormat(f.output.format)); err != nil {
+		if code == ExitCodeOutputError {
+			fmt.Fprintf(c.ErrStream, "failed to write output: %v\n", err)
+			return code
+		}
+		c.printSearchError(&f, err)
+		return code
+	}
+
+	if err := writer.Close(); err != nil {
+		fmt.Fprintf(c.ErrStream, "failed to write output: %v\n", err)
+		return ExitCodeOutputError
+	}
+
+	return ExitCodeOK
+}
+
+// setSearchFlags は測定データの検索に使うフラグを flags に登録する。
+func (f *cliFlags) setSearchFlags(flags *flag.FlagSet) {
+	flags.StringVar(
+		&f.startYM,
 		"startYM",
 		"",
 		"開始年月 (format: yyyyMM) (必須)",
 	)
 	flags.StringVar(
-		&endYM,
+		&f.endYM,
 		"endYM",
 		"",
 		"終了年月 (format: yyyyMM)",
 	)
 	flags.StringVar(
-		&todofukenCode,
+		&f.todofukenCode,
 		"todofukenCode",
 		"",
-		"都道府県コード (range: 01 to 47) (必須)",
+		"都道府県。コード (01 to 47)、名前 (東京都)、ローマ字 (tokyo) のいずれかで指定 (必須)",
 	)
 	flags.StringVar(
-		&sokuteikyokuCode,
+		&f.sokuteikyokuCode,
 		"sokuteikyokuCode",
 		"",
-		"測定局コード。複数指定の場合はカンマ区切りで指定",
+		"測定局コードないしは測定局名。複数指定の場合はカンマ区切りで指定",
 	)
+	flags.StringVar(
+		&f.catalogPath,
+		"catalog",
+		"",
+		"測定局名の解決に使う測定局の一覧のJSONファイル。指定しない場合は組み込みの一覧を使う",
+	)
+	flags.BoolVar(
+		&f.useCache,
+		"cache",
+		false,
+		"先月以前の検索結果をディスクにキャッシュする",
+	)
+	flags.StringVar(
+		&f.cacheDir,
+		"cacheDir",
+		DefaultCacheDir(),
+		"キャッシュディレクトリ",
+	)
+	flags.DurationVar(
+		&f.cacheTTL,
+		"cacheTTL",
+		0,
+		"キャッシュの有効期間 (例: 720h)。0の場合は期限切れにならない",
+	)
+	flags.StringVar(
+		&f.archiveDir,
+		"archive",
+		"",
+		"APIの代わりに検索するダウンロード済みデータのディレクトリ",
+	)
+	flags.StringVar(
+		&f.decode,
+		"decode",
+		DecodeStrict.String(),
+		"不正な値の項目の扱い (strict: エラーにする, lenient: 警告を出力して続ける)",
+	)
+}
 
-	if err := flags.Parse(args[1:]); err != nil {
-		return ExitCodeParseFlagError
+// prepareSearch は検索のフラグを解釈して、検索に使う DataSource と SearchParam を作成する。
+// 都道府県名と測定局名はコードにする。失敗した場合はエラー出力に表示して終了コードを返す。
+func (c *CLI) prepareSearch(f *cliFlags) (DataSource, *SearchParam, int) {
+	decodeMode, err := ParseDecodeMode(f.decode)
+	if err != nil {
+		fmt.Fprintf(c.ErrStream, "invalid decode: %v\n", err)
+		return nil, nil, ExitCodeParseFlagError
 	}
+	f.decodeMode = decodeMode
 
-	// コマンドライン引数が指定されていない場合は用例を表示
-	if len(args) == 1 {
-		flags.Usage()
+	// 都道府県は名前やローマ字でも指定できる
+	if len(f.todofukenCode) != 0 {
+		prefecture, ok := LookupPrefecture(f.todofukenCode)
+		if !ok {
+			fmt.Fprintf(c.ErrStream, "invalid todofukenCode: unknown prefecture: %s\n", f.todofukenCode)
+			return nil, nil, ExitCodeValidationError
+		}
+		f.todofukenCode = prefecture.Code
+	}
+
+	// 測定局は測定局名でも指定できる
+	if code := c.resolveStationFlag(f); code != ExitCodeOK {
+		return nil, nil, code
+	}
+
+	source, code := c.newDataSource(f)
+	if code != ExitCodeOK {
+		return nil, nil, code
+	}
+
+	param := &SearchParam{
+		StartYM:          f.startYM,
+		EndYM:            f.endYM,
+		TodofukenCode:    f.todofukenCode,
+		SokuteikyokuCode: f.sokuteikyokuCode,
+	}
+
+	return source, param, ExitCodeOK
+}
+
+// fetch は測定データを検索してすべて返す。失敗した場合はエラー出力に表示して終了コードを返す。
+func (c *CLI) fetch(f *cliFlags, source DataSource, param *SearchParam) (SokuteiData, int) {
+	response, err := source.Search(context.Background(), param)
+	if err != nil {
+		c.printSearchError(f, err)
+		return nil, exitCodeFromError(err)
+	}
+
+	for _, hsd := range response {
+		c.warnProblems(hsd)
+	}
+
+	return response, ExitCodeOK
+}
+
+// writeProcessed は測定データを検索し、-fill の場合は欠測の時間を policy に従って補い、
+// -qc の場合は品質管理をして writer に出力する。失敗した場合はエラー出力に表示して終了コードを返す。
+func (c *CLI) writeProcessed(f *cliFlags, source DataSource, param *SearchParam, policy FillPolicy, writer recordWriter) int {
+	data, code := c.fetch(f, source, param)
+	if code != ExitCodeOK {
+		return code
+	}
+
+	if len(f.fill) != 0 {
+		filled, err := Fill(data, param, policy)
+		if err != nil {
+			c.printSearchError(f, err)
+			return exitCodeFromError(err)
+		}
+		data = filled
+	}
+	if f.qc {
+		if _, err := CheckQuality(data, DefaultQCRule); err != nil {
+			fmt.Fprintf(c.ErrStream, "%v\n", err)
+			return ExitCodeParseFlagError
+		}
+	}
+
+	for _, hsd := range data {
+		if err := writer.Write(hsd); err != nil {
+			fmt.Fprintf(c.ErrStream, "failed to write output: %v\n", err)
+			return ExitCodeOutputError
+		}
+	}
+
+	return ExitCodeOK
+}
+
+// printSearchError は検索のエラーを検索条件とともにエラー出力に表示する。
+func (c *CLI) printSearchError(f *cliFlags, err error) {
+	fmt.Fprintf(
+		c.ErrStream,
+		"failed to request to API with args startYM=%s, endYM=%s, todofukenCode=%s, sokuteikyokuCode=%s: %v\n",
+		f.startYM,
+		f.endYM,
+		f.todofukenCode,
+		f.sokuteikyokuCode,
+		err,
+	)
+}
+
+// resolveStationFlag は -sokuteikyokuCode の測定局名を測定局コードにする。
+// -todofukenCode が指定されている場合はその都道府県の測定局から探す。
+func (c *CLI) resolveStationFlag(f *cliFlags) int {
+	if isStationCodeList(f.sokuteikyokuCode) {
 		return ExitCodeOK
 	}
 
-	// data_search API の実行
-	client, err := NewClient(DefaultEndpoint)
+	catalog, err := loadStationCatalog(f.catalogPath)
 	if err != nil {
-		fmt.Fprintf(c.ErrStream, "failed to initialize API client with url=%s: %v\n", DefaultEndpoint, err)
+		fmt.Fprintf(c.ErrStream, "failed to load station catalog: %v\n", err)
 		return ExitCodeInitializeError
 	}
+	if len(f.todofukenCode) != 0 {
+		catalog = NewStationCatalog(catalog.ByPrefecture(f.todofukenCode))
+	}
 
-	param := &SearchParam{
-		StartYM:          startYM,
-		EndYM:            endYM,
-		TodofukenCode:    todofukenCode,
-		SokuteikyokuCode: sokuteikyokuCode,
+	codes, err := resolveStationCodes(catalog, f.sokuteikyokuCode)
+	if err != nil {
+		fmt.Fprintf(c.ErrStream, "invalid sokuteikyokuCode: %v\n", err)
+		return ExitCodeValidationError
+	}
+	f.sokuteikyokuCode = codes
+
+	return ExitCodeOK
+}
+
+// isFlagSet はコマンドラインでフラグが指定されたかどうかを返す。
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+
+	return set
+}
+
+// terminalWidth は出力先 w が端末の場合はその表示幅を返し、端末でない場合や取得できない場合は
+// 環境変数 COLUMNS の値を返す。どちらも取得できない場合は0を返す。
+func terminalWidth(w io.Writer) int {
+	if f, ok := w.(interface{ Fd() uintptr }); ok {
+		if width, _, err := term.GetSize(int(f.Fd())); err == nil && width > 0 {
+			return width
+		}
+	}
+
+	columns, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil || columns < 0 {
+		return 0
+	}
+
+	return columns
+}
+
+// iterDataSource は測定データを逐次取得できる DataSource を表す。
+type iterDataSource interface {
+	SearchIter(ctx context.Context, param *SearchParam, fn func(*HourlySokuteiData) error) error
+}
+
+// search は測定データを検索して writer に出力し、エラーの場合は終了コードとエラーを返す。
+// streaming が true で逐次取得できる DataSource の場合は、取得したそばから出力する。
+func (c *CLI) search(source DataSource, param *SearchParam, writer recordWriter, streaming bool) (int, error) {
+	ctx := context.Background()
+
+	if iter, ok := source.(iterDataSource); ok && streaming {
+		var writeErr error
+		err := iter.SearchIter(ctx, param, func(hsd *HourlySokuteiData) error {
+			c.warnProblems(hsd)
+			writeErr = writer.Write(hsd)
+			return writeErr
+		})
+		if writeErr != nil {
+			return ExitCodeOutputError, writeErr
+		}
+		if err != nil {
+			return exitCodeFromError(err), err
+		}
+		return ExitCodeOK, nil
 	}
 
-	response, err := client.Search(context.Background(), param)
+	response, err := source.Search(ctx, param)
 	if err != nil {
-		fmt.Fprintf(
-			c.ErrStream,
-			"failed to request to API with args startYM=%s, endYM=%s, todofukenCode=%s, sokuteikyokuCode=%s: %v\n",
-			startYM,
-			endYM,
-			todofukenCode,
-			sokuteikyokuCode,
-			err,
-		)
-		return ExitCodeAPIRequestError
+		return exitCodeFromError(err), err
+	}
+
+	for _, hsd := range response {
+		c.warnProblems(hsd)
+		if err := writer.Write(hsd); err != nil {
+			return ExitCodeOutputError, err
+		}
 	}
 
-	printJSON, err := json.MarshalIndent(response, "", "\t")
+	return ExitCodeOK, nil
+}
+
+// warnProblems は -decode lenient で記録した項目の問題をエラー出力に警告として出力する。
+func (c *CLI) warnProblems(hsd *HourlySokuteiData) {
+	if len(hsd.Problems) == 0 {
+		return
+	}
+
+	problems := make([]string, len(hsd.Problems))
+	for i, p := range hsd.Problems {
+		problems[i] = p.String()
+	}
+
+	fmt.Fprintf(
+		c.ErrStream,
+		"warning: record SKT_CD=%s SKT_NNGP=%s SKT_HH=%s: %s\n",
+		hsd.SokuteikyokuCode,
+		hsd.SokuteiNengappi,
+		hsd.SokuteiJikoku,
+		strings.Join(problems, "; "),
+	)
+}
+
+// warnRowError は -archive のCSVで読み飛ばした行をエラー出力に警告として出力する。
+func (c *CLI) warnRowError(path string, err *RowError) {
+	fmt.Fprintf(c.ErrStream, "warning: %s:%v (skipped)\n", path, err)
+}
+
+// newDataSource はフラグに応じて検索に使う DataSource を作成する。
+// -archive が指定されている場合はダウンロード済みのデータを、それ以外は data_search API を使う。
+func (c *CLI) newDataSource(f *cliFlags) (DataSource, int) {
+	if len(f.archiveDir) != 0 {
+		archive, err := NewArchive(f.archiveDir)
+		if err != nil {
+			fmt.Fprintf(c.ErrStream, "failed to initialize archive: %v\n", err)
+			return nil, ExitCodeInitializeError
+		}
+		archive.DecodeMode = f.decodeMode
+		archive.OnRowError = c.warnRowError
+		return archive, ExitCodeOK
+	}
+
+	endpoint := c.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = DefaultEndpoint
+	}
+
+	newClient := c.NewClient
+	if newClient == nil {
+		newClient = func(endpoint string) (*Client, error) {
+			return NewClient(endpoint)
+		}
+	}
+
+	client, err := newClient(endpoint)
 	if err != nil {
-		fmt.Fprintf(c.ErrStream, "failed to initialize API client: %v\n", err)
+		fmt.Fprintf(c.ErrStream, "failed to initialize API client with url=%s: %v\n", endpoint, err)
+		return nil, ExitCodeInitializeError
 	}
 
-	fmt.Fprintf(c.OutStream, "%s", printJSON)
+	if f.useCache {
+		cache, err := NewDiskCache(f.cacheDir, f.cacheTTL)
+		if err != nil {
+			fmt.Fprintf(c.ErrStream, "failed to initialize cache: %v\n", err)
+			return nil, ExitCodeInitializeError
+		}
+		client.Cache = cache
+	}
+	client.DecodeMode = f.decodeMode
 
-	return ExitCodeOK
+	return client, ExitCodeOK
+}
+
+// exitCodeFromError は検索のエラーの種類に対応する終了コードを返す。
+func exitCodeFromError(err error) int {
+	var validationErr *ValidationError
+	var decodeErr *DecodeError
+
+	switch {
+	case errors.As(err, &validationErr):
+		return ExitCodeValidationError
+	case errors.As(err, &decodeErr):
+		return ExitCodeDecodeError
+	default:
+		return ExitCodeAPIRequestError
+	}
 }
@@ -0,0 +1,105 @@
+package kafun
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+)
+
+// runCache は cache サブコマンドを実行する。
+//
+//	kafun cache list [-cacheDir DIR] [-cacheTTL TTL]
+//	kafun cache purge [-cacheDir DIR] [-cacheTTL TTL] [-expired]
+func (c *CLI) runCache(args []string) int {
+	if len(args) < 2 || (args[1] != "list" && args[1] != "purge") {
+		fmt.Fprintf(c.ErrStream, "Usage: kafun cache list|purge [options]\n")
+		return ExitCodeParseFlagError
+	}
+	action := args[1]
+
+	var (
+		cacheDir    string
+		cacheTTL    time.Duration
+		expiredOnly bool
+	)
+
+	flags := flag.NewFlagSet("kafun cache "+action, flag.ContinueOnError)
+	flags.SetOutput(c.ErrStream)
+	flags.StringVar(
+		&cacheDir,
+		"cacheDir",
+		DefaultCacheDir(),
+		"キャッシュディレクトリ",
+	)
+	flags.DurationVar(
+		&cacheTTL,
+		"cacheTTL",
+		0,
+		"キャッシュの有効期間 (例: 720h)。0の場合は期限切れにならない",
+	)
+	if action == "purge" {
+		flags.BoolVar(
+			&expiredOnly,
+			"expired",
+			false,
+			"期限切れのキャッシュだけを削除する",
+		)
+	}
+
+	if err := flags.Parse(args[2:]); err != nil {
+		return ExitCodeParseFlagError
+	}
+
+	cache, err := NewDiskCache(cacheDir, cacheTTL)
+	if err != nil {
+		fmt.Fprintf(c.ErrStream, "failed to initialize cache: %v\n", err)
+		return ExitCodeInitializeError
+	}
+
+	switch action {
+	case "purge":
+		purged, err := cache.Purge(expiredOnly)
+		if err != nil {
+			fmt.Fprintf(c.ErrStream, "failed to purge cache: %v\n", err)
+			return ExitCodeCacheError
+		}
+		fmt.Fprintf(c.OutStream, "%d cache entries purged\n", purged)
+
+	default:
+		entries, err := cache.Entries()
+		if err != nil {
+			fmt.Fprintf(c.ErrStream, "failed to list cache: %v\n", err)
+			return ExitCodeCacheError
+		}
+
+		w := tabwriter.NewWriter(c.OutStream, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CREATED_AT\tRECORDS\tSIZE\tEXPIRED\tKEY")
+		for _, entry := range entries {
+			if entry.Err != nil {
+				fmt.Fprintf(c.ErrStream, "warning: %v (removed by purge)\n", entry.Err)
+				fmt.Fprintf(
+					w,
+					"%s\t-\t%d\t-\t(invalid) %s\n",
+					entry.CreatedAt.Format(time.RFC3339),
+					entry.Size,
+					filepath.Base(entry.Path),
+				)
+				continue
+			}
+			fmt.Fprintf(
+				w,
+				"%s\t%d\t%d\t%t\t%s\n",
+				entry.CreatedAt.Format(time.RFC3339),
+				entry.Records,
+				entry.Size,
+				entry.Expired,
+				entry.Key,
+			)
+		}
+		w.Flush()
+	}
+
+	return ExitCodeOK
+}
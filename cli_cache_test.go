@@ -0,0 +1,107 @@
+package kafun
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCLI_runCache(t *testing.T) {
+	t.Parallel()
+	type want struct {
+		returnCode int
+		stdout     []string
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		entries int
+		broken  bool // 読み込めないキャッシュファイルを置く
+		want    want
+	}{
+		{
+			name:    "standard case: list",
+			args:    []string{"kafun", "cache", "list"},
+			entries: 1,
+			want: want{
+				returnCode: ExitCodeOK,
+				stdout:     []string{"CREATED_AT", "key0"},
+			},
+		},
+		{
+			name:    "standard case: purge",
+			args:    []string{"kafun", "cache", "purge"},
+			entries: 2,
+			want: want{
+				returnCode: ExitCodeOK,
+				stdout:     []string{"2 cache entries purged"},
+			},
+		},
+		{
+			name:    "standard case: purge expired only",
+			args:    []string{"kafun", "cache", "purge", "-expired", "-cacheTTL", "1h"},
+			entries: 2,
+			want: want{
+				returnCode: ExitCodeOK,
+				stdout:     []string{"0 cache entries purged"},
+			},
+		},
+		{
+			name:    "standard case: list with broken file",
+			args:    []string{"kafun", "cache", "list"},
+			entries: 1,
+			broken:  true,
+			want: want{
+				returnCode: ExitCodeOK,
+				stdout:     []string{"key0", "(invalid) broken.gob"},
+			},
+		},
+		{
+			name:    "standard case: purge expired only removes broken file",
+			args:    []string{"kafun", "cache", "purge", "-expired", "-cacheTTL", "1h"},
+			entries: 1,
+			broken:  true,
+			want: want{
+				returnCode: ExitCodeOK,
+				stdout:     []string{"1 cache entries purged"},
+			},
+		},
+		{
+			name: "error case: unknown action",
+			args: []string{"kafun", "cache", "unknown"},
+			want: want{
+				returnCode: ExitCodeParseFlagError,
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			cache, _ := NewDiskCache(dir, time.Hour)
+			for i := 0; i < tt.entries; i++ {
+				cache.Set("key"+string(rune('0'+i)), SokuteiData{})
+			}
+			if tt.broken {
+				if err := os.WriteFile(filepath.Join(dir, "broken.gob"), []byte("not gob"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			stdOut := new(bytes.Buffer)
+			c := &CLI{OutStream: stdOut, ErrStream: new(bytes.Buffer)}
+			if got := c.Run(append(tt.args, "-cacheDir", dir)); got != tt.want.returnCode {
+				t.Fatalf("Run() return code = %v, want %v", got, tt.want.returnCode)
+			}
+			for _, want := range tt.want.stdout {
+				if !strings.Contains(stdOut.String(), want) {
+					t.Errorf("Run() stdout = %v, want contains %v", stdOut.String(), want)
+				}
+			}
+		})
+	}
+}
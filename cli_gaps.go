@@ -0,0 +1,273 @@
+package kafun
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// gaps サブコマンドの出力の種類。
+const (
+	gapReportSummary = "summary" // 測定局ごとの集計
+	gapReportRuns    = "runs"    // 欠測が続いた期間
+	gapReportSlots   = "slots"   // 欠測の測定年月日と測定時刻
+)
+
+// runGaps は gaps サブコマンドを実行する。
+//
+//	kafun gaps -startYM YYYYMM [-endYM YYYYMM] -todofukenCode PREF [検索のオプション]
+//	    [-report summary|runs|slots] [-minCompleteness PERCENT]
+//	    [-format table|json|csv] [-noHeader] [-width N]
+func (c *CLI) runGaps(args []string) int {
+	var f cliFlags
+	var report string
+	var minCompleteness float64
+
+	flags := flag.NewFlagSet("kafun gaps", flag.ContinueOnError)
+	flags.SetOutput(c.ErrStream)
+	f.setSearchFlags(flags)
+	flags.StringVar(
+		&report,
+		"report",
+		gapReportSummary,
+		"出力の種類 (summary: 測定局ごとの集計, runs: 欠測が続いた期間, slots: 欠測の測定日時)",
+	)
+	flags.Float64Var(
+		&minCompleteness,
+		"minCompleteness",
+		0,
+		"完全性 (%) がこれ未満の測定局がある場合に終了コード 10 で終了する。100 の場合は欠測が1時間でもあれば失敗",
+	)
+	flags.StringVar(
+		&f.output.format,
+		"format",
+		FormatTable,
+		"出力形式 (table, json, csv)",
+	)
+	flags.BoolVar(
+		&f.output.noHeader,
+		"noHeader",
+		false,
+		"table, csv でヘッダ行を出力しない",
+	)
+	flags.IntVar(
+		&f.output.width,
+		"width",
+		0,
+		"table で切り詰める表示幅。0の場合は切り詰めない (default: 端末の表示幅ないしは環境変数 COLUMNS)",
+	)
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return ExitCodeParseFlagError
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprintf(c.ErrStream, "Usage: kafun gaps -startYM YYYYMM -todofukenCode PREF [options]\n")
+		return ExitCodeParseFlagError
+	}
+
+	switch report {
+	case gapReportSummary, gapReportRuns, gapReportSlots:
+	default:
+		fmt.Fprintf(c.ErrStream, "invalid report: %s\n", report)
+		return ExitCodeParseFlagError
+	}
+	if minCompleteness < 0 || minCompleteness > 100 {
+		fmt.Fprintf(c.ErrStream, "invalid minCompleteness: must be between 0 and 100: %g\n", minCompleteness)
+		return ExitCodeParseFlagError
+	}
+	if _, err := summaryOutputThresholds(&f.output); err != nil {
+		fmt.Fprintf(c.ErrStream, "failed to initialize output: %v\n", err)
+		return ExitCodeParseFlagError
+	}
+	if !isFlagSet(flags, "width") {
+		f.output.width = terminalWidth(c.OutStream)
+	}
+
+	source, param, code := c.prepareSearch(&f)
+	if code != ExitCodeOK {
+		return code
+	}
+	data, code := c.fetch(&f, source, param)
+	if code != ExitCodeOK {
+		return code
+	}
+
+	gaps, err := DetectGaps(data, param)
+	if err != nil {
+		c.printSearchError(&f, err)
+		return exitCodeFromError(err)
+	}
+
+	if err := writeGaps(c.OutStream, gaps, report, &f.output); err != nil {
+		fmt.Fprintf(c.ErrStream, "failed to write output: %v\n", err)
+		return ExitCodeOutputError
+	}
+
+	code = ExitCodeOK
+	for _, g := range gaps {
+		if completeness := g.Completeness() * 100; completeness < minCompleteness {
+			fmt.Fprintf(
+				c.ErrStream,
+				"station %s: completeness %.1f%% is below %g%%\n",
+				g.SokuteikyokuCode,
+				completeness,
+				minCompleteness,
+			)
+			code = ExitCodeGapError
+		}
+	}
+
+	return code
+}
+
+// gapSummaryRecord は欠測の測定局ごとの集計の出力の1行。CSVの見出しはJSONキーを使う。
+type gapSummaryRecord struct {
+	SokuteikyokuCode string  `json:"SKT_CD"`
+	SokuteikyokuName string  `json:"SKT_NM"`
+	Expected         int     `json:"expected"`
+	Present          int     `json:"present"`
+	Missing          int     `json:"missing"`
+	Completeness     float64 `json:"completeness"`
+	Runs             int     `json:"runs"`
+	LongestRun       int     `json:"longest_run"`
+}
+
+// gapRunRecord は欠測が続いた期間の出力の1行。
+type gapRunRecord struct {
+	SokuteikyokuCode string `json:"SKT_CD"`
+	SokuteikyokuName string `json:"SKT_NM"`
+	StartNengappi    string `json:"start_SKT_NNGP"`
+	StartJikoku      string `json:"start_SKT_HH"`
+	EndNengappi      string `json:"end_SKT_NNGP"`
+	EndJikoku        string `json:"end_SKT_HH"`
+	Hours            int    `json:"hours"`
+}
+
+// gapSlotRecord は欠測の測定年月日と測定時刻の出力の1行。
+type gapSlotRecord struct {
+	SokuteikyokuCode string `json:"SKT_CD"`
+	SokuteikyokuName string `json:"SKT_NM"`
+	SokuteiNengappi  string `json:"SKT_NNGP"`
+	SokuteiJikoku    string `json:"SKT_HH"`
+}
+
+// writeGaps は欠測を出力の種類と出力形式に応じて出力する。
+func writeGaps(w io.Writer, gaps []*StationGaps, kind string, opts *outputOptions) error {
+	report := &summaryReport{}
+
+	switch kind {
+	case gapReportRuns:
+		report.columns = []*tableColumn{
+			{Title: "コード"},
+			{Title: "測定局", truncatable: true},
+			{Title: "開始日"},
+			{Title: "開始時刻", alignRight: true},
+			{Title: "終了日"},
+			{Title: "終了時刻", alignRight: true},
+			{Title: "時間数", alignRight: true},
+		}
+		report.header = []string{"SKT_CD", "SKT_NM", "start_SKT_NNGP", "start_SKT_HH", "end_SKT_NNGP", "end_SKT_HH", "hours"}
+		runs := []*gapRunRecord{}
+		for _, g := range gaps {
+			for _, run := range g.Runs {
+				r := &gapRunRecord{
+					SokuteikyokuCode: g.SokuteikyokuCode,
+					SokuteikyokuName: g.SokuteikyokuName,
+					Hours:            run.Hours(),
+				}
+				r.StartNengappi, r.StartJikoku = sokuteiSlot(run.Start)
+				r.EndNengappi, r.EndJikoku = sokuteiSlot(run.End)
+				runs = append(runs, r)
+				row := []string{
+					r.SokuteikyokuCode,
+					r.SokuteikyokuName,
+					r.StartNengappi,
+					r.StartJikoku,
+					r.EndNengappi,
+					r.EndJikoku,
+					strconv.Itoa(r.Hours),
+				}
+				report.rows = append(report.rows, row)
+				report.tableRows = append(report.tableRows, withDashName(row))
+			}
+		}
+		report.records = runs
+
+	case gapReportSlots:
+		report.columns = []*tableColumn{
+			{Title: "コード"},
+			{Title: "測定局", truncatable: true},
+			{Title: "測定年月日"},
+			{Title: "測定時刻", alignRight: true},
+		}
+		report.header = []string{"SKT_CD", "SKT_NM", "SKT_NNGP", "SKT_HH"}
+		slots := []*gapSlotRecord{}
+		for _, g := range gaps {
+			for _, t := range g.Missing {
+				r := &gapSlotRecord{SokuteikyokuCode: g.SokuteikyokuCode, SokuteikyokuName: g.SokuteikyokuName}
+				r.SokuteiNengappi, r.SokuteiJikoku = sokuteiSlot(t)
+				slots = append(slots, r)
+				row := []string{r.SokuteikyokuCode, r.SokuteikyokuName, r.SokuteiNengappi, r.SokuteiJikoku}
+				report.rows = append(report.rows, row)
+				report.tableRows = append(report.tableRows, withDashName(row))
+			}
+		}
+		report.records = slots
+
+	default:
+		report.columns = []*tableColumn{
+			{Title: "コード"},
+			{Title: "測定局", truncatable: true},
+			{Title: "期間の時間数", alignRight: true},
+			{Title: "測定時間数", alignRight: true},
+			{Title: "欠測時間数", alignRight: true},
+			{Title: "完全性", alignRight: true},
+			{Title: "欠測期間数", alignRight: true},
+			{Title: "最長欠測時間数", alignRight: true},
+		}
+		report.header = []string{"SKT_CD", "SKT_NM", "expected", "present", "missing", "completeness", "runs", "longest_run"}
+		summaries := make([]*gapSummaryRecord, len(gaps))
+		for i, g := range gaps {
+			r := &gapSummaryRecord{
+				SokuteikyokuCode: g.SokuteikyokuCode,
+				SokuteikyokuName: g.SokuteikyokuName,
+				Expected:         g.Expected,
+				Present:          g.Present(),
+				Missing:          len(g.Missing),
+				Completeness:     math.Round(g.Completeness()*1000) / 1000,
+				Runs:             len(g.Runs),
+			}
+			if longest, ok := g.LongestRun(); ok {
+				r.LongestRun = longest.Hours()
+			}
+			summaries[i] = r
+			row := []string{
+				r.SokuteikyokuCode,
+				r.SokuteikyokuName,
+				strconv.Itoa(r.Expected),
+				strconv.Itoa(r.Present),
+				strconv.Itoa(r.Missing),
+				strconv.FormatFloat(r.Completeness, 'f', -1, 64),
+				strconv.Itoa(r.Runs),
+				strconv.Itoa(r.LongestRun),
+			}
+			report.rows = append(report.rows, row)
+			// table では完全性をパーセントにする
+			tableRow := withDashName(row)
+			tableRow[5] = strconv.FormatFloat(g.Completeness()*100, 'f', 1, 64) + "%"
+			report.tableRows = append(report.tableRows, tableRow)
+		}
+		report.records = summaries
+	}
+
+	return report.write(w, opts)
+}
+
+// withDashName は測定局名を orDash にした行のコピーを返す。
+func withDashName(row []string) []string {
+	r := append([]string{}, row...)
+	r[1] = orDash(r[1])
+	return r
+}
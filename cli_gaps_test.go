@@ -0,0 +1,120 @@
+package kafun
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCLI_runGaps(t *testing.T) {
+	t.Parallel()
+	from := time.Date(2021, 2, 1, 0, 0, 0, 0, jst)
+	counts := make([]int, 28)
+	counts[9] = -1 // 2月10日は測定データがない
+	data := append(dailyFixture(t, "1", from, counts...), dailyFixture(t, "2", from, make([]int, 28)...)...)
+	dir := writeArchiveFixture(t, map[string][]byte{"2021.jsonl": archiveJSONL(t, data)})
+	search := []string{"-startYM", "202102", "-endYM", "202102", "-todofukenCode", "13", "-archive", dir}
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantErrout string
+	}{
+		{
+			name:     "standard case: summary table",
+			args:     []string{"-width", "0"},
+			wantCode: ExitCodeOK,
+			wantStdout: "コード  測定局         期間の時間数  測定時間数  欠測時間数  完全性  欠測期間数  最長欠測時間数\n" +
+				"1       テスト観測所1           672         648          24   96.4%           1              24\n" +
+				"2       テスト観測所2           672         672           0  100.0%           0               0\n",
+		},
+		{
+			name:     "standard case: summary csv",
+			args:     []string{"-format", "csv", "-sokuteikyokuCode", "1"},
+			wantCode: ExitCodeOK,
+			wantStdout: "SKT_CD,SKT_NM,expected,present,missing,completeness,runs,longest_run\n" +
+				"1,テスト観測所1,672,648,24,0.964,1,24\n",
+		},
+		{
+			name:     "standard case: runs json",
+			args:     []string{"-report", "runs", "-format", "json"},
+			wantCode: ExitCodeOK,
+			wantStdout: `[
+	{
+		"SKT_CD": "1",
+		"SKT_NM": "テスト観測所1",
+		"start_SKT_NNGP": "20210210",
+		"start_SKT_HH": "01",
+		"end_SKT_NNGP": "20210210",
+		"end_SKT_HH": "24",
+		"hours": 24
+	}
+]
+`,
+		},
+		{
+			name:     "standard case: slots csv without header",
+			args:     []string{"-report", "slots", "-format", "csv", "-noHeader", "-sokuteikyokuCode", "1"},
+			wantCode: ExitCodeOK,
+			wantStdout: func() string {
+				var b strings.Builder
+				for hour := 1; hour <= 24; hour++ {
+					fmt.Fprintf(&b, "1,テスト観測所1,20210210,%02d\n", hour)
+				}
+				return b.String()
+			}(),
+		},
+		{
+			name:     "error case: completeness below minimum",
+			args:     []string{"-format", "csv", "-noHeader", "-minCompleteness", "100"},
+			wantCode: ExitCodeGapError,
+			wantStdout: "1,テスト観測所1,672,648,24,0.964,1,24\n" +
+				"2,テスト観測所2,672,672,0,1,0,0\n",
+			wantErrout: "station 1: completeness 96.4% is below 100%\n",
+		},
+		{
+			name:       "standard case: completeness above minimum",
+			args:       []string{"-format", "csv", "-noHeader", "-minCompleteness", "95", "-sokuteikyokuCode", "1"},
+			wantCode:   ExitCodeOK,
+			wantStdout: "1,テスト観測所1,672,648,24,0.964,1,24\n",
+		},
+		{
+			name:       "error case: unknown report",
+			args:       []string{"-report", "hours"},
+			wantCode:   ExitCodeParseFlagError,
+			wantErrout: "invalid report: hours\n",
+		},
+		{
+			name:       "error case: invalid minCompleteness",
+			args:       []string{"-minCompleteness", "120"},
+			wantCode:   ExitCodeParseFlagError,
+			wantErrout: "invalid minCompleteness: must be between 0 and 100: 120\n",
+		},
+		{
+			name:       "error case: unknown format",
+			args:       []string{"-format", "ndjson"},
+			wantCode:   ExitCodeParseFlagError,
+			wantErrout: "failed to initialize output: unknown output format: ndjson\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outStream, errOut := new(bytes.Buffer), new(bytes.Buffer)
+			c := &CLI{OutStream: outStream, ErrStream: errOut}
+			args := append(append([]string{"kafun", "gaps"}, search...), tt.args...)
+			if got := c.Run(args); got != tt.wantCode {
+				t.Errorf("Run() return code = %v, want %v: %s", got, tt.wantCode, errOut.String())
+			}
+			if outStream.String() != tt.wantStdout {
+				t.Errorf("Run() stdout = %q, want %q", outStream.String(), tt.wantStdout)
+			}
+			if errOut.String() != tt.wantErrout {
+				t.Errorf("Run() errout = %q, want %q", errOut.String(), tt.wantErrout)
+			}
+		})
+	}
+}
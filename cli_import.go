@@ -0,0 +1,117 @@
+package kafun
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runImport は import サブコマンドを実行する。
+// 環境省が公開したCSVを読み込み、-archive で検索できるJSONL形式で出力する。
+//
+//	kafun import [-o DIR] FILE...
+func (c *CLI) runImport(args []string) int {
+	var outDir string
+
+	flags := flag.NewFlagSet("kafun import", flag.ContinueOnError)
+	flags.SetOutput(c.ErrStream)
+	flags.StringVar(
+		&outDir,
+		"o",
+		"",
+		"JSONLファイルの出力先ディレクトリ。指定しない場合は標準出力に出力",
+	)
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return ExitCodeParseFlagError
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintf(c.ErrStream, "Usage: kafun import [-o DIR] FILE...\n")
+		flags.PrintDefaults()
+		return ExitCodeParseFlagError
+	}
+
+	if len(outDir) != 0 {
+		if err := os.MkdirAll(outDir, 0o755); err != nil {
+			fmt.Fprintf(c.ErrStream, "failed to create output directory: %s: %v\n", outDir, err)
+			return ExitCodeImportError
+		}
+	}
+
+	exitCode := ExitCodeOK
+	for _, path := range flags.Args() {
+		imported, rowErrors, err := c.importFile(path, outDir)
+		if err != nil {
+			fmt.Fprintf(c.ErrStream, "failed to import %s: %v\n", path, err)
+			exitCode = ExitCodeImportError
+			continue
+		}
+
+		for _, rowErr := range rowErrors {
+			fmt.Fprintf(c.ErrStream, "%s:%v\n", path, rowErr)
+		}
+		fmt.Fprintf(c.ErrStream, "%s: %d rows imported, %d rows skipped\n", path, imported, len(rowErrors))
+	}
+
+	return exitCode
+}
+
+// importFile はCSVファイルを読み込んでJSONLで出力し、出力した件数と行単位のエラーを返す。
+func (c *CLI) importFile(path string, outDir string) (int, []*RowError, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer in.Close()
+
+	data, rowErrors, err := ParseCSV(in)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(outDir) == 0 {
+		return len(data), rowErrors, writeJSONL(c.OutStream, data)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + ".jsonl"
+	out, err := os.Create(filepath.Join(outDir, name))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if err := writeJSONL(out, data); err != nil {
+		out.Close()
+		return 0, nil, err
+	}
+
+	return len(data), rowErrors, out.Close()
+}
+
+// archiveRecord は取り込んだ測定データのJSONL形式の1行。欠測した花粉数は null で出力する。
+type archiveRecord struct {
+	*HourlySokuteiData
+	KafunNum *int `json:"KFN_NUM"`
+}
+
+// writeJSONL は測定データを1行に1件のJSONで出力する。
+func writeJSONL(w io.Writer, data SokuteiData) error {
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	for _, hsd := range data {
+		record := &archiveRecord{HourlySokuteiData: hsd}
+		if !hsd.isMissing("KFN_NUM") {
+			record.KafunNum = &hsd.KafunNum
+		}
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,95 @@
+package kafun
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI_runImport(t *testing.T) {
+	t.Parallel()
+	dir := writeArchiveFixture(t, map[string][]byte{
+		"2021.csv": []byte("測定局コード,都道府県コード,年月日,時,花粉数\n" +
+			"00000001,13,2021/2/1,1,3\n" +
+			"00000001,13,2021/2/1,x,3\n"),
+	})
+	outDir := filepath.Join(dir, "archive")
+
+	errOut := new(bytes.Buffer)
+	c := &CLI{OutStream: new(bytes.Buffer), ErrStream: errOut}
+	args := []string{"kafun", "import", "-o", outDir, filepath.Join(dir, "2021.csv"), filepath.Join(dir, "none.csv")}
+	if got := c.Run(args); got != ExitCodeImportError {
+		t.Errorf("Run() return code = %v, want %v", got, ExitCodeImportError)
+	}
+
+	for _, want := range []string{"2021.csv:line 3: field SKT_HH", "1 rows imported, 1 rows skipped", "failed to import"} {
+		if !strings.Contains(errOut.String(), want) {
+			t.Errorf("Run() errout = %v, want contains %v", errOut.String(), want)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(outDir, "2021.jsonl")); err != nil {
+		t.Fatalf("Run() output file: %v", err)
+	}
+
+	// 取り込んだデータは -archive で検索できる
+	stdOut := new(bytes.Buffer)
+	c = &CLI{OutStream: stdOut, ErrStream: new(bytes.Buffer)}
+	if got := c.Run([]string{"kafun", "-archive", outDir, "-startYM", "202102", "-todofukenCode", "13"}); got != ExitCodeOK {
+		t.Fatalf("Run() archive return code = %v, want %v", got, ExitCodeOK)
+	}
+	if !strings.Contains(stdOut.String(), `"SKT_NNGP": "20210201"`) {
+		t.Errorf("Run() archive stdout = %v, want imported data", stdOut.String())
+	}
+}
+
+func TestCLI_runImport_roundTrip(t *testing.T) {
+	t.Parallel()
+	dir := writeArchiveFixture(t, map[string][]byte{
+		"2021.csv": []byte("測定局コード,都道府県,年月日,時,花粉数\n" +
+			"00000001,東京都,2021/2/1,1,3\n" +
+			"00000001,東京都,2021/2/1,2,-\n" +
+			"00000002,神奈川県,2021/2/1,1,5\n"),
+	})
+	outDir := filepath.Join(dir, "archive")
+
+	errOut := new(bytes.Buffer)
+	c := &CLI{OutStream: new(bytes.Buffer), ErrStream: errOut}
+	if got := c.Run([]string{"kafun", "import", "-o", outDir, filepath.Join(dir, "2021.csv")}); got != ExitCodeOK {
+		t.Fatalf("Run() return code = %v, want %v: %s", got, ExitCodeOK, errOut.String())
+	}
+	if want := "3 rows imported, 0 rows skipped"; !strings.Contains(errOut.String(), want) {
+		t.Errorf("Run() errout = %v, want contains %v", errOut.String(), want)
+	}
+
+	// 都道府県コードの列がなくても都道府県で検索でき、欠測した花粉数は値なしのまま読み込める
+	stdOut := new(bytes.Buffer)
+	errOut.Reset()
+	c = &CLI{OutStream: stdOut, ErrStream: errOut}
+	args := []string{
+		"kafun", "-archive", outDir, "-startYM", "202102", "-todofukenCode", "東京都",
+		"-format", "csv", "-fields", "SKT_CD,TDFKN_CD,SKT_HH,KFN_NUM",
+	}
+	if got := c.Run(args); got != ExitCodeOK {
+		t.Fatalf("Run() archive return code = %v, want %v: %s", got, ExitCodeOK, errOut.String())
+	}
+	want := "SKT_CD,TDFKN_CD,SKT_HH,KFN_NUM\n" +
+		"00000001,13,01,3\n" +
+		"00000001,13,02,\n"
+	if stdOut.String() != want {
+		t.Errorf("Run() archive stdout = %q, want %q", stdOut.String(), want)
+	}
+
+	// JSONでは欠測した花粉数を0と区別して null で出力する
+	stdOut.Reset()
+	args = []string{"kafun", "-archive", outDir, "-startYM", "202102", "-todofukenCode", "東京都", "-format", "ndjson"}
+	if got := c.Run(args); got != ExitCodeOK {
+		t.Fatalf("Run() archive return code = %v, want %v: %s", got, ExitCodeOK, errOut.String())
+	}
+	lines := strings.Split(strings.TrimSpace(stdOut.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"KFN_NUM":3`) || !strings.Contains(lines[1], `"KFN_NUM":null`) {
+		t.Errorf("Run() archive stdout = %v, want KFN_NUM 3 and null", stdOut.String())
+	}
+}
@@ -0,0 +1,255 @@
+package kafun
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// qc サブコマンドの出力の種類。
+const (
+	qcReportSummary = "summary" // 測定局ごとの集計
+	qcReportRows    = "rows"    // 疑わしい測定データ
+)
+
+// runQC は qc サブコマンドを実行する。
+//
+//	kafun qc -startYM YYYYMM [-endYM YYYYMM] -todofukenCode PREF [検索のオプション]
+//	    [-report summary|rows] [-window N] [-spikeFactor F] [-spikeMin N] [-flatlineHours N]
+//	    [-neighborFactor F] [-neighborMin N] [-neighborStations N]
+//	    [-format table|json|csv] [-noHeader] [-width N]
+func (c *CLI) runQC(args []string) int {
+	var f cliFlags
+	var report string
+	rule := DefaultQCRule
+
+	flags := flag.NewFlagSet("kafun qc", flag.ContinueOnError)
+	flags.SetOutput(c.ErrStream)
+	f.setSearchFlags(flags)
+	flags.StringVar(
+		&report,
+		"report",
+		qcReportSummary,
+		"出力の種類 (summary: 測定局ごとの集計, rows: 疑わしい測定データ)",
+	)
+	flags.IntVar(
+		&rule.Window,
+		"window",
+		rule.Window,
+		"急増の判定の移動統計に使う前後の時間数",
+	)
+	flags.Float64Var(
+		&rule.SpikeFactor,
+		"spikeFactor",
+		rule.SpikeFactor,
+		"急増とみなす、前後の時間の中央値からの差の中央絶対偏差に対する倍率",
+	)
+	flags.IntVar(
+		&rule.SpikeMin,
+		"spikeMin",
+		rule.SpikeMin,
+		"急増とみなす花粉数の下限",
+	)
+	flags.IntVar(
+		&rule.FlatlineHours,
+		"flatlineHours",
+		rule.FlatlineHours,
+		"一定値とみなす、0より大きい同じ花粉数が続く時間数",
+	)
+	flags.Float64Var(
+		&rule.NeighborFactor,
+		"neighborFactor",
+		rule.NeighborFactor,
+		"近隣と乖離しているとみなす、同じ都道府県の他の測定局の中央値との比",
+	)
+	flags.IntVar(
+		&rule.NeighborMin,
+		"neighborMin",
+		rule.NeighborMin,
+		"近隣と乖離しているとみなす花粉数の下限",
+	)
+	flags.IntVar(
+		&rule.NeighborStations,
+		"neighborStations",
+		rule.NeighborStations,
+		"近隣と比べるのに必要な、同じ時間に測定データのある他の測定局の数",
+	)
+	flags.StringVar(
+		&f.output.format,
+		"format",
+		FormatTable,
+		"出力形式 (table, json, csv)",
+	)
+	flags.BoolVar(
+		&f.output.noHeader,
+		"noHeader",
+		false,
+		"table, csv でヘッダ行を出力しない",
+	)
+	flags.IntVar(
+		&f.output.width,
+		"width",
+		0,
+		"table で切り詰める表示幅。0の場合は切り詰めない (default: 端末の表示幅ないしは環境変数 COLUMNS)",
+	)
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return ExitCodeParseFlagError
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprintf(c.ErrStream, "Usage: kafun qc -startYM YYYYMM -todofukenCode PREF [options]\n")
+		return ExitCodeParseFlagError
+	}
+
+	switch report {
+	case qcReportSummary, qcReportRows:
+	default:
+		fmt.Fprintf(c.ErrStream, "invalid report: %s\n", report)
+		return ExitCodeParseFlagError
+	}
+	if err := rule.validate(); err != nil {
+		fmt.Fprintf(c.ErrStream, "%v\n", err)
+		return ExitCodeParseFlagError
+	}
+	if _, err := summaryOutputThresholds(&f.output); err != nil {
+		fmt.Fprintf(c.ErrStream, "failed to initialize output: %v\n", err)
+		return ExitCodeParseFlagError
+	}
+	if !isFlagSet(flags, "width") {
+		f.output.width = terminalWidth(c.OutStream)
+	}
+
+	source, param, code := c.prepareSearch(&f)
+	if code != ExitCodeOK {
+		return code
+	}
+	data, code := c.fetch(&f, source, param)
+	if code != ExitCodeOK {
+		return code
+	}
+
+	stations, err := CheckQuality(data, rule)
+	if err != nil {
+		fmt.Fprintf(c.ErrStream, "%v\n", err)
+		return ExitCodeParseFlagError
+	}
+
+	if err := writeQC(c.OutStream, stations, data, report, &f.output); err != nil {
+		fmt.Fprintf(c.ErrStream, "failed to write output: %v\n", err)
+		return ExitCodeOutputError
+	}
+
+	return ExitCodeOK
+}
+
+// qcSummaryRecord は品質管理の測定局ごとの集計の出力の1行。CSVの見出しはJSONキーを使う。
+type qcSummaryRecord struct {
+	SokuteikyokuCode string `json:"SKT_CD"`
+	SokuteikyokuName string `json:"SKT_NM"`
+	TodofukenCode    string `json:"TDFKN_CD"`
+	Checked          int    `json:"checked"`
+	Flagged          int    `json:"flagged"`
+	Spike            int    `json:"spike"`
+	Flatline         int    `json:"flatline"`
+	Neighbor         int    `json:"neighbor"`
+}
+
+// qcRowRecord は疑わしい測定データの出力の1行。
+type qcRowRecord struct {
+	SokuteikyokuCode string `json:"SKT_CD"`
+	SokuteikyokuName string `json:"SKT_NM"`
+	SokuteiNengappi  string `json:"SKT_NNGP"`
+	SokuteiJikoku    string `json:"SKT_HH"`
+	KafunNum         int    `json:"KFN_NUM"`
+	QC               string `json:"qc"`
+}
+
+// writeQC は品質管理の結果を出力の種類と出力形式に応じて出力する。rows の場合は data を測定局コード、測定日時の順に並べ替える。
+func writeQC(w io.Writer, stations []*StationQC, data SokuteiData, kind string, opts *outputOptions) error {
+	report := &summaryReport{}
+
+	switch kind {
+	case qcReportRows:
+		report.columns = []*tableColumn{
+			{Title: "コード"},
+			{Title: "測定局", truncatable: true},
+			{Title: "測定年月日"},
+			{Title: "測定時刻", alignRight: true},
+			{Title: "花粉数", alignRight: true},
+			{Title: "理由", truncatable: true},
+		}
+		report.header = []string{"SKT_CD", "SKT_NM", "SKT_NNGP", "SKT_HH", "KFN_NUM", "qc"}
+		data.SortByStation()
+		records := []*qcRowRecord{}
+		for _, hsd := range data {
+			if hsd.QC == 0 {
+				continue
+			}
+			r := &qcRowRecord{
+				SokuteikyokuCode: hsd.SokuteikyokuCode,
+				SokuteikyokuName: hsd.SokuteikyokuName,
+				SokuteiNengappi:  hsd.SokuteiNengappi,
+				SokuteiJikoku:    hsd.SokuteiJikoku,
+				KafunNum:         hsd.KafunNum,
+				QC:               hsd.QC.English(),
+			}
+			records = append(records, r)
+			report.rows = append(report.rows, []string{
+				r.SokuteikyokuCode,
+				r.SokuteikyokuName,
+				r.SokuteiNengappi,
+				r.SokuteiJikoku,
+				strconv.Itoa(r.KafunNum),
+				r.QC,
+			})
+			report.tableRows = append(report.tableRows, []string{
+				r.SokuteikyokuCode,
+				orDash(r.SokuteikyokuName),
+				r.SokuteiNengappi,
+				r.SokuteiJikoku,
+				strconv.Itoa(r.KafunNum),
+				hsd.QC.Japanese(),
+			})
+		}
+		report.records = records
+
+	default:
+		report.columns = []*tableColumn{
+			{Title: "コード"},
+			{Title: "測定局", truncatable: true},
+			{Title: "検査数", alignRight: true},
+			{Title: "疑わしい数", alignRight: true},
+			{Title: "急増", alignRight: true},
+			{Title: "一定値", alignRight: true},
+			{Title: "近隣と乖離", alignRight: true},
+		}
+		report.header = []string{"SKT_CD", "SKT_NM", "TDFKN_CD", "checked", "flagged", "spike", "flatline", "neighbor"}
+		records := make([]*qcSummaryRecord, len(stations))
+		for i, s := range stations {
+			r := &qcSummaryRecord{
+				SokuteikyokuCode: s.SokuteikyokuCode,
+				SokuteikyokuName: s.SokuteikyokuName,
+				TodofukenCode:    s.TodofukenCode,
+				Checked:          s.Checked,
+				Flagged:          s.Flagged,
+				Spike:            s.Spike,
+				Flatline:         s.Flatline,
+				Neighbor:         s.Neighbor,
+			}
+			records[i] = r
+			counts := []string{
+				strconv.Itoa(r.Checked),
+				strconv.Itoa(r.Flagged),
+				strconv.Itoa(r.Spike),
+				strconv.Itoa(r.Flatline),
+				strconv.Itoa(r.Neighbor),
+			}
+			report.rows = append(report.rows, append([]string{r.SokuteikyokuCode, r.SokuteikyokuName, r.TodofukenCode}, counts...))
+			report.tableRows = append(report.tableRows, append([]string{r.SokuteikyokuCode, orDash(r.SokuteikyokuName)}, counts...))
+		}
+		report.records = records
+	}
+
+	return report.write(w, opts)
+}
@@ -0,0 +1,94 @@
+package kafun
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestCLI_runQC(t *testing.T) {
+	t.Parallel()
+	day := time.Date(2021, 3, 1, 0, 0, 0, 0, jst)
+	data := append(append(
+		hourlyFixture(t, "1", day, 0, 0, 1, 0, 3000, 0, 2, 0),
+		hourlyFixture(t, "2", day, 0, 0, 0, 0, 0, 0, 0, 0)...),
+		hourlyFixture(t, "3", day, 0, 5, 5, 5, 5, 5, 5, 0)...,
+	)
+	dir := writeArchiveFixture(t, map[string][]byte{"2021.jsonl": archiveJSONL(t, data)})
+	search := []string{"-startYM", "202103", "-todofukenCode", "13", "-archive", dir}
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantErrout string
+	}{
+		{
+			name:     "standard case: summary table",
+			args:     []string{"-width", "0"},
+			wantCode: ExitCodeOK,
+			wantStdout: "コード  測定局         検査数  疑わしい数  急増  一定値  近隣と乖離\n" +
+				"1       テスト観測所1       8           1     1       0           0\n" +
+				"2       テスト観測所2       8           0     0       0           0\n" +
+				"3       テスト観測所3       8           6     0       6           0\n",
+		},
+		{
+			name:     "standard case: summary csv",
+			args:     []string{"-format", "csv", "-sokuteikyokuCode", "1"},
+			wantCode: ExitCodeOK,
+			wantStdout: "SKT_CD,SKT_NM,TDFKN_CD,checked,flagged,spike,flatline,neighbor\n" +
+				"1,テスト観測所1,13,8,1,1,0,0\n",
+		},
+		{
+			name:       "standard case: rows table",
+			args:       []string{"-report", "rows", "-width", "0", "-noHeader", "-flatlineHours", "7"},
+			wantCode:   ExitCodeOK,
+			wantStdout: "1  テスト観測所1  20210301  5  3000  急増\n",
+		},
+		{
+			name:     "standard case: rows json",
+			args:     []string{"-report", "rows", "-format", "json", "-sokuteikyokuCode", "1"},
+			wantCode: ExitCodeOK,
+			wantStdout: `[
+	{
+		"SKT_CD": "1",
+		"SKT_NM": "テスト観測所1",
+		"SKT_NNGP": "20210301",
+		"SKT_HH": "5",
+		"KFN_NUM": 3000,
+		"qc": "spike"
+	}
+]
+`,
+		},
+		{
+			name:       "error case: invalid rule",
+			args:       []string{"-window", "0"},
+			wantCode:   ExitCodeParseFlagError,
+			wantErrout: "invalid qc rule: {Window:0 SpikeFactor:10 SpikeMin:100 FlatlineHours:6 NeighborFactor:10 NeighborMin:100 NeighborStations:3}\n",
+		},
+		{
+			name:       "error case: unknown report",
+			args:       []string{"-report", "hours"},
+			wantCode:   ExitCodeParseFlagError,
+			wantErrout: "invalid report: hours\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outStream, errOut := new(bytes.Buffer), new(bytes.Buffer)
+			c := &CLI{OutStream: outStream, ErrStream: errOut}
+			args := append(append([]string{"kafun", "qc"}, search...), tt.args...)
+			if got := c.Run(args); got != tt.wantCode {
+				t.Errorf("Run() return code = %v, want %v: %s", got, tt.wantCode, errOut.String())
+			}
+			if outStream.String() != tt.wantStdout {
+				t.Errorf("Run() stdout = %q, want %q", outStream.String(), tt.wantStdout)
+			}
+			if errOut.String() != tt.wantErrout {
+				t.Errorf("Run() errout = %q, want %q", errOut.String(), tt.wantErrout)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package kafun
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// runSeason は season サブコマンドを実行する。
+//
+//	kafun season -startYM YYYYMM [-endYM YYYYMM] -todofukenCode PREF [検索のオプション]
+//	    [-threshold F] [-startDays N] [-endDays N] [-level] [-levelThresholds LIST]
+//	    [-format table|json|csv] [-noHeader] [-width N]
+func (c *CLI) runSeason(args []string) int {
+	var f cliFlags
+	rule := DefaultSeasonRule
+
+	flags := flag.NewFlagSet("kafun season", flag.ContinueOnError)
+	flags.SetOutput(c.ErrStream)
+	f.setSearchFlags(flags)
+	flags.Float64Var(
+		&rule.Threshold,
+		"threshold",
+		rule.Threshold,
+		"花粉が飛散した日とみなす1時間の花粉数の日平均の下限",
+	)
+	flags.IntVar(
+		&rule.StartDays,
+		"startDays",
+		rule.StartDays,
+		"飛散開始とみなす、花粉が飛散した日が続く日数",
+	)
+	flags.IntVar(
+		&rule.EndDays,
+		"endDays",
+		rule.EndDays,
+		"飛散終了とみなす、花粉が飛散しなかった日が続く日数",
+	)
+	flags.StringVar(
+		&f.output.format,
+		"format",
+		FormatTable,
+		"出力形式 (table, json, csv)",
+	)
+	flags.BoolVar(
+		&f.output.noHeader,
+		"noHeader",
+		false,
+		"table, csv でヘッダ行を出力しない",
+	)
+	flags.IntVar(
+		&f.output.width,
+		"width",
+		0,
+		"table で切り詰める表示幅。0の場合は切り詰めない (default: 端末の表示幅ないしは環境変数 COLUMNS)",
+	)
+	flags.BoolVar(
+		&f.output.level,
+		"level",
+		false,
+		"ピーク日の日平均の段階の level 項目を追加する",
+	)
+	flags.StringVar(
+		&f.output.levelThresholds,
+		"levelThresholds",
+		"",
+		"やや多い, 多い, 非常に多い, 極めて多い の下限の花粉数をカンマ区切りで指定 (default: 10,31,51,101)",
+	)
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return ExitCodeParseFlagError
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprintf(c.ErrStream, "Usage: kafun season -startYM YYYYMM -todofukenCode PREF [options]\n")
+		return ExitCodeParseFlagError
+	}
+
+	if err := rule.validate(); err != nil {
+		fmt.Fprintf(c.ErrStream, "%v\n", err)
+		return ExitCodeParseFlagError
+	}
+	thresholds, err := summaryOutputThresholds(&f.output)
+	if err != nil {
+		fmt.Fprintf(c.ErrStream, "failed to initialize output: %v\n", err)
+		return ExitCodeParseFlagError
+	}
+	if !isFlagSet(flags, "width") {
+		f.output.width = terminalWidth(c.OutStream)
+	}
+
+	source, param, code := c.prepareSearch(&f)
+	if code != ExitCodeOK {
+		return code
+	}
+	data, code := c.fetch(&f, source, param)
+	if code != ExitCodeOK {
+		return code
+	}
+
+	seasons, err := DetectSeasons(data, rule)
+	if err != nil {
+		fmt.Fprintf(c.ErrStream, "%v\n", err)
+		return ExitCodeParseFlagError
+	}
+
+	if err := writeSeasons(c.OutStream, seasons, &f.output, thresholds); err != nil {
+		fmt.Fprintf(c.ErrStream, "failed to write output: %v\n", err)
+		return ExitCodeOutputError
+	}
+
+	return ExitCodeOK
+}
+
+// summaryOutputThresholds は集計結果を出力するサブコマンドの出力形式を検証し、段階の下限を返す。
+func summaryOutputThresholds(opts *outputOptions) (Thresholds, error) {
+	switch opts.format {
+	case "", FormatTable, FormatJSON, FormatCSV:
+	default:
+		return Thresholds{}, xerrors.Errorf("unknown output format: %s", opts.format)
+	}
+
+	if len(opts.levelThresholds) == 0 {
+		return DefaultThresholds, nil
+	}
+
+	return ParseThresholds(opts.levelThresholds)
+}
+
+// seasonRecord は飛散期間の出力の1行。CSVの見出しはJSONキーを使う。
+type seasonRecord struct {
+	SokuteikyokuCode string   `json:"SKT_CD"`
+	SokuteikyokuName string   `json:"SKT_NM"`
+	TodofukenCode    string   `json:"TDFKN_CD"`
+	Year             int      `json:"year"`
+	Start            string   `json:"start,omitempty"`
+	End              string   `json:"end,omitempty"`
+	PeakDate         string   `json:"peak_date"`
+	PeakMean         float64  `json:"peak_mean"`
+	Total            int      `json:"total"`
+	Coverage         float64  `json:"coverage"`
+	Level            string   `json:"level,omitempty"`
+	Notes            []string `json:"notes"`
+}
+
+func newSeasonRecord(s *Season, level bool, thresholds Thresholds) *seasonRecord {
+	r := &seasonRecord{
+		SokuteikyokuCode: s.SokuteikyokuCode,
+		SokuteikyokuName: s.SokuteikyokuName,
+		TodofukenCode:    s.TodofukenCode,
+		Year:             s.Year,
+		PeakDate:         formatDate(s.Peak.Date),
+		PeakMean:         math.Round(s.Peak.Mean()*10) / 10,
+		Total:            s.Total,
+		Coverage:         math.Round(s.Coverage*1000) / 1000,
+		Notes:            append([]string{}, s.Notes...),
+	}
+	if s.HasStart() {
+		r.Start = formatDate(s.Start)
+	}
+	if s.HasEnd() {
+		r.End = formatDate(s.End)
+	}
+	if level {
+		if l, ok := s.Peak.Level(thresholds); ok {
+			r.Level = l.English()
+		}
+	}
+
+	return r
+}
+
+// writeSeasons は飛散期間を出力形式に応じて出力する。
+func writeSeasons(w io.Writer, seasons []*Season, opts *outputOptions, thresholds Thresholds) error {
+	report := &summaryReport{
+		columns: []*tableColumn{
+			{Title: "コード"},
+			{Title: "測定局", truncatable: true},
+			{Title: "年"},
+			{Title: "開始日"},
+			{Title: "終了日"},
+			{Title: "ピーク日"},
+			{Title: "ピーク日平均", alignRight: true},
+			{Title: "合計", alignRight: true},
+			{Title: "カバー率", alignRight: true},
+		},
+		header: []string{
+			"SKT_CD", "SKT_NM", "TDFKN_CD", "year", "start", "end", "peak_date", "peak_mean", "total", "coverage",
+		},
+	}
+	if opts.level {
+		report.columns = append(report.columns, &tableColumn{Title: "段階"})
+		report.header = append(report.header, "level")
+	}
+	report.columns = append(report.columns, &tableColumn{Title: "注記", truncatable: true})
+	report.header = append(report.header, "notes")
+
+	records := make([]*seasonRecord, len(seasons))
+	report.rows = make([][]string, len(seasons))
+	report.tableRows = make([][]string, len(seasons))
+	for i, s := range seasons {
+		r := newSeasonRecord(s, opts.level, thresholds)
+		records[i] = r
+
+		row := []string{
+			r.SokuteikyokuCode,
+			r.SokuteikyokuName,
+			r.TodofukenCode,
+			strconv.Itoa(r.Year),
+			r.Start,
+			r.End,
+			r.PeakDate,
+			strconv.FormatFloat(r.PeakMean, 'f', -1, 64),
+			strconv.Itoa(r.Total),
+			strconv.FormatFloat(r.Coverage, 'f', -1, 64),
+		}
+		tableRow := []string{
+			r.SokuteikyokuCode,
+			r.SokuteikyokuName,
+			strconv.Itoa(r.Year),
+			orDash(r.Start),
+			orDash(r.End),
+			r.PeakDate,
+			strconv.FormatFloat(r.PeakMean, 'f', 1, 64),
+			strconv.Itoa(r.Total),
+			strconv.FormatFloat(s.Coverage*100, 'f', 1, 64) + "%",
+		}
+		if opts.level {
+			row = append(row, r.Level)
+			l, _ := s.Peak.Level(thresholds)
+			tableRow = append(tableRow, l.Japanese())
+		}
+		notes := strings.Join(r.Notes, "; ")
+		report.rows[i] = append(row, notes)
+		report.tableRows[i] = append(tableRow, notes)
+	}
+	report.records = records
+
+	return report.write(w, opts)
+}
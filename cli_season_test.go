@@ -0,0 +1,110 @@
+package kafun
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// archiveJSONL は測定データを都道府県コードと測定局名を付けたJSONLにする。
+func archiveJSONL(t *testing.T, data SokuteiData) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, hsd := range data {
+		hsd.TodofukenCode = "13"
+		hsd.SokuteikyokuName = "テスト観測所" + hsd.SokuteikyokuCode
+		if err := encoder.Encode(hsd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func TestCLI_runSeason(t *testing.T) {
+	t.Parallel()
+	from := time.Date(2021, 2, 1, 0, 0, 0, 0, jst)
+	data := append(
+		dailyFixture(t, "1", from, 0, 2, 3, 40, 0, 0, 0),
+		dailyFixture(t, "2", from, 0, 0, 0)...,
+	)
+	dir := writeArchiveFixture(t, map[string][]byte{"2021.jsonl": archiveJSONL(t, data)})
+	search := []string{"-startYM", "202102", "-todofukenCode", "13", "-archive", dir}
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantErrout string
+	}{
+		{
+			name:     "standard case: table",
+			args:     []string{"-width", "0"},
+			wantCode: ExitCodeOK,
+			wantStdout: "コード  測定局         年    開始日      終了日      ピーク日    ピーク日平均  合計  カバー率  注記\n" +
+				"1       テスト観測所1  2021  2021-02-02  2021-02-04  2021-02-04          40.0  1080    100.0%\n" +
+				"2       テスト観測所2  2021  -           -           2021-02-01           0.0     0    100.0%  " +
+				"no 2 consecutive days with daily mean of 1 or more\n",
+		},
+		{
+			name:     "standard case: csv with level",
+			args:     []string{"-format", "csv", "-level", "-sokuteikyokuCode", "1"},
+			wantCode: ExitCodeOK,
+			wantStdout: "SKT_CD,SKT_NM,TDFKN_CD,year,start,end,peak_date,peak_mean,total,coverage,level,notes\n" +
+				"1,テスト観測所1,13,2021,2021-02-02,2021-02-04,2021-02-04,40,1080,1,high,\n",
+		},
+		{
+			name:     "standard case: json with custom rule",
+			args:     []string{"-format", "json", "-sokuteikyokuCode", "1", "-threshold", "3", "-startDays", "1", "-endDays", "4"},
+			wantCode: ExitCodeOK,
+			wantStdout: `[
+	{
+		"SKT_CD": "1",
+		"SKT_NM": "テスト観測所1",
+		"TDFKN_CD": "13",
+		"year": 2021,
+		"start": "2021-02-03",
+		"peak_date": "2021-02-04",
+		"peak_mean": 40,
+		"total": 1032,
+		"coverage": 1,
+		"notes": [
+			"season end not confirmed: less than 4 days of data after the last day with daily mean of 3 or more (2021-02-04)"
+		]
+	}
+]
+`,
+		},
+		{
+			name:       "error case: invalid rule",
+			args:       []string{"-startDays", "0"},
+			wantCode:   ExitCodeParseFlagError,
+			wantErrout: "invalid season rule: threshold must be positive and days must be at least 1: {Threshold:1 StartDays:0 EndDays:3}\n",
+		},
+		{
+			name:       "error case: unknown format",
+			args:       []string{"-format", "ndjson"},
+			wantCode:   ExitCodeParseFlagError,
+			wantErrout: "failed to initialize output: unknown output format: ndjson\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outStream, errOut := new(bytes.Buffer), new(bytes.Buffer)
+			c := &CLI{OutStream: outStream, ErrStream: errOut}
+			args := append(append([]string{"kafun", "season"}, search...), tt.args...)
+			if got := c.Run(args); got != tt.wantCode {
+				t.Errorf("Run() return code = %v, want %v: %s", got, tt.wantCode, errOut.String())
+			}
+			if outStream.String() != tt.wantStdout {
+				t.Errorf("Run() stdout = %q, want %q", outStream.String(), tt.wantStdout)
+			}
+			if errOut.String() != tt.wantErrout {
+				t.Errorf("Run() errout = %q, want %q", errOut.String(), tt.wantErrout)
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+package kafun
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 測定局の一覧の列の見出し。CSVとJSONは Station のJSONキーを使う。
+var stationTableColumns = []*tableColumn{
+	{Title: "コード"},
+	{Title: "測定局", truncatable: true},
+	{Title: "種別"},
+	{Title: "都道府県"},
+	{Title: "市区町村", truncatable: true},
+	{Title: "アメダス"},
+}
+
+var stationCSVHeader = []string{
+	"SKT_CD", "SKT_NM", "SKT_TYPE", "TDFKN_CD", "TDFKN_NM", "SKCHSN_CD", "SKCHSN_NM", "AMeDAS_CD", "latitude", "longitude",
+}
+
+// runStations は stations サブコマンドを実行する。
+//
+//	kafun stations list [-catalog FILE] [-todofukenCode PREF] [-format table|json|csv] [-noHeader] [-width N]
+//	kafun stations search [-catalog FILE] [-todofukenCode PREF] [-limit N] [-format table|json|csv] [-noHeader] [-width N] QUERY
+//	kafun stations build [-catalog FILE] [-o FILE] [-startYM YYYYMM [-endYM YYYYMM]] [PATH...]
+func (c *CLI) runStations(args []string) int {
+	if len(args) < 2 || (args[1] != "list" && args[1] != "search" && args[1] != "build") {
+		fmt.Fprintf(c.ErrStream, "Usage: kafun stations list|search|build [options]\n")
+		return ExitCodeParseFlagError
+	}
+	action := args[1]
+
+	var (
+		catalogPath   string
+		todofukenCode string
+		output        outputOptions
+		limit         int
+		outPath       string
+		fetch         cliFlags
+	)
+
+	flags := flag.NewFlagSet("kafun stations "+action, flag.ContinueOnError)
+	flags.SetOutput(c.ErrStream)
+	flags.StringVar(
+		&catalogPath,
+		"catalog",
+		"",
+		"測定局の一覧のJSONファイル。指定しない場合は組み込みの一覧を使う",
+	)
+	switch action {
+	case "list", "search":
+		flags.StringVar(
+			&todofukenCode,
+			"todofukenCode",
+			"",
+			"都道府県。コード (01 to 47)、名前 (東京都)、ローマ字 (tokyo) のいずれかで指定",
+		)
+		flags.StringVar(
+			&output.format,
+			"format",
+			FormatTable,
+			"出力形式 (table, json, csv)",
+		)
+		flags.BoolVar(
+			&output.noHeader,
+			"noHeader",
+			false,
+			"table, csv でヘッダ行を出力しない",
+		)
+		flags.IntVar(
+			&output.width,
+			"width",
+			0,
+			"table で切り詰める表示幅。0の場合は切り詰めない (default: 端末の表示幅ないしは環境変数 COLUMNS)",
+		)
+		if action == "search" {
+			flags.IntVar(
+				&limit,
+				"limit",
+				20,
+				"表示する測定局の最大数。0の場合はすべて表示",
+			)
+		}
+	case "build":
+		flags.StringVar(
+			&outPath,
+			"o",
+			"",
+			"測定局の一覧の出力先ファイル。指定しない場合は標準出力に出力",
+		)
+		flags.StringVar(
+			&fetch.startYM,
+			"startYM",
+			"",
+			"指定した場合は、この年月からの47都道府県の測定データをAPIから取得して測定局を取り出す (format: yyyyMM)",
+		)
+		flags.StringVar(
+			&fetch.endYM,
+			"endYM",
+			"",
+			"APIから取得する測定データの終了年月 (format: yyyyMM)",
+		)
+	}
+
+	if err := flags.Parse(args[2:]); err != nil {
+		return ExitCodeParseFlagError
+	}
+
+	switch output.format {
+	case "", FormatTable, FormatJSON, FormatCSV:
+	default:
+		fmt.Fprintf(c.ErrStream, "failed to initialize output: unknown output format: %s\n", output.format)
+		return ExitCodeParseFlagError
+	}
+
+	catalog, err := loadStationCatalog(catalogPath)
+	if err != nil {
+		fmt.Fprintf(c.ErrStream, "failed to load station catalog: %v\n", err)
+		return ExitCodeInitializeError
+	}
+
+	if action == "build" {
+		return c.buildStations(catalog, flags.Args(), &fetch, outPath)
+	}
+
+	if len(todofukenCode) != 0 {
+		prefecture, ok := LookupPrefecture(todofukenCode)
+		if !ok {
+			fmt.Fprintf(c.ErrStream, "invalid todofukenCode: unknown prefecture: %s\n", todofukenCode)
+			return ExitCodeValidationError
+		}
+		catalog = NewStationCatalog(catalog.ByPrefecture(prefecture.Code))
+	}
+
+	stations := catalog.Stations()
+	if action == "search" {
+		if flags.NArg() == 0 {
+			fmt.Fprintf(c.ErrStream, "Usage: kafun stations search [options] QUERY\n")
+			return ExitCodeParseFlagError
+		}
+
+		matches := catalog.Search(strings.Join(flags.Args(), " "))
+		if limit > 0 && len(matches) > limit {
+			matches = matches[:limit]
+		}
+		stations = make([]Station, len(matches))
+		for i, m := range matches {
+			stations[i] = m.Station
+		}
+	}
+
+	if !isFlagSet(flags, "width") {
+		output.width = terminalWidth(c.OutStream)
+	}
+	if err := writeStations(c.OutStream, stations, &output); err != nil {
+		fmt.Fprintf(c.ErrStream, "failed to write output: %v\n", err)
+		return ExitCodeOutputError
+	}
+
+	return ExitCodeOK
+}
+
+// buildStations は paths の測定データから測定局を取り出して catalog を更新し、outPath に出力する。
+// paths にはディレクトリ (-archive と同じ形式) とファイルを指定できる。
+// fetch の開始年月が指定されている場合は、47都道府県の測定データをAPIから取得して測定局を取り出す。
+func (c *CLI) buildStations(catalog *StationCatalog, paths []string, fetch *cliFlags, outPath string) int {
+	if len(paths) == 0 && len(fetch.startYM) == 0 {
+		fmt.Fprintf(c.ErrStream, "Usage: kafun stations build [-catalog FILE] [-o FILE] [-startYM YYYYMM [-endYM YYYYMM]] [PATH...]\n")
+		return ExitCodeParseFlagError
+	}
+
+	var data SokuteiData
+	if len(fetch.startYM) != 0 {
+		fetched, code := c.fetchAllPrefectures(fetch)
+		if code != ExitCodeOK {
+			return code
+		}
+		data = append(data, fetched...)
+	}
+	for _, path := range paths {
+		read, err := readStationSource(path, c.warnRowError)
+		if err != nil {
+			fmt.Fprintf(c.ErrStream, "failed to read %s: %v\n", path, err)
+			return ExitCodeStationError
+		}
+		data = append(data, read...)
+	}
+
+	stations := StationsFromSokuteiData(data)
+	merged := catalog.Merge(stations)
+
+	var w io.Writer = c.OutStream
+	if len(outPath) != 0 {
+		f, err := os.Create(outPath)
+		if err != nil {
+			fmt.Fprintf(c.ErrStream, "failed to create %s: %v\n", outPath, err)
+			return ExitCodeStationError
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := merged.WriteJSON(w); err != nil {
+		fmt.Fprintf(c.ErrStream, "failed to write station catalog: %v\n", err)
+		return ExitCodeStationError
+	}
+	fmt.Fprintf(
+		c.ErrStream,
+		"%d stations found in data, %d stations in catalog (%d added)\n",
+		len(stations),
+		merged.Len(),
+		merged.Len()-catalog.Len(),
+	)
+
+	return ExitCodeOK
+}
+
+// fetchAllPrefectures は47都道府県の測定データをAPIから取得する。
+// 測定局の一覧を作るのが目的なので、不正な値の項目があっても DecodeLenient で読み込みを続ける。
+func (c *CLI) fetchAllPrefectures(f *cliFlags) (SokuteiData, int) {
+	f.decodeMode = DecodeLenient
+	source, code := c.newDataSource(f)
+	if code != ExitCodeOK {
+		return nil, code
+	}
+
+	var data SokuteiData
+	for _, p := range Prefectures() {
+		param := &SearchParam{StartYM: f.startYM, EndYM: f.endYM, TodofukenCode: p.Code}
+		response, err := source.Search(context.Background(), param)
+		if err != nil {
+			fmt.Fprintf(c.ErrStream, "failed to fetch %s (%s): %v\n", p.Name, p.Code, err)
+			return nil, exitCodeFromError(err)
+		}
+		data = append(data, response...)
+	}
+
+	return data, ExitCodeOK
+}
+
+// readStationSource はディレクトリ以下のダウンロード済みデータないしはファイルの測定データを読み込む。
+// CSVの読み込めない行は読み飛ばして onRowError に渡す。
+func readStationSource(path string, onRowError func(string, *RowError)) (SokuteiData, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		data, rowErrors, err := readArchiveFile(path, DecodeStrict)
+		for _, rowErr := range rowErrors {
+			onRowError(path, rowErr)
+		}
+		return data, err
+	}
+
+	var data SokuteiData
+	archive := &Archive{Dir: path, OnRowError: onRowError}
+	err = archive.each(context.Background(), func(hsd *HourlySokuteiData) error {
+		data = append(data, hsd)
+		return nil
+	})
+
+	return data, err
+}
+
+// resolveStationCodes はカンマ区切りの測定局コードないしは測定局名を、カンマ区切りの測定局コードにする。
+// 数字だけの値は測定局コードとしてそのまま使い、それ以外は catalog の測定局名などから解決する。
+func resolveStationCodes(catalog *StationCatalog, value string) (string, error) {
+	var codes []string
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if len(v) == 0 {
+			continue
+		}
+		if isDigits(v) {
+			codes = append(codes, v)
+			continue
+		}
+
+		station, err := catalog.Resolve(v)
+		if err != nil {
+			return "", err
+		}
+		codes = append(codes, station.Code)
+	}
+
+	return strings.Join(codes, ","), nil
+}
+
+// isStationCodeList はカンマ区切りの値がすべて測定局コードで、測定局名を解決する必要がないかどうかを返す。
+func isStationCodeList(value string) bool {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); len(v) != 0 && !isDigits(v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return len(s) != 0
+}
+
+// loadStationCatalog は path の測定局の一覧を読み込む。path が空文字列の場合は組み込みの一覧を返す。
+func loadStationCatalog(path string) (*StationCatalog, error) {
+	if len(path) == 0 {
+		return DefaultStationCatalog(), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return LoadStationCatalog(f)
+}
+
+// writeStations は測定局の一覧を出力形式に応じて出力する。
+func writeStations(w io.Writer, stations []Station, opts *outputOptions) error {
+	// 検索結果の順を保つため StationCatalog を使わずに出力する
+	report := &summaryReport{
+		columns: stationTableColumns,
+		header:  stationCSVHeader,
+		records: stations,
+	}
+	if stations == nil {
+		report.records = []Station{}
+	}
+	for _, s := range stations {
+		report.rows = append(report.rows, stationRecord(s))
+		report.tableRows = append(report.tableRows, []string{s.Code, s.Name, s.Type, s.TodofukenName, s.ShichosonName, s.AMeDASCode})
+	}
+
+	return report.write(w, opts)
+}
+
+// stationRecord は測定局を stationCSVHeader の順の値で返す。
+func stationRecord(s Station) []string {
+	coordinate := func(f *float64) string {
+		if f == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*f, 'f', -1, 64)
+	}
+
+	return []string{
+		s.Code,
+		s.Name,
+		s.Type,
+		s.TodofukenCode,
+		s.TodofukenName,
+		s.ShichosonCode,
+		s.ShichosonName,
+		s.AMeDASCode,
+		coordinate(s.Latitude),
+		coordinate(s.Longitude),
+	}
+}
@@ -0,0 +1,223 @@
+package kafun
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCLI_runStations_list(t *testing.T) {
+	t.Parallel()
+	dir := writeArchiveFixture(t, map[string][]byte{
+		"stations.json": []byte(`[
+			{"SKT_CD": "00000001", "SKT_NM": "テスト観測所1", "TDFKN_CD": "13", "TDFKN_NM": "東京都", "SKCHSN_NM": "テスト区"},
+			{"SKT_CD": "00000002", "SKT_NM": "テスト観測所2", "TDFKN_CD": "14", "TDFKN_NM": "神奈川県", "latitude": 35.4}
+		]`),
+	})
+	catalog := filepath.Join(dir, "stations.json")
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantErrout string
+	}{
+		{
+			name:     "standard case: table",
+			args:     []string{"kafun", "stations", "list", "-catalog", catalog, "-width", "0"},
+			wantCode: ExitCodeOK,
+			wantStdout: "コード    測定局         種別  都道府県  市区町村  アメダス\n" +
+				"00000001  テスト観測所1        東京都    テスト区\n" +
+				"00000002  テスト観測所2        神奈川県\n",
+		},
+		{
+			name:     "standard case: csv filtered by prefecture name",
+			args:     []string{"kafun", "stations", "list", "-catalog", catalog, "-todofukenCode", "kanagawa", "-format", "csv"},
+			wantCode: ExitCodeOK,
+			wantStdout: "SKT_CD,SKT_NM,SKT_TYPE,TDFKN_CD,TDFKN_NM,SKCHSN_CD,SKCHSN_NM,AMeDAS_CD,latitude,longitude\n" +
+				"00000002,テスト観測所2,,14,神奈川県,,,,35.4,\n",
+		},
+		{
+			name:       "standard case: embedded catalog",
+			args:       []string{"kafun", "stations", "list", "-format", "csv", "-noHeader", "-todofukenCode", "13"},
+			wantCode:   ExitCodeOK,
+			wantStdout: "51320100,新宿区役所第二分庁舎,,13,東京都,13104,新宿区,,,\n",
+		},
+		{
+			name:       "standard case: search by romaji",
+			args:       []string{"kafun", "stations", "search", "-format", "csv", "-noHeader", "shinjuku"},
+			wantCode:   ExitCodeOK,
+			wantStdout: "51320100,新宿区役所第二分庁舎,,13,東京都,13104,新宿区,,,\n",
+		},
+		{
+			name:       "standard case: search by katakana",
+			args:       []string{"kafun", "stations", "search", "-format", "csv", "-noHeader", "シンジュク"},
+			wantCode:   ExitCodeOK,
+			wantStdout: "51320100,新宿区役所第二分庁舎,,13,東京都,13104,新宿区,,,\n",
+		},
+		{
+			name:       "standard case: search without match",
+			args:       []string{"kafun", "stations", "search", "-catalog", catalog, "-format", "json", "大阪"},
+			wantCode:   ExitCodeOK,
+			wantStdout: "[]\n",
+		},
+		{
+			name:       "standard case: search limited",
+			args:       []string{"kafun", "stations", "search", "-catalog", catalog, "-format", "csv", "-noHeader", "-limit", "1", "テスト"},
+			wantCode:   ExitCodeOK,
+			wantStdout: "00000001,テスト観測所1,,13,東京都,,テスト区,,,\n",
+		},
+		{
+			name:       "error case: search without query",
+			args:       []string{"kafun", "stations", "search", "-catalog", catalog},
+			wantCode:   ExitCodeParseFlagError,
+			wantErrout: "Usage: kafun stations search [options] QUERY\n",
+		},
+		{
+			name:       "error case: unknown action",
+			args:       []string{"kafun", "stations", "remove"},
+			wantCode:   ExitCodeParseFlagError,
+			wantErrout: "Usage: kafun stations list|search|build [options]\n",
+		},
+		{
+			name:       "error case: unknown format",
+			args:       []string{"kafun", "stations", "list", "-format", "xml"},
+			wantCode:   ExitCodeParseFlagError,
+			wantErrout: "failed to initialize output: unknown output format: xml\n",
+		},
+		{
+			name:       "error case: unknown prefecture",
+			args:       []string{"kafun", "stations", "list", "-todofukenCode", "atlantis"},
+			wantCode:   ExitCodeValidationError,
+			wantErrout: "invalid todofukenCode: unknown prefecture: atlantis\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			stdOut := new(bytes.Buffer)
+			errOut := new(bytes.Buffer)
+			c := &CLI{OutStream: stdOut, ErrStream: errOut}
+
+			if got := c.Run(tt.args); got != tt.wantCode {
+				t.Errorf("Run() return code = %v, want %v", got, tt.wantCode)
+			}
+			if stdOut.String() != tt.wantStdout {
+				t.Errorf("Run() stdout = %q, want %q", stdOut.String(), tt.wantStdout)
+			}
+			if errOut.String() != tt.wantErrout {
+				t.Errorf("Run() errout = %q, want %q", errOut.String(), tt.wantErrout)
+			}
+		})
+	}
+}
+
+func TestCLI_runStations_build(t *testing.T) {
+	t.Parallel()
+	dir := writeArchiveFixture(t, archiveFixtureFiles(t))
+	extra := writeArchiveFixture(t, map[string][]byte{
+		"extra.jsonl": []byte(`{"SKT_CD": "51320100", "SKT_NNGP": "20210201", "SKT_HH": "01", "TDFKN_CD": "13", "AMeDAS_CD": "44132", "KFN_NUM": "1"}`),
+	})
+	outPath := filepath.Join(t.TempDir(), "stations.json")
+
+	errOut := new(bytes.Buffer)
+	c := &CLI{OutStream: new(bytes.Buffer), ErrStream: errOut}
+	args := []string{"kafun", "stations", "build", "-o", outPath, dir, filepath.Join(extra, "extra.jsonl")}
+	if got := c.Run(args); got != ExitCodeOK {
+		t.Fatalf("Run() return code = %v, want %v: %s", got, ExitCodeOK, errOut.String())
+	}
+	if want := "4 stations found in data, 4 stations in catalog (3 added)\n"; errOut.String() != want {
+		t.Errorf("Run() errout = %q, want %q", errOut.String(), want)
+	}
+
+	f, err := os.Open(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	catalog, err := LoadStationCatalog(f)
+	if err != nil {
+		t.Fatalf("LoadStationCatalog() error = %v", err)
+	}
+
+	// 組み込みの一覧の項目は測定データにない項目も残る
+	shinjuku, _ := catalog.Lookup("51320100")
+	if shinjuku.Name != "新宿区役所第二分庁舎" || shinjuku.AMeDASCode != "44132" {
+		t.Errorf("merged station = %+v", shinjuku)
+	}
+	if s, ok := catalog.Lookup("00000002"); !ok || s.Name != "テスト観測所2" {
+		t.Errorf("Lookup(00000002) = %+v, %v", s, ok)
+	}
+
+	// 存在しないパスはエラー
+	errOut.Reset()
+	if got := c.Run([]string{"kafun", "stations", "build", filepath.Join(dir, "none")}); got != ExitCodeStationError {
+		t.Errorf("Run() return code = %v, want %v", got, ExitCodeStationError)
+	}
+	if !strings.Contains(errOut.String(), "failed to read") {
+		t.Errorf("Run() errout = %q", errOut.String())
+	}
+}
+
+func TestCLI_runStations_buildFetch(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	requested := make(map[string]bool)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := r.URL.Query().Get("TDFKN_CD")
+		mu.Lock()
+		requested[code] = true
+		mu.Unlock()
+		if r.URL.Query().Get("Start_YM") == "209912" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body := fmt.Sprintf(`[{"SKT_CD": "5%s00000", "SKT_NM": "テスト観測所%s", "SKT_NNGP": "20210301", "SKT_HH": "1",
+			"TDFKN_CD": "%s", "KFN_NUM": "1"}]`, code, code, code)
+		sjisStr, _ := encodeUTF8ToSJIS(t, []byte(body))
+		w.WriteHeader(http.StatusOK)
+		w.Write(sjisStr)
+	}))
+	defer testServer.Close()
+	dir := writeArchiveFixture(t, map[string][]byte{"empty.json": []byte("[]")})
+
+	stdOut, errOut := new(bytes.Buffer), new(bytes.Buffer)
+	c := &CLI{OutStream: stdOut, ErrStream: errOut, Endpoint: testServer.URL}
+	args := []string{"kafun", "stations", "build", "-catalog", filepath.Join(dir, "empty.json"), "-startYM", "202103"}
+	if got := c.Run(args); got != ExitCodeOK {
+		t.Fatalf("Run() return code = %v, want %v: %s", got, ExitCodeOK, errOut.String())
+	}
+	if want := "47 stations found in data, 47 stations in catalog (47 added)\n"; errOut.String() != want {
+		t.Errorf("Run() errout = %q, want %q", errOut.String(), want)
+	}
+	if len(requested) != 47 {
+		t.Errorf("Run() requested prefectures = %d, want 47", len(requested))
+	}
+
+	catalog, err := LoadStationCatalog(stdOut)
+	if err != nil {
+		t.Fatalf("LoadStationCatalog() error = %v", err)
+	}
+	if s, ok := catalog.Lookup("54700000"); !ok || s.TodofukenCode != "47" {
+		t.Errorf("Lookup(54700000) = %+v, %v", s, ok)
+	}
+
+	// APIの取得に失敗した場合は一覧を出力しない
+	stdOut.Reset()
+	errOut.Reset()
+	args = []string{"kafun", "stations", "build", "-startYM", "209912"}
+	if got := c.Run(args); got != ExitCodeAPIRequestError {
+		t.Errorf("Run() return code = %v, want %v", got, ExitCodeAPIRequestError)
+	}
+	if stdOut.Len() != 0 || !strings.HasPrefix(errOut.String(), "failed to fetch 北海道 (01)") {
+		t.Errorf("Run() stdout = %q, errout = %q", stdOut.String(), errOut.String())
+	}
+}
@@ -0,0 +1,255 @@
+package kafun
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// runStats は stats サブコマンドを実行する。
+//
+//	kafun stats -startYM YYYYMM [-endYM YYYYMM] -todofukenCode PREF [検索のオプション]
+//	    [-by station|prefecture] [-period day|week|month|season]
+//	    [-format table|json|csv] [-noHeader] [-width N]
+func (c *CLI) runStats(args []string) int {
+	var f cliFlags
+	var group, period string
+
+	flags := flag.NewFlagSet("kafun stats", flag.ContinueOnError)
+	flags.SetOutput(c.ErrStream)
+	f.setSearchFlags(flags)
+	flags.StringVar(
+		&group,
+		"by",
+		string(GroupStation),
+		"集計する単位 (station: 測定局, prefecture: 都道府県)",
+	)
+	flags.StringVar(
+		&period,
+		"period",
+		string(PeriodDay),
+		"集計する期間 (day: 日, week: 月曜日からの週, month: 月, season: 春3〜5月, 夏6〜8月, 秋9〜11月, 冬12〜2月)",
+	)
+	flags.StringVar(
+		&f.output.format,
+		"format",
+		FormatTable,
+		"出力形式 (table, json, csv)",
+	)
+	flags.BoolVar(
+		&f.output.noHeader,
+		"noHeader",
+		false,
+		"table, csv でヘッダ行を出力しない",
+	)
+	flags.IntVar(
+		&f.output.width,
+		"width",
+		0,
+		"table で切り詰める表示幅。0の場合は切り詰めない (default: 端末の表示幅ないしは環境変数 COLUMNS)",
+	)
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return ExitCodeParseFlagError
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprintf(c.ErrStream, "Usage: kafun stats -startYM YYYYMM -todofukenCode PREF [options]\n")
+		return ExitCodeParseFlagError
+	}
+
+	switch StatsGroup(group) {
+	case GroupStation, GroupPrefecture:
+	default:
+		fmt.Fprintf(c.ErrStream, "invalid by: %s\n", group)
+		return ExitCodeParseFlagError
+	}
+	switch StatsPeriod(period) {
+	case PeriodDay, PeriodWeek, PeriodMonth, PeriodSeason:
+	default:
+		fmt.Fprintf(c.ErrStream, "invalid period: %s\n", period)
+		return ExitCodeParseFlagError
+	}
+	if _, err := summaryOutputThresholds(&f.output); err != nil {
+		fmt.Fprintf(c.ErrStream, "failed to initialize output: %v\n", err)
+		return ExitCodeParseFlagError
+	}
+	if !isFlagSet(flags, "width") {
+		f.output.width = terminalWidth(c.OutStream)
+	}
+
+	source, param, code := c.prepareSearch(&f)
+	if code != ExitCodeOK {
+		return code
+	}
+	data, code := c.fetch(&f, source, param)
+	if code != ExitCodeOK {
+		return code
+	}
+
+	stats, err := data.Aggregate(StatsGroup(group), StatsPeriod(period))
+	if err != nil {
+		fmt.Fprintf(c.ErrStream, "%v\n", err)
+		return ExitCodeParseFlagError
+	}
+
+	if err := writeStats(c.OutStream, stats, &f.output); err != nil {
+		fmt.Fprintf(c.ErrStream, "failed to write output: %v\n", err)
+		return ExitCodeOutputError
+	}
+
+	return ExitCodeOK
+}
+
+// statsRecord は集計の出力の1行。CSVの見出しはJSONキーに項目の集計の名前を付けたもの (KFN_NUM_sum など) を使う。
+type statsRecord struct {
+	Group         string            `json:"group"`
+	Code          string            `json:"code"`
+	Name          string            `json:"name"`
+	TodofukenCode string            `json:"TDFKN_CD"`
+	Period        string            `json:"period"`
+	Start         string            `json:"start"`
+	End           string            `json:"end"` // 期間の最後の日
+	Stations      int               `json:"stations"`
+	KafunNum      *statsFieldRecord `json:"KFN_NUM"`
+	Temperature   *statsFieldRecord `json:"AMeDAS_TP"`
+	Precipitation *statsFieldRecord `json:"AMeDAS_PR"`
+}
+
+// statsFieldRecord は項目の集計の出力。値のある時間がない場合は mean と max が null になる。
+type statsFieldRecord struct {
+	Sum         float64  `json:"sum"`
+	Mean        *float64 `json:"mean"`
+	Max         *float64 `json:"max"`
+	MaxNengappi string   `json:"max_SKT_NNGP,omitempty"`
+	MaxJikoku   string   `json:"max_SKT_HH,omitempty"`
+	Hours       int      `json:"hours"`
+	Expected    int      `json:"expected"`
+	Coverage    float64  `json:"coverage"`
+}
+
+// statsFieldColumns は項目の集計のCSVの見出しの接尾辞。statsFieldRecord.values の順。
+var statsFieldColumns = []string{"sum", "mean", "max", "max_SKT_NNGP", "max_SKT_HH", "hours", "expected", "coverage"}
+
+func newStatsFieldRecord(f *FieldStats) *statsFieldRecord {
+	r := &statsFieldRecord{
+		Sum:      roundStats(f.Sum),
+		Hours:    f.Hours,
+		Expected: f.Expected,
+		Coverage: math.Round(f.Coverage()*1000) / 1000,
+	}
+	if mean, ok := f.Mean(); ok {
+		mean = roundStats(mean)
+		peak := f.Max
+		r.Mean = &mean
+		r.Max = &peak
+		r.MaxNengappi, r.MaxJikoku = sokuteiSlot(f.MaxTime)
+	}
+
+	return r
+}
+
+// values は statsFieldColumns の順の値を返す。値がない場合は空文字列になる。
+func (r *statsFieldRecord) values() []string {
+	return []string{
+		strconv.FormatFloat(r.Sum, 'f', -1, 64),
+		formatOptionalFloat(r.Mean, -1),
+		formatOptionalFloat(r.Max, -1),
+		r.MaxNengappi,
+		r.MaxJikoku,
+		strconv.Itoa(r.Hours),
+		strconv.Itoa(r.Expected),
+		strconv.FormatFloat(r.Coverage, 'f', -1, 64),
+	}
+}
+
+// roundStats は合計や平均値の浮動小数点数の誤差を小数点以下3桁に丸める。
+func roundStats(f float64) float64 {
+	return math.Round(f*1000) / 1000
+}
+
+// formatOptionalFloat は f を prec 桁で文字列にする。nil の場合は空文字列を返す。
+func formatOptionalFloat(f *float64, prec int) string {
+	if f == nil {
+		return ""
+	}
+
+	return strconv.FormatFloat(*f, 'f', prec, 64)
+}
+
+// writeStats は集計を出力形式に応じて出力する。
+func writeStats(w io.Writer, stats []*Stats, opts *outputOptions) error {
+	report := &summaryReport{
+		columns: []*tableColumn{
+			{Title: "コード"},
+			{Title: "名前", truncatable: true},
+			{Title: "期間"},
+			{Title: "測定局数", alignRight: true},
+			{Title: "花粉数合計", alignRight: true},
+			{Title: "花粉数平均", alignRight: true},
+			{Title: "花粉数最大", alignRight: true},
+			{Title: "最大の日時"},
+			{Title: "カバー率", alignRight: true},
+			{Title: "平均気温", alignRight: true},
+			{Title: "最高気温", alignRight: true},
+			{Title: "降水量合計", alignRight: true},
+		},
+		header: []string{"group", "code", "name", "TDFKN_CD", "period", "start", "end", "stations"},
+	}
+	for _, field := range []string{"KFN_NUM", "AMeDAS_TP", "AMeDAS_PR"} {
+		for _, column := range statsFieldColumns {
+			report.header = append(report.header, field+"_"+column)
+		}
+	}
+
+	records := make([]*statsRecord, len(stats))
+	for i, s := range stats {
+		r := &statsRecord{
+			Group:         string(s.Group),
+			Code:          s.Code,
+			Name:          s.Name,
+			TodofukenCode: s.TodofukenCode,
+			Period:        s.Label(),
+			Start:         formatDate(s.Start),
+			End:           formatDate(s.End.AddDate(0, 0, -1)),
+			Stations:      s.Stations,
+			KafunNum:      newStatsFieldRecord(&s.KafunNum),
+			Temperature:   newStatsFieldRecord(&s.Temperature),
+			Precipitation: newStatsFieldRecord(&s.Precipitation),
+		}
+		records[i] = r
+
+		row := []string{r.Group, r.Code, r.Name, r.TodofukenCode, r.Period, r.Start, r.End, strconv.Itoa(r.Stations)}
+		row = append(row, r.KafunNum.values()...)
+		row = append(row, r.Temperature.values()...)
+		row = append(row, r.Precipitation.values()...)
+		report.rows = append(report.rows, row)
+
+		maxTime := "-"
+		if r.KafunNum.Max != nil {
+			maxTime = r.KafunNum.MaxNengappi + " " + r.KafunNum.MaxJikoku
+		}
+		precipitation := "-"
+		if r.Precipitation.Hours != 0 {
+			precipitation = strconv.FormatFloat(r.Precipitation.Sum, 'f', -1, 64)
+		}
+		report.tableRows = append(report.tableRows, []string{
+			r.Code,
+			orDash(r.Name),
+			r.Period,
+			strconv.Itoa(r.Stations),
+			strconv.FormatFloat(r.KafunNum.Sum, 'f', -1, 64),
+			orDash(formatOptionalFloat(r.KafunNum.Mean, 1)),
+			orDash(formatOptionalFloat(r.KafunNum.Max, -1)),
+			maxTime,
+			strconv.FormatFloat(s.KafunNum.Coverage()*100, 'f', 1, 64) + "%",
+			orDash(formatOptionalFloat(r.Temperature.Mean, 1)),
+			orDash(formatOptionalFloat(r.Temperature.Max, 1)),
+			precipitation,
+		})
+	}
+	report.records = records
+
+	return report.write(w, opts)
+}
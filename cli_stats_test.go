@@ -0,0 +1,120 @@
+package kafun
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestCLI_runStats(t *testing.T) {
+	t.Parallel()
+	from := time.Date(2021, 2, 1, 0, 0, 0, 0, jst)
+	data := append(dailyFixture(t, "1", from, 1, 2, 3, 4, 5, 6, 7), dailyFixture(t, "2", from, make([]int, 7)...)...)
+	dir := writeArchiveFixture(t, map[string][]byte{"2021.jsonl": archiveJSONL(t, data)})
+	search := []string{"-startYM", "202102", "-endYM", "202102", "-todofukenCode", "13", "-archive", dir}
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantErrout string
+	}{
+		{
+			name:     "standard case: station by week table",
+			args:     []string{"-period", "week", "-width", "0"},
+			wantCode: ExitCodeOK,
+			wantStdout: "コード  名前           期間      測定局数  花粉数合計  花粉数平均  花粉数最大  最大の日時   カバー率  平均気温  最高気温  降水量合計\n" +
+				"1       テスト観測所1  2021-W05         1         672         4.0           7  20210207 01    100.0%         -         -           -\n" +
+				"2       テスト観測所2  2021-W05         1           0         0.0           0  20210201 01    100.0%         -         -           -\n",
+		},
+		{
+			name:     "standard case: prefecture by month csv",
+			args:     []string{"-by", "prefecture", "-period", "month", "-format", "csv"},
+			wantCode: ExitCodeOK,
+			wantStdout: "group,code,name,TDFKN_CD,period,start,end,stations," +
+				"KFN_NUM_sum,KFN_NUM_mean,KFN_NUM_max,KFN_NUM_max_SKT_NNGP,KFN_NUM_max_SKT_HH,KFN_NUM_hours,KFN_NUM_expected,KFN_NUM_coverage," +
+				"AMeDAS_TP_sum,AMeDAS_TP_mean,AMeDAS_TP_max,AMeDAS_TP_max_SKT_NNGP,AMeDAS_TP_max_SKT_HH,AMeDAS_TP_hours,AMeDAS_TP_expected,AMeDAS_TP_coverage," +
+				"AMeDAS_PR_sum,AMeDAS_PR_mean,AMeDAS_PR_max,AMeDAS_PR_max_SKT_NNGP,AMeDAS_PR_max_SKT_HH,AMeDAS_PR_hours,AMeDAS_PR_expected,AMeDAS_PR_coverage\n" +
+				"prefecture,13,東京都,13,2021-02,2021-02-01,2021-02-28,2,672,2,7,20210207,01,336,1344,0.25,0,,,,,0,1344,0,0,,,,,0,1344,0\n",
+		},
+		{
+			name:     "standard case: station by season json",
+			args:     []string{"-period", "season", "-format", "json", "-sokuteikyokuCode", "1"},
+			wantCode: ExitCodeOK,
+			wantStdout: `[
+	{
+		"group": "station",
+		"code": "1",
+		"name": "テスト観測所1",
+		"TDFKN_CD": "13",
+		"period": "2020-winter",
+		"start": "2020-12-01",
+		"end": "2021-02-28",
+		"stations": 1,
+		"KFN_NUM": {
+			"sum": 672,
+			"mean": 4,
+			"max": 7,
+			"max_SKT_NNGP": "20210207",
+			"max_SKT_HH": "01",
+			"hours": 168,
+			"expected": 2160,
+			"coverage": 0.078
+		},
+		"AMeDAS_TP": {
+			"sum": 0,
+			"mean": null,
+			"max": null,
+			"hours": 0,
+			"expected": 2160,
+			"coverage": 0
+		},
+		"AMeDAS_PR": {
+			"sum": 0,
+			"mean": null,
+			"max": null,
+			"hours": 0,
+			"expected": 2160,
+			"coverage": 0
+		}
+	}
+]
+`,
+		},
+		{
+			name:       "error case: unknown group",
+			args:       []string{"-by", "city"},
+			wantCode:   ExitCodeParseFlagError,
+			wantErrout: "invalid by: city\n",
+		},
+		{
+			name:       "error case: unknown period",
+			args:       []string{"-period", "year"},
+			wantCode:   ExitCodeParseFlagError,
+			wantErrout: "invalid period: year\n",
+		},
+		{
+			name:       "error case: unknown format",
+			args:       []string{"-format", "ndjson"},
+			wantCode:   ExitCodeParseFlagError,
+			wantErrout: "failed to initialize output: unknown output format: ndjson\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outStream, errOut := new(bytes.Buffer), new(bytes.Buffer)
+			c := &CLI{OutStream: outStream, ErrStream: errOut}
+			args := append(append([]string{"kafun", "stats"}, search...), tt.args...)
+			if got := c.Run(args); got != tt.wantCode {
+				t.Errorf("Run() return code = %v, want %v: %s", got, tt.wantCode, errOut.String())
+			}
+			if outStream.String() != tt.wantStdout {
+				t.Errorf("Run() stdout = %q, want %q", outStream.String(), tt.wantStdout)
+			}
+			if errOut.String() != tt.wantErrout {
+				t.Errorf("Run() errout = %q, want %q", errOut.String(), tt.wantErrout)
+			}
+		})
+	}
+}
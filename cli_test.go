@@ -2,9 +2,14 @@ package kafun
 
 import (
 	"bytes"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -28,6 +33,7 @@ const testSokuteiDataJSONStringOptional = `[
 var removePattern = regexp.MustCompile(`\s+|\t|\n`)
 
 func TestCLI_Run(t *testing.T) {
+	t.Parallel()
 	sjisStr, _ := encodeUTF8ToSJIS(t, sokuteiDataByteOmitOptional)
 	type fields struct {
 		mockServerHandlerFunc func(w http.ResponseWriter, r *http.Request)
@@ -58,9 +64,9 @@ func TestCLI_Run(t *testing.T) {
 				[]string{
 					"kafun",
 					"-startYM",
-					"000000",
+					"202102",
 					"-endYM",
-					"000001",
+					"202102",
 					"-todofukenCode",
 					"01",
 					"-sokuteikyokuCode",
@@ -73,18 +79,210 @@ func TestCLI_Run(t *testing.T) {
 				errout:     "",
 			},
 		},
+		{
+			name: "standard case: csv format without header",
+			fields: fields{
+				mockServerHandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+					w.Write(sjisStr)
+				},
+			},
+			args: args{
+				[]string{
+					"kafun",
+					"-startYM",
+					"202102",
+					"-todofukenCode",
+					"01",
+					"-format",
+					"csv",
+					"-noHeader",
+				},
+			},
+			want: want{
+				returnCode: ExitCodeOK,
+				stdout:     "00000000,00000,00000000,00,テスト観測所,0,00,テスト都道府県,000000,テスト市町村,0,00,,,,\n",
+				errout:     "",
+			},
+		},
+		{
+			name: "standard case: table format truncated to width",
+			fields: fields{
+				mockServerHandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+					w.Write(sjisStr)
+				},
+			},
+			args: args{
+				[]string{
+					"kafun",
+					"-startYM",
+					"202102",
+					"-todofukenCode",
+					"01",
+					"-format",
+					"table",
+					"-width",
+					"30",
+				},
+			},
+			want: want{
+				returnCode: ExitCodeOK,
+				stdout: "日付      時  測定局  花粉数\n" +
+					"00000000  00  テス…        0\n",
+				errout: "",
+			},
+		},
+		{
+			name: "standard case: selected fields with go names",
+			fields: fields{
+				mockServerHandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+					w.Write(sjisStr)
+				},
+			},
+			args: args{
+				[]string{
+					"kafun",
+					"-startYM",
+					"202102",
+					"-todofukenCode",
+					"01",
+					"-format",
+					"ndjson",
+					"-fields",
+					"SKT_NNGP,SKT_HH,KFN_NUM",
+					"-fieldNames",
+					"go",
+				},
+			},
+			want: want{
+				returnCode: ExitCodeOK,
+				stdout:     `{"SokuteiNengappi":"00000000","SokuteiJikoku":"00","KafunNum":0}` + "\n",
+				errout:     "",
+			},
+		},
+		{
+			name: "error case: unknown field",
+			args: args{
+				[]string{
+					"kafun",
+					"-startYM",
+					"202102",
+					"-todofukenCode",
+					"01",
+					"-fields",
+					"POLLEN",
+				},
+			},
+			want: want{
+				returnCode: ExitCodeParseFlagError,
+				stdout:     "",
+				errout:     "failed to initialize output: unknown output field: POLLEN\n",
+			},
+		},
+		{
+			name: "standard case: prefecture by romaji",
+			fields: fields{
+				mockServerHandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+					if got := r.URL.Query().Get("TDFKN_CD"); got != "13" {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					w.WriteHeader(http.StatusOK)
+					w.Write(sjisStr)
+				},
+			},
+			args: args{
+				[]string{
+					"kafun",
+					"-startYM",
+					"202102",
+					"-todofukenCode",
+					"Tokyo",
+					"-format",
+					"csv",
+					"-fields",
+					"SKT_CD",
+					"-noHeader",
+				},
+			},
+			want: want{
+				returnCode: ExitCodeOK,
+				stdout:     "00000000\n",
+				errout:     "",
+			},
+		},
+		{
+			name: "error case: unknown prefecture",
+			args: args{
+				[]string{
+					"kafun",
+					"-startYM",
+					"202102",
+					"-todofukenCode",
+					"atlantis",
+				},
+			},
+			want: want{
+				returnCode: ExitCodeValidationError,
+				stdout:     "",
+				errout:     "invalid todofukenCode: unknown prefecture: atlantis\n",
+			},
+		},
+		{
+			name: "error case: month 00 is not a year month",
+			args: args{
+				[]string{
+					"kafun",
+					"-startYM",
+					"000000",
+					"-endYM",
+					"000001",
+					"-todofukenCode",
+					"01",
+				},
+			},
+			want: want{
+				returnCode: ExitCodeValidationError,
+				stdout:     "",
+				errout: "failed to request to API with args startYM=000000, endYM=000001, todofukenCode=01, sokuteikyokuCode=: " +
+					"invalid search parameter: StartYM: invalid year month: 000000\n",
+			},
+		},
+		{
+			name: "error case: unknown format",
+			args: args{
+				[]string{
+					"kafun",
+					"-startYM",
+					"202102",
+					"-todofukenCode",
+					"01",
+					"-format",
+					"xml",
+				},
+			},
+			want: want{
+				returnCode: ExitCodeParseFlagError,
+				stdout:     "",
+				errout:     "failed to initialize output: unknown output format: xml\n",
+			},
+		},
 	}
 	for _, tt := range tests {
+		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testServer := httptest.NewServer(http.HandlerFunc(tt.fields.mockServerHandlerFunc))
+			defer testServer.Close()
 			stdOut := new(bytes.Buffer)
 			errOut := new(bytes.Buffer)
 			c := &CLI{
 				OutStream: stdOut,
 				ErrStream: errOut,
+				Endpoint:  testServer.URL,
 			}
-			testServer := httptest.NewServer(http.HandlerFunc(tt.fields.mockServerHandlerFunc))
-			defer testServer.Close()
-			DefaultEndpoint = testServer.URL
 
 			if got := c.Run(tt.args.args); got != tt.want.returnCode {
 				t.Errorf("Run() return code = %v, want %v", got, tt.want.returnCode)
@@ -98,3 +296,470 @@ func TestCLI_Run(t *testing.T) {
 		})
 	}
 }
+
+func Test_exitCodeFromError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{
+			name: "standard case: validation error",
+			err:  &ValidationError{Fields: map[string]string{"StartYM": "failed on 'required' rule"}},
+			want: ExitCodeValidationError,
+		},
+		{
+			name: "standard case: wrapped decode error",
+			err:  &SubSearchError{Param: validSearchParam, Err: &DecodeError{Index: 0, Field: "KFN_NUM"}},
+			want: ExitCodeDecodeError,
+		},
+		{
+			name: "standard case: decode error in partial failure",
+			err: &PartialSearchError{Errors: []*SubSearchError{
+				{Param: validSearchParam, Err: &APIError{StatusCode: http.StatusInternalServerError}},
+				{Param: validSearchParam, Err: &DecodeError{Index: 0, Field: "KFN_NUM"}},
+			}},
+			want: ExitCodeDecodeError,
+		},
+		{
+			name: "standard case: api error",
+			err:  &SubSearchError{Param: validSearchParam, Err: &APIError{StatusCode: http.StatusBadRequest}},
+			want: ExitCodeAPIRequestError,
+		},
+		{
+			name: "standard case: transport error",
+			err:  http.ErrHandlerTimeout,
+			want: ExitCodeAPIRequestError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCodeFromError(tt.err); got != tt.want {
+				t.Errorf("exitCodeFromError() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCLI_Run_parallel(t *testing.T) {
+	t.Parallel()
+	testServer := httptest.NewServer(monthlyHandler(t, ""))
+	t.Cleanup(testServer.Close)
+
+	for _, code := range []string{"01", "13", "47"} {
+		code := code
+		t.Run(code, func(t *testing.T) {
+			t.Parallel()
+			stdOut := new(bytes.Buffer)
+			c := &CLI{
+				OutStream: stdOut,
+				ErrStream: new(bytes.Buffer),
+				Endpoint:  testServer.URL,
+			}
+
+			args := []string{"kafun", "-startYM", "202102", "-todofukenCode", code}
+			if got := c.Run(args); got != ExitCodeOK {
+				t.Fatalf("Run() return code = %v, want %v", got, ExitCodeOK)
+			}
+			if want := `"TDFKN_CD": "` + code + `"`; !strings.Contains(stdOut.String(), want) {
+				t.Errorf("Run() stdout = %v, want contains %v", stdOut.String(), want)
+			}
+		})
+	}
+}
+
+func TestCLI_Run_clientFactory(t *testing.T) {
+	t.Parallel()
+	var gotEndpoint string
+	errOut := new(bytes.Buffer)
+	c := &CLI{
+		OutStream: new(bytes.Buffer),
+		ErrStream: errOut,
+		Endpoint:  "http://example.com/api",
+		NewClient: func(endpoint string) (*Client, error) {
+			gotEndpoint = endpoint
+			return nil, errors.New("factory error")
+		},
+	}
+
+	args := []string{"kafun", "-startYM", "202102", "-todofukenCode", "13"}
+	if got := c.Run(args); got != ExitCodeInitializeError {
+		t.Errorf("Run() return code = %v, want %v", got, ExitCodeInitializeError)
+	}
+	if gotEndpoint != c.Endpoint {
+		t.Errorf("Run() endpoint = %v, want %v", gotEndpoint, c.Endpoint)
+	}
+	if !strings.Contains(errOut.String(), "factory error") {
+		t.Errorf("Run() errout = %v, want contains factory error", errOut.String())
+	}
+}
+
+func TestCLI_Run_emptyResult(t *testing.T) {
+	t.Parallel()
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("[]"))
+	}))
+	defer testServer.Close()
+
+	stdOut, errOut := new(bytes.Buffer), new(bytes.Buffer)
+	c := &CLI{OutStream: stdOut, ErrStream: errOut, Endpoint: testServer.URL}
+	if got := c.Run([]string{"kafun", "-startYM", "202102", "-todofukenCode", "13"}); got != ExitCodeOK {
+		t.Fatalf("Run() return code = %v, want %v: %s", got, ExitCodeOK, errOut.String())
+	}
+	// 測定データがない場合は null ではなく空の配列を出力する
+	if stdOut.String() != "[]" {
+		t.Errorf("Run() stdout = %q, want %q", stdOut.String(), "[]")
+	}
+}
+
+// syncBuffer は並行して読み書きできる bytes.Buffer。
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestCLI_Run_ndjsonStreaming(t *testing.T) {
+	t.Parallel()
+	stdOut := &syncBuffer{}
+	handler := monthlyHandler(t, "")
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 2か月目のリクエストの時点で1か月目のデータが出力されている
+		if r.URL.Query().Get("Start_YM") == "202103" && !strings.Contains(stdOut.String(), `"SKT_NNGP":"20210201"`) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(testServer.Close)
+
+	c := &CLI{
+		OutStream: stdOut,
+		ErrStream: new(bytes.Buffer),
+		Endpoint:  testServer.URL,
+	}
+	args := []string{"kafun", "-startYM", "202102", "-endYM", "202103", "-todofukenCode", "13", "-format", "ndjson"}
+	if got := c.Run(args); got != ExitCodeOK {
+		t.Fatalf("Run() return code = %v, want %v", got, ExitCodeOK)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdOut.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], `"SKT_NNGP":"20210301"`) {
+		t.Errorf("Run() stdout = %v, want 2 lines in month order", stdOut.String())
+	}
+}
+
+func Test_terminalWidth(t *testing.T) {
+	tests := []struct {
+		columns string
+		want    int
+	}{
+		{columns: "120", want: 120},
+		{columns: "", want: 0},
+		{columns: "wide", want: 0},
+		{columns: "-1", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.columns, func(t *testing.T) {
+			t.Setenv("COLUMNS", tt.columns)
+			if got := terminalWidth(new(bytes.Buffer)); got != tt.want {
+				t.Errorf("terminalWidth() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_terminalWidth_notTerminal(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// 端末でないファイルへの出力では COLUMNS を使う
+	t.Setenv("COLUMNS", "100")
+	if got := terminalWidth(f); got != 100 {
+		t.Errorf("terminalWidth() = %v, want %v", got, 100)
+	}
+}
+
+func TestCLI_Run_stationName(t *testing.T) {
+	t.Parallel()
+	dir := writeArchiveFixture(t, map[string][]byte{
+		"stations.json": []byte(`[
+			{"SKT_CD": "00000001", "SKT_NM": "テスト観測所1", "TDFKN_CD": "13"},
+			{"SKT_CD": "00000002", "SKT_NM": "テスト観測所2", "TDFKN_CD": "13"},
+			{"SKT_CD": "00000003", "SKT_NM": "別の観測所", "TDFKN_CD": "14"}
+		]`),
+	})
+	catalog := filepath.Join(dir, "stations.json")
+
+	var gotStations []string
+	var mu sync.Mutex
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		gotStations = append(gotStations, r.URL.Query().Get("SKT_CD"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("[]"))
+	}))
+	t.Cleanup(testServer.Close)
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantErrout string
+		wantCodes  string
+	}{
+		{
+			name:      "standard case: embedded catalog",
+			args:      []string{"-todofukenCode", "13", "-sokuteikyokuCode", "新宿区役所第二分庁舎"},
+			wantCode:  ExitCodeOK,
+			wantCodes: "51320100",
+		},
+		{
+			name:      "standard case: names and codes",
+			args:      []string{"-todofukenCode", "13", "-catalog", catalog, "-sokuteikyokuCode", "テスト観測所2,00000009"},
+			wantCode:  ExitCodeOK,
+			wantCodes: "00000002,00000009",
+		},
+		{
+			name:     "error case: ambiguous name",
+			args:     []string{"-todofukenCode", "13", "-catalog", catalog, "-sokuteikyokuCode", "テスト"},
+			wantCode: ExitCodeValidationError,
+			wantErrout: "invalid sokuteikyokuCode: station name テスト is ambiguous: " +
+				"did you mean 00000001 テスト観測所1, 00000002 テスト観測所2?\n",
+		},
+		{
+			name:     "error case: prefecture only match is not resolved",
+			args:     []string{"-todofukenCode", "13", "-sokuteikyokuCode", "tokyo"},
+			wantCode: ExitCodeValidationError,
+			wantErrout: "invalid sokuteikyokuCode: station name tokyo is ambiguous: " +
+				"did you mean 51320100 新宿区役所第二分庁舎?\n",
+		},
+		{
+			name:       "error case: station in other prefecture",
+			args:       []string{"-todofukenCode", "13", "-catalog", catalog, "-sokuteikyokuCode", "別の観測所"},
+			wantCode:   ExitCodeValidationError,
+			wantErrout: "invalid sokuteikyokuCode: no station matches 別の観測所\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			gotStations = nil
+			mu.Unlock()
+
+			errOut := new(bytes.Buffer)
+			c := &CLI{OutStream: new(bytes.Buffer), ErrStream: errOut, Endpoint: testServer.URL}
+			args := append([]string{"kafun", "-startYM", "202102"}, tt.args...)
+			if got := c.Run(args); got != tt.wantCode {
+				t.Errorf("Run() return code = %v, want %v: %s", got, tt.wantCode, errOut.String())
+			}
+			if errOut.String() != tt.wantErrout {
+				t.Errorf("Run() errout = %q, want %q", errOut.String(), tt.wantErrout)
+			}
+			if len(tt.wantCodes) != 0 && (len(gotStations) != 1 || gotStations[0] != tt.wantCodes) {
+				t.Errorf("Run() requested SKT_CD = %v, want %v", gotStations, tt.wantCodes)
+			}
+		})
+	}
+}
+
+func TestCLI_Run_decode(t *testing.T) {
+	t.Parallel()
+	dir := writeArchiveFixture(t, map[string][]byte{
+		"2021.jsonl": []byte(`{"SKT_CD": "00000001", "TDFKN_CD": "13", "SKT_NNGP": "20210201", "SKT_HH": "1", "KFN_NUM": "-"}
+{"SKT_CD": "00000001", "TDFKN_CD": "13", "SKT_NNGP": "20210201", "SKT_HH": "2", "KFN_NUM": "5"}
+`),
+	})
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantOut    string
+		wantErrout string
+	}{
+		{
+			name:     "error case: strict mode by default",
+			args:     []string{},
+			wantCode: ExitCodeDecodeError,
+			wantErrout: "failed to request to API with args startYM=202102, endYM=, todofukenCode=13, sokuteikyokuCode=: " +
+				"failed to decode archive file: " + filepath.Join(dir, "2021.jsonl") + ": " +
+				`failed to decode record 0 field KFN_NUM: strconv.Atoi: parsing "-": invalid syntax` + "\n",
+		},
+		{
+			name:     "standard case: lenient mode warns and keeps going",
+			args:     []string{"-decode", "lenient"},
+			wantCode: ExitCodeOK,
+			wantOut:  "20210201,1,0\n20210201,2,5\n",
+			wantErrout: "warning: record SKT_CD=00000001 SKT_NNGP=20210201 SKT_HH=1: " +
+				`KFN_NUM: strconv.Atoi: parsing "-": invalid syntax` + "\n",
+		},
+		{
+			name:       "error case: unknown decode mode",
+			args:       []string{"-decode", "loose"},
+			wantCode:   ExitCodeParseFlagError,
+			wantErrout: "invalid decode: unknown decode mode: loose\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outStream, errOut := new(bytes.Buffer), new(bytes.Buffer)
+			c := &CLI{OutStream: outStream, ErrStream: errOut}
+			args := append([]string{
+				"kafun", "-startYM", "202102", "-todofukenCode", "13", "-archive", dir,
+				"-format", "csv", "-noHeader", "-fields", "SKT_NNGP,SKT_HH,KFN_NUM",
+			}, tt.args...)
+			if got := c.Run(args); got != tt.wantCode {
+				t.Errorf("Run() return code = %v, want %v: %s", got, tt.wantCode, errOut.String())
+			}
+			if outStream.String() != tt.wantOut {
+				t.Errorf("Run() stdout = %q, want %q", outStream.String(), tt.wantOut)
+			}
+			if errOut.String() != tt.wantErrout {
+				t.Errorf("Run() errout = %q, want %q", errOut.String(), tt.wantErrout)
+			}
+		})
+	}
+}
+
+func TestCLI_Run_fill(t *testing.T) {
+	t.Parallel()
+	dir := writeArchiveFixture(t, map[string][]byte{
+		"2021.jsonl": []byte(`{"SKT_CD": "00000001", "TDFKN_CD": "13", "SKT_NNGP": "20210201", "SKT_HH": "01", "KFN_NUM": "3", "AMeDAS_TP": "4.0"}
+{"SKT_CD": "00000001", "TDFKN_CD": "13", "SKT_NNGP": "20210201", "SKT_HH": "04", "KFN_NUM": "5", "AMeDAS_TP": "7.0"}
+`),
+	})
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantHead   string // 出力の最初の行
+		wantLines  int    // 出力の行数
+		wantErrout string
+	}{
+		{
+			name:     "standard case: filled rows are flagged",
+			args:     []string{"-fill", "kafun=zero,temperature=linear", "-format", "csv"},
+			wantCode: ExitCodeOK,
+			wantHead: "SKT_HH,KFN_NUM,AMeDAS_TP,filled\n" +
+				"01,3,4,false\n02,0,5,true\n03,0,6,true\n04,5,7,false\n05,0,,true\n",
+			wantLines: 1 + 28*24,
+		},
+		{
+			name:     "standard case: null leaves values empty",
+			args:     []string{"-fill", "null", "-format", "ndjson"},
+			wantCode: ExitCodeOK,
+			wantHead: `{"SKT_HH":"01","KFN_NUM":3,"AMeDAS_TP":4,"filled":false}` + "\n" +
+				`{"SKT_HH":"02","KFN_NUM":null,"filled":true}` + "\n" +
+				`{"SKT_HH":"03","KFN_NUM":null,"filled":true}` + "\n" +
+				`{"SKT_HH":"04","KFN_NUM":5,"AMeDAS_TP":7,"filled":false}` + "\n",
+			wantLines: 28 * 24,
+		},
+		{
+			name:       "error case: invalid fill policy",
+			args:       []string{"-fill", "kafun=linear"},
+			wantCode:   ExitCodeParseFlagError,
+			wantErrout: "invalid fill: invalid fill policy: linear is not supported for kafun\n",
+		},
+		{
+			name:       "error case: carry for kafun",
+			args:       []string{"-fill", "kafun=carry"},
+			wantCode:   ExitCodeParseFlagError,
+			wantErrout: "invalid fill: invalid fill policy: carry is not supported for kafun\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outStream, errOut := new(bytes.Buffer), new(bytes.Buffer)
+			c := &CLI{OutStream: outStream, ErrStream: errOut}
+			args := append([]string{
+				"kafun", "-startYM", "202102", "-endYM", "202102", "-todofukenCode", "13",
+				"-sokuteikyokuCode", "00000001", "-archive", dir, "-fields", "SKT_HH,KFN_NUM,AMeDAS_TP",
+			}, tt.args...)
+			if got := c.Run(args); got != tt.wantCode {
+				t.Errorf("Run() return code = %v, want %v: %s", got, tt.wantCode, errOut.String())
+			}
+			if !strings.HasPrefix(outStream.String(), tt.wantHead) {
+				t.Errorf("Run() stdout = %q, want prefix %q", outStream.String(), tt.wantHead)
+			}
+			if got := strings.Count(outStream.String(), "\n"); got != tt.wantLines {
+				t.Errorf("Run() stdout lines = %d, want %d", got, tt.wantLines)
+			}
+			if errOut.String() != tt.wantErrout {
+				t.Errorf("Run() errout = %q, want %q", errOut.String(), tt.wantErrout)
+			}
+		})
+	}
+}
+
+func TestCLI_Run_qc(t *testing.T) {
+	t.Parallel()
+	dir := writeArchiveFixture(t, map[string][]byte{
+		"2021.jsonl": []byte(`{"SKT_CD": "00000001", "TDFKN_CD": "13", "SKT_NNGP": "20210201", "SKT_HH": "01", "KFN_NUM": "3", "AMeDAS_TP": "4.0"}
+{"SKT_CD": "00000001", "TDFKN_CD": "13", "SKT_NNGP": "20210201", "SKT_HH": "04", "KFN_NUM": "5", "AMeDAS_TP": "7.0"}
+`),
+	})
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantHead   string // 出力の最初の行
+		wantLines  int    // 出力の行数
+		wantErrout string
+	}{
+		{
+			name:      "standard case: qc column",
+			args:      []string{"-qc", "-format", "csv"},
+			wantCode:  ExitCodeOK,
+			wantHead:  "SKT_HH,KFN_NUM,AMeDAS_TP,qc\n01,3,4,\n04,5,7,\n",
+			wantLines: 3,
+		},
+		{
+			name:      "standard case: filled rows are not checked",
+			args:      []string{"-fill", "kafun=zero", "-qc", "-format", "csv", "-noHeader"},
+			wantCode:  ExitCodeOK,
+			wantHead:  "01,3,4,false,\n02,0,,true,\n03,0,,true,\n04,5,7,false,\n05,0,,true,\n",
+			wantLines: 28 * 24,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outStream, errOut := new(bytes.Buffer), new(bytes.Buffer)
+			c := &CLI{OutStream: outStream, ErrStream: errOut}
+			args := append([]string{
+				"kafun", "-startYM", "202102", "-endYM", "202102", "-todofukenCode", "13",
+				"-sokuteikyokuCode", "00000001", "-archive", dir, "-fields", "SKT_HH,KFN_NUM,AMeDAS_TP",
+			}, tt.args...)
+			if got := c.Run(args); got != tt.wantCode {
+				t.Errorf("Run() return code = %v, want %v: %s", got, tt.wantCode, errOut.String())
+			}
+			if !strings.HasPrefix(outStream.String(), tt.wantHead) {
+				t.Errorf("Run() stdout = %q, want prefix %q", outStream.String(), tt.wantHead)
+			}
+			if got := strings.Count(outStream.String(), "\n"); got != tt.wantLines {
+				t.Errorf("Run() stdout lines = %d, want %d", got, tt.wantLines)
+			}
+			if errOut.String() != tt.wantErrout {
+				t.Errorf("Run() errout = %q, want %q", errOut.String(), tt.wantErrout)
+			}
+		})
+	}
+}
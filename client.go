@@ -58,11 +58,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
 	"runtime"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"golang.org/x/text/encoding/japanese"
@@ -80,18 +80,77 @@ var userAgent = fmt.Sprintf("KafunGoClient/%s (%s)", Version, runtime.Version())
 type SearchParam struct {
 	StartYM          string `validate:"required,numeric,len=6"`
 	EndYM            string `validate:"omitempty,numeric,len=6"`
-	TodofukenCode    string `validate:"required,numeric,gte=01,lte=47"`
+	TodofukenCode    string `validate:"required,todofuken"`
 	SokuteikyokuCode string `validate:"omitempty"`
 }
 
+// DataSource は測定データの取得元を表す。
+// APIクライアントの Client と、ダウンロード済みのファイルを読む Archive がある。
+type DataSource interface {
+	Search(ctx context.Context, param *SearchParam) (SokuteiData, error)
+}
+
+var _ DataSource = (*Client)(nil)
+
+// validateSearchParam は検索パラメータを検証する。
+// 都道府県コードは todofuken ルールで、2桁のJISの都道府県コード (01〜47) かどうかを検証する。
+func validateSearchParam(param *SearchParam) error {
+	validate := validator.New()
+	if err := validate.RegisterValidation("todofuken", func(fl validator.FieldLevel) bool {
+		return isPrefectureCode(fl.Field().String())
+	}); err != nil {
+		return err
+	}
+	if err := validate.Struct(param); err != nil {
+		return newValidationError(err)
+	}
+
+	return nil
+}
+
 // Client は環境庁花粉観測システムAPIのクライアントを表す。
 type Client struct {
 	URL        *url.URL
 	HTTPClient *http.Client
+
+	// Concurrency は分割リクエストの同時実行数。1未満の場合は1として扱う。
+	Concurrency int
+
+	// SplitByStation が true の場合、カンマ区切りで複数指定された測定局ごとにもリクエストを分割する。
+	SplitByStation bool
+
+	// Order は分割リクエストの結果を結合する順序。
+	Order MergeOrder
+
+	// AllowPartial が true の場合、一部の分割リクエストが失敗しても取得できたデータを
+	// *PartialSearchError とともに返す。false の場合は最初のエラーで残りのリクエストを中断する。
+	AllowPartial bool
+
+	// RetryPolicy は一時的な失敗に対する再試行の方針。nil の場合は再試行しない。
+	RetryPolicy RetryPolicy
+
+	// RateLimiter はすべてのリクエストで共有する流量制限。nil の場合は制限しない。
+	RateLimiter RateLimiter
+
+	// UserAgent はリクエストの User-Agent ヘッダ。空文字列の場合はデフォルトの値を使う。
+	UserAgent string
+
+	// Logger はリクエストや再試行のログの出力先。nil の場合はログを出力しない。
+	Logger Logger
+
+	// Cache は分割したリクエストごとの検索結果のキャッシュ。nil の場合はキャッシュしない。
+	Cache Cache
+
+	// DecodeMode はレスポンスの項目に不正な値があった場合の扱い。ゼロ値は DecodeStrict。
+	DecodeMode DecodeMode
+
+	// timeout は WithTimeout で指定されたタイムアウト。0の場合は HTTPClient のタイムアウトを変えない。
+	timeout time.Duration
 }
 
 // NewClient は新しいAPIクライアントを作成する。
-func NewClient(endpoint string) (*Client, error) {
+// opts でHTTPクライアントや再試行の方針などを指定できる。
+func NewClient(endpoint string, opts ...Option) (*Client, error) {
 	if len(endpoint) == 0 {
 		endpoint = DefaultEndpoint
 	}
@@ -101,10 +160,27 @@ func NewClient(endpoint string) (*Client, error) {
 		return nil, xerrors.Errorf("failed to parse url: %s: %v", endpoint, err)
 	}
 
-	return &Client{
-		URL:        parsedURL,
-		HTTPClient: http.DefaultClient,
-	}, nil
+	client := &Client{
+		URL:         parsedURL,
+		HTTPClient:  http.DefaultClient,
+		Concurrency: DefaultConcurrency,
+		UserAgent:   userAgent,
+	}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	if client.HTTPClient == nil {
+		client.HTTPClient = http.DefaultClient
+	}
+	if client.timeout > 0 {
+		httpClient := *client.HTTPClient
+		httpClient.Timeout = client.timeout
+		client.HTTPClient = &httpClient
+	}
+
+	return client, nil
 }
 
 func (c *Client) newRequest(
@@ -135,64 +211,157 @@ func (c *Client) newRequest(
 	}
 
 	req = req.WithContext(ctx)
-	req.Header.Set("User-Agent", userAgent)
+	if len(c.UserAgent) != 0 {
+		req.Header.Set("User-Agent", c.UserAgent)
+	} else {
+		req.Header.Set("User-Agent", userAgent)
+	}
 
 	return req, nil
 }
 
-func decodeBody(resp *http.Response, out interface{}) (err error) {
-	defer func(Body io.ReadCloser) {
-		err = Body.Close()
-	}(resp.Body)
+// decodeBody はAPIレスポンスのボディを SokuteiData にデコードする。
+// デコードに失敗した場合は失敗したレコードの位置を含む *DecodeError を返す。
+func decodeBody(resp *http.Response, mode DecodeMode) (SokuteiData, error) {
+	defer resp.Body.Close()
+
+	response := SokuteiData{}
+	err := decodeStream(resp.Body, mode, func(hsd *HourlySokuteiData) error {
+		response = append(response, hsd)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
 
+// decodeStream はShift-JISのAPIレスポンスを1レコードずつデコードして fn に渡す。
+// デコードに失敗した場合は *DecodeError を返し、fn がエラーを返した場合はそのエラーを返す。
+func decodeStream(body io.Reader, mode DecodeMode, fn func(*HourlySokuteiData) error) error {
 	// APIレスポンスがShift-JISなので、Goで扱えるようにUTF-8変換する。
-	reader := transform.NewReader(resp.Body, japanese.ShiftJIS.NewDecoder())
-	byteArray, err := ioutil.ReadAll(reader)
+	reader := transform.NewReader(body, japanese.ShiftJIS.NewDecoder())
+	decoder := json.NewDecoder(reader)
+
+	if err := expectDelim(decoder, '['); err != nil {
+		return &DecodeError{Index: -1, Err: err}
+	}
+
+	for index := 0; decoder.More(); index++ {
+		var record json.RawMessage
+		if err := decoder.Decode(&record); err != nil {
+			return wrapRecordDecodeError(index, err)
+		}
+
+		hsd, err := decodeRecord(record, mode)
+		if err != nil {
+			return wrapRecordDecodeError(index, err)
+		}
+
+		if err := fn(hsd); err != nil {
+			return err
+		}
+	}
+
+	if err := expectDelim(decoder, ']'); err != nil {
+		return &DecodeError{Index: -1, Err: err}
+	}
+
+	return nil
+}
+
+// expectDelim は次のトークンが delim であることを確認する。
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
 	if err != nil {
-		return xerrors.Errorf("cannot read response: %v", err)
+		return err
 	}
 
-	return json.Unmarshal(byteArray, out)
+	if token != delim {
+		return xerrors.Errorf("unexpected token: %v, want %v", token, delim)
+	}
+
+	return nil
 }
 
 // Search は 環境庁花粉観測システムAPIの data_search API をコールするメソッド
+//
+// 検索期間は月ごとに分割してリクエストし、Concurrency の数まで並行して取得した結果を
+// Order の順で結合して返す。
 func (c *Client) Search(ctx context.Context, param *SearchParam) (SokuteiData, error) {
-	validate := validator.New()
-	err := validate.Struct(param)
+	if err := validateSearchParam(param); err != nil {
+		return nil, err
+	}
+
+	params, err := splitSearchParam(param, c.SplitByStation)
 	if err != nil {
 		return nil, err
 	}
 
-	query := make(map[string]string)
-	query["Start_YM"] = param.StartYM
-	query["End_YM"] = param.EndYM
-	query["TDFKN_CD"] = param.TodofukenCode
-	query["SKT_CD"] = param.SokuteikyokuCode
+	return c.searchAll(ctx, params)
+}
 
-	req, err := c.newRequest(ctx, http.MethodGet, "/data_search", query, nil)
+// search は分割済みのパラメータで data_search API を1回コールする。
+func (c *Client) search(ctx context.Context, param *SearchParam) (SokuteiData, error) {
+	req, err := c.newSearchRequest(ctx, param)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := c.HTTPClient.Do(req)
+	// クエリはキー順にエンコードされるので、URLをそのままキャッシュのキーにする。
+	// 当月以降のデータは今後も変わるので、キャッシュを使わずに毎回取得する。
+	cacheKey := req.URL.String()
+	useCache := c.Cache != nil && isCompletedPeriod(param, time.Now())
+	if useCache {
+		if response, ok := c.Cache.Get(cacheKey); ok {
+			c.logf("cache hit: %s", cacheKey)
+			return response, nil
+		}
+	}
+
+	res, err := c.send(req)
 	if err != nil {
 		return nil, err
 	}
 
-	if res.StatusCode != http.StatusOK {
-		return nil, xerrors.New(
-			fmt.Sprintf(
-				"failed to http request with url=%s, status_code=%d",
-				req.URL.String(),
-				res.StatusCode,
-			),
-		)
+	response, err := decodeBody(res, c.DecodeMode)
+	if err != nil {
+		return nil, err
 	}
 
-	var response SokuteiData
-	if err = decodeBody(res, &response); err != nil {
-		return nil, err
+	// 不正な値の項目を含む検索結果は DecodeStrict で取得し直せるようにキャッシュしない
+	if useCache && !response.hasProblems() {
+		if err := c.Cache.Set(cacheKey, response); err != nil {
+			c.logf("failed to store cache: %s: %v", cacheKey, err)
+		}
 	}
 
 	return response, nil
 }
+
+// newSearchRequest は分割済みのパラメータから data_search API のリクエストを作成する。
+func (c *Client) newSearchRequest(ctx context.Context, param *SearchParam) (*http.Request, error) {
+	query := make(map[string]string)
+	query["Start_YM"] = param.StartYM
+	query["End_YM"] = param.EndYM
+	query["TDFKN_CD"] = param.TodofukenCode
+	query["SKT_CD"] = param.SokuteikyokuCode
+
+	return c.newRequest(ctx, http.MethodGet, "/data_search", query, nil)
+}
+
+// send はリクエストを送信する。ステータスコードが200でない場合は *APIError を返す。
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	c.logf("request: %s", req.URL)
+	res, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, newAPIError(req.URL.String(), res.StatusCode, res.Body)
+	}
+
+	return res, nil
+}
@@ -3,12 +3,14 @@ package kafun
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 
 	"golang.org/x/text/encoding/japanese"
@@ -53,8 +55,8 @@ var (
 		},
 	}
 	validSearchParam = &SearchParam{
-		StartYM:          "000000",
-		EndYM:            "000001",
+		StartYM:          "202102",
+		EndYM:            "202102",
 		TodofukenCode:    "01",
 		SokuteikyokuCode: "00000000",
 	}
@@ -98,8 +100,10 @@ func TestNewClient(t *testing.T) {
 				endpoint: DefaultEndpoint,
 			},
 			want: &Client{
-				URL:        testURL,
-				HTTPClient: http.DefaultClient,
+				URL:         testURL,
+				HTTPClient:  http.DefaultClient,
+				Concurrency: DefaultConcurrency,
+				UserAgent:   userAgent,
 			},
 			wantErr: false,
 		},
@@ -109,8 +113,10 @@ func TestNewClient(t *testing.T) {
 				endpoint: "",
 			},
 			want: &Client{
-				URL:        testURL,
-				HTTPClient: http.DefaultClient,
+				URL:         testURL,
+				HTTPClient:  http.DefaultClient,
+				Concurrency: DefaultConcurrency,
+				UserAgent:   userAgent,
 			},
 			wantErr: false,
 		},
@@ -227,15 +233,18 @@ func TestClient_newRequest(t *testing.T) {
 }
 
 func Test_decodeBody(t *testing.T) {
+	record := string(sokuteiDataByteOmitOptional[1 : len(sokuteiDataByteOmitOptional)-1])
+	invalidRecord := strings.Replace(record, `"KFN_NUM": "0"`, `"KFN_NUM": "invalid"`, 1)
+	invalidSecondRecord := []byte("[" + record + "," + invalidRecord + "]")
+
 	type args struct {
 		resp *http.Response
-		out  interface{}
 	}
 	tests := []struct {
 		name    string
 		args    args
-		want    interface{}
-		wantErr bool
+		want    SokuteiData
+		wantErr *DecodeError
 	}{
 		{
 			name: "standard case",
@@ -244,20 +253,51 @@ func Test_decodeBody(t *testing.T) {
 					t,
 					sokuteiDataByteOmitOptional,
 				),
-				out: SokuteiData{},
 			},
 			want: sokuteiDataStructOmitOptional,
 		},
+		{
+			name: "standard case: empty array",
+			args: args{
+				resp: decodeBodyResponseFixture(t, []byte(`[]`)),
+			},
+			want: SokuteiData{},
+		},
+		{
+			name: "error case: invalid field in second record",
+			args: args{
+				resp: decodeBodyResponseFixture(t, invalidSecondRecord),
+			},
+			wantErr: &DecodeError{Index: 1, Field: "KFN_NUM"},
+		},
+		{
+			name: "error case: response is not array",
+			args: args{
+				resp: decodeBodyResponseFixture(t, []byte(`{}`)),
+			},
+			wantErr: &DecodeError{Index: -1},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := decodeBody(tt.args.resp, tt.args.out)
-			if (err != nil) != tt.wantErr {
+			got, err := decodeBody(tt.args.resp, DecodeStrict)
+			if (err != nil) != (tt.wantErr != nil) {
 				t.Fatalf("decodeBody() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
-			if err != nil && !reflect.DeepEqual(tt.args.out, tt.want) {
-				t.Errorf("decodeBody() got = %v, want %v", tt.args.out, tt.want)
+			if tt.wantErr != nil {
+				var decodeErr *DecodeError
+				if !errors.As(err, &decodeErr) {
+					t.Fatalf("decodeBody() error = %v, want *DecodeError", err)
+				}
+				if decodeErr.Index != tt.wantErr.Index || decodeErr.Field != tt.wantErr.Field {
+					t.Errorf("decodeBody() error = %#v, want %#v", decodeErr, tt.wantErr)
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeBody() got = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -0,0 +1,122 @@
+package kafun
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// hoursPerDay は1日の測定時刻の数。測定時刻は1〜24で表す。
+const hoursPerDay = 24
+
+// DailyKafun は測定局ごとの1日の花粉数の集計を表す。
+type DailyKafun struct {
+	SokuteikyokuCode string    // 測定局コード
+	SokuteikyokuName string    // 測定局名
+	TodofukenCode    string    // 都道府県コード(JIS)
+	Date             time.Time // 測定年月日の0時(日本標準時)
+
+	Total     int   // 花粉数の合計
+	Hours     int   // 花粉数のある時間数
+	Max       int   // 1時間の花粉数の最大値
+	MaxHour   int   // 花粉数が最大になった測定時刻(1〜24)。同じ値の場合は早い時刻
+	KafunNums []int // 花粉数のある測定時刻の1時間の花粉数。測定時刻の順
+}
+
+// Mean は1時間の花粉数の平均値を返す。花粉数のある時間がない場合は0を返す。
+func (d *DailyKafun) Mean() float64 {
+	if d.Hours == 0 {
+		return 0
+	}
+
+	return float64(d.Total) / float64(d.Hours)
+}
+
+// Coverage は1日の測定時刻のうち花粉数のある時間の割合(0〜1)を返す。
+func (d *DailyKafun) Coverage() float64 {
+	return float64(d.Hours) / hoursPerDay
+}
+
+// Level は1時間の花粉数の平均値の段階を返す。t は1時間の花粉数の段階なので、1日の合計ではなく
+// 1時間あたりの平均値を分類する。花粉数のある時間がない場合は false を返す。
+func (d *DailyKafun) Level(t Thresholds) (Level, bool) {
+	if d.Hours == 0 {
+		return LevelLow, false
+	}
+
+	return t.Classify(d.Mean()), true
+}
+
+// LevelHours は1時間の花粉数の段階ごとの時間数を返す。添字は Level。
+func (d *DailyKafun) LevelHours(t Thresholds) [LevelExtreme + 1]int {
+	var counts [LevelExtreme + 1]int
+	for _, n := range d.KafunNums {
+		counts[t.Classify(float64(n))]++
+	}
+
+	return counts
+}
+
+// Daily は測定データを測定局と測定年月日ごとに集計し、測定局コード、測定年月日の順で返す。
+// 24時の測定データは翌日ではなく測定年月日の日に含める。測定日時を解釈できない測定データと、
+// 花粉数が不正な値で記録された測定データは集計しない。同じ測定時刻の測定データは後のものを使う。
+func (sd SokuteiData) Daily() []*DailyKafun {
+	type dayKey struct {
+		code string
+		date string
+	}
+	type day struct {
+		daily *DailyKafun
+		hours [hoursPerDay + 1]*HourlySokuteiData
+	}
+
+	days := make(map[dayKey]*day)
+	for _, hsd := range sd {
+		if _, err := hsd.Time(); err != nil || hsd.hasProblem("KFN_NUM") {
+			continue
+		}
+		hour, _ := strconv.Atoi(hsd.SokuteiJikoku)
+		if hour == 0 {
+			// 0時は前日の24時と同じ測定時刻だが、測定年月日の日に含める
+			hour = hoursPerDay
+		}
+
+		key := dayKey{code: hsd.SokuteikyokuCode, date: hsd.SokuteiNengappi}
+		d, ok := days[key]
+		if !ok {
+			date, _ := time.ParseInLocation(sokuteiNengappiLayout, hsd.SokuteiNengappi, jst)
+			d = &day{daily: &DailyKafun{SokuteikyokuCode: hsd.SokuteikyokuCode, Date: date}}
+			days[key] = d
+		}
+		d.hours[hour] = hsd
+	}
+
+	result := make([]*DailyKafun, 0, len(days))
+	for _, d := range days {
+		daily := d.daily
+		for hour, hsd := range d.hours {
+			if hsd == nil {
+				continue
+			}
+			daily.SokuteikyokuName = hsd.SokuteikyokuName
+			daily.TodofukenCode = hsd.TodofukenCode
+			daily.Total += hsd.KafunNum
+			if daily.Hours == 0 || hsd.KafunNum > daily.Max {
+				daily.Max = hsd.KafunNum
+				daily.MaxHour = hour
+			}
+			daily.Hours++
+			daily.KafunNums = append(daily.KafunNums, hsd.KafunNum)
+		}
+		result = append(result, daily)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].SokuteikyokuCode != result[j].SokuteikyokuCode {
+			return result[i].SokuteikyokuCode < result[j].SokuteikyokuCode
+		}
+		return result[i].Date.Before(result[j].Date)
+	})
+
+	return result
+}
@@ -0,0 +1,52 @@
+package kafun
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSokuteiData_Daily(t *testing.T) {
+	data := SokuteiData{
+		{SokuteikyokuCode: "2", SokuteiNengappi: "20210201", SokuteiJikoku: "1", KafunNum: 5},
+		{SokuteikyokuCode: "1", SokuteikyokuName: "A", SokuteiNengappi: "20210202", SokuteiJikoku: "1", KafunNum: 3},
+		{SokuteikyokuCode: "1", SokuteikyokuName: "A", SokuteiNengappi: "20210201", SokuteiJikoku: "24", KafunNum: 40},
+		{SokuteikyokuCode: "1", SokuteikyokuName: "A", SokuteiNengappi: "20210201", SokuteiJikoku: "2", KafunNum: 40},
+		{SokuteikyokuCode: "1", SokuteikyokuName: "A", SokuteiNengappi: "20210201", SokuteiJikoku: "1", KafunNum: 10},
+		{SokuteikyokuCode: "1", SokuteiNengappi: "20210201", SokuteiJikoku: "3", Problems: []FieldProblem{{Field: "KFN_NUM"}}},
+		{SokuteikyokuCode: "1", SokuteiNengappi: "invalid", SokuteiJikoku: "1", KafunNum: 100},
+	}
+
+	day := func(d int) time.Time {
+		return time.Date(2021, 2, d, 0, 0, 0, 0, jst)
+	}
+	want := []*DailyKafun{
+		{SokuteikyokuCode: "1", SokuteikyokuName: "A", Date: day(1), Total: 90, Hours: 3, Max: 40, MaxHour: 2, KafunNums: []int{10, 40, 40}},
+		{SokuteikyokuCode: "1", SokuteikyokuName: "A", Date: day(2), Total: 3, Hours: 1, Max: 3, MaxHour: 1, KafunNums: []int{3}},
+		{SokuteikyokuCode: "2", Date: day(1), Total: 5, Hours: 1, Max: 5, MaxHour: 1, KafunNums: []int{5}},
+	}
+
+	got := data.Daily()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Daily() got = %+v, want %+v", got, want)
+	}
+
+	if mean := got[0].Mean(); mean != 30 {
+		t.Errorf("Mean() = %v, want 30", mean)
+	}
+	if coverage := got[0].Coverage(); coverage != 0.125 {
+		t.Errorf("Coverage() = %v, want 0.125", coverage)
+	}
+	if level, ok := got[0].Level(DefaultThresholds); !ok || level != LevelModerate {
+		t.Errorf("Level() = %v, %v, want %v, true", level, ok, LevelModerate)
+	}
+	if _, ok := (&DailyKafun{}).Level(DefaultThresholds); ok {
+		t.Errorf("Level() ok = true for a day without data")
+	}
+	if hours := got[0].LevelHours(DefaultThresholds); hours != [5]int{0, 1, 2, 0, 0} {
+		t.Errorf("LevelHours() = %v, want [0 1 2 0 0]", hours)
+	}
+	if hours := (&DailyKafun{}).LevelHours(DefaultThresholds); hours != [5]int{} {
+		t.Errorf("LevelHours() = %v, want no hours for a day without data", hours)
+	}
+}
@@ -0,0 +1,287 @@
+package kafun
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// DecodeMode は測定データの項目に不正な値があった場合の扱いを表す。
+type DecodeMode int
+
+const (
+	// DecodeStrict は不正な値の項目があるとデコードを中断して *DecodeError を返す。
+	DecodeStrict DecodeMode = iota
+
+	// DecodeLenient は不正な値の項目を HourlySokuteiData.Problems に記録してデコードを続ける。
+	// 不正な値の数値型の項目はnull(花粉数は0)に、文字列型の項目は文字列にできる値ならそのままになる。
+	DecodeLenient
+)
+
+var decodeModeNames = [...]string{DecodeStrict: "strict", DecodeLenient: "lenient"}
+
+func (m DecodeMode) String() string {
+	if m < 0 || int(m) >= len(decodeModeNames) {
+		return fmt.Sprintf("DecodeMode(%d)", int(m))
+	}
+
+	return decodeModeNames[m]
+}
+
+// ParseDecodeMode は "strict" ないしは "lenient" を DecodeMode に変換する。
+func ParseDecodeMode(s string) (DecodeMode, error) {
+	for m, name := range decodeModeNames {
+		if s == name {
+			return DecodeMode(m), nil
+		}
+	}
+
+	return DecodeStrict, xerrors.Errorf("unknown decode mode: %s", s)
+}
+
+// FieldProblem は DecodeLenient でデコードした測定データの項目の問題を表す。
+// 欠測した花粉数や、Fill で補った測定データで花粉数を補わなかった場合も、値のない項目として記録する。
+type FieldProblem struct {
+	Field   string // 項目のJSONキー
+	Value   string // 項目の元の値。文字列にできない値の場合は空文字列
+	Message string // 問題の内容
+}
+
+// missingValueMessage は値のない項目の問題の内容。
+const missingValueMessage = "not observed"
+
+func (p FieldProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Field, p.Message)
+}
+
+// hasProblem は項目に DecodeLenient で記録した問題があるかどうかを返す。
+func (hsd *HourlySokuteiData) hasProblem(field string) bool {
+	for _, p := range hsd.Problems {
+		if p.Field == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isMissing は項目が欠測などで値のない項目として記録されているかどうかを返す。
+func (hsd *HourlySokuteiData) isMissing(field string) bool {
+	for _, p := range hsd.Problems {
+		if p.Field == field && p.Message == missingValueMessage {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasProblems は DecodeLenient で不正な値の項目を記録した測定データを含むかどうかを返す。
+func (sd SokuteiData) hasProblems() bool {
+	for _, hsd := range sd {
+		if len(hsd.Problems) != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// decodeRecord は1件の測定データのJSONを mode に従ってデコードする。
+func decodeRecord(data []byte, mode DecodeMode) (*HourlySokuteiData, error) {
+	var v map[string]interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+
+	hsd := &HourlySokuteiData{}
+	if err := hsd.decodeMap(v, mode); err != nil {
+		return nil, err
+	}
+
+	return hsd, nil
+}
+
+// decodeMap はJSONキーと値の対応から mode に従って HourlySokuteiData を作成する。
+// 花粉数は必須の項目として扱うが、null の場合は欠測として Problems に記録する。
+// DecodeStrict では測定局コード、測定年月日、測定時刻、都道府県コードも必須の項目として扱う。
+// 測定日時の形式は検証しないので Time で確認する。
+func (hsd *HourlySokuteiData) decodeMap(v map[string]interface{}, mode DecodeMode) error {
+	d := &fieldDecoder{v: v, mode: mode}
+
+	hsd.SokuteikyokuCode = d.requiredString("SKT_CD")
+	hsd.AMeDASCode = d.string("AMeDAS_CD")
+	hsd.SokuteiNengappi = d.requiredString("SKT_NNGP")
+	hsd.SokuteiJikoku = d.requiredString("SKT_HH")
+	hsd.SokuteikyokuName = d.string("SKT_NM")
+	hsd.SokuteiType = d.string("SKT_TYPE")
+	hsd.TodofukenCode = d.requiredString("TDFKN_CD")
+	hsd.TodofukenName = d.string("TDFKN_NM")
+	hsd.SokuteiShichosonCode = d.string("SKCHSN_CD")
+	hsd.SokuteiShichosonName = d.string("SKCHSN_NM")
+	hsd.KafunNum = d.kafunNum("KFN_NUM")
+	hsd.AMeDASWindDirect = d.windDirection("AMeDAS_WD")
+	hsd.AMeDASWindSpeed = d.intPointer("AMeDAS_WS")
+	hsd.AMeDASTemperature = d.float64Pointer("AMeDAS_TP")
+	hsd.AMeDASPrecipitation = d.intPointer("AMeDAS_PR")
+	hsd.AMeDASRadarPrecipitation = d.intPointer("AMeDAS_RDPR")
+
+	if d.err != nil {
+		return d.err
+	}
+	hsd.Problems = d.problems
+
+	return nil
+}
+
+// fieldDecoder は項目ごとの値を検証しながら取り出す。
+// DecodeStrict では最初の問題を err に、DecodeLenient ではすべての問題を problems に記録する。
+type fieldDecoder struct {
+	v        map[string]interface{}
+	mode     DecodeMode
+	err      error
+	problems []FieldProblem
+}
+
+// fail は項目の問題を記録する。
+func (d *fieldDecoder) fail(key, value string, err error) {
+	if d.mode != DecodeLenient {
+		if d.err == nil {
+			d.err = &DecodeError{Index: -1, Field: key, Err: err}
+		}
+		return
+	}
+
+	d.problems = append(d.problems, FieldProblem{Field: key, Value: value, Message: err.Error()})
+}
+
+// value は項目の値を文字列で返す。値が空でない場合に true を返す。
+// 文字列と数値以外の値は問題として記録し、必須の項目が空の場合も問題として記録する。
+func (d *fieldDecoder) value(key string, required bool) (string, bool) {
+	elem := d.v[key]
+	switch elem.(type) {
+	case nil, string, float64:
+	default:
+		d.fail(key, "", xerrors.Errorf("unexpected %s value", jsonTypeName(elem)))
+		return "", false
+	}
+
+	s := elementString(elem)
+	if len(s) == 0 {
+		if required {
+			d.fail(key, s, d.missingError())
+		}
+		return "", false
+	}
+
+	return s, true
+}
+
+// missingError は必須の項目が空の場合のエラーを返す。
+// どの測定データかわかるように、測定局コード、測定年月日、測定時刻のうち値があるものを含める。
+func (d *fieldDecoder) missingError() error {
+	var fields []string
+	for _, key := range []string{"SKT_CD", "SKT_NNGP", "SKT_HH"} {
+		if s := elementString(d.v[key]); len(s) != 0 {
+			fields = append(fields, fmt.Sprintf("%s=%s", key, s))
+		}
+	}
+	if len(fields) == 0 {
+		return xerrors.New("missing required field")
+	}
+
+	return xerrors.Errorf("missing required field in record %s", strings.Join(fields, " "))
+}
+
+func (d *fieldDecoder) string(key string) string {
+	s, _ := d.value(key, false)
+	return s
+}
+
+// requiredString は DecodeStrict で必須の項目の値を返す。
+// DecodeLenient では空の値を問題として記録せず、測定データを特定できないまま読み込みを続ける。
+func (d *fieldDecoder) requiredString(key string) string {
+	s, _ := d.value(key, d.mode == DecodeStrict)
+	return s
+}
+
+func (d *fieldDecoder) windDirection(key string) string {
+	s, ok := d.value(key, false)
+	if ok {
+		if _, err := ParseWindDirection(s); err != nil {
+			d.fail(key, s, err)
+		}
+	}
+
+	return s
+}
+
+func (d *fieldDecoder) kafunNum(key string) int {
+	if elem, ok := d.v[key]; ok && elem == nil {
+		// kafun import は欠測した花粉数を null で出力する
+		d.problems = append(d.problems, FieldProblem{Field: key, Message: missingValueMessage})
+		return 0
+	}
+
+	s, ok := d.value(key, true)
+	if !ok {
+		return 0
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		d.fail(key, s, err)
+		return 0
+	}
+
+	return n
+}
+
+func (d *fieldDecoder) intPointer(key string) *int {
+	s, ok := d.value(key, false)
+	if !ok {
+		return nil
+	}
+
+	n, err := validateIntPointerElement(d.v, key)
+	if err != nil {
+		d.fail(key, s, err)
+	}
+
+	return n
+}
+
+func (d *fieldDecoder) float64Pointer(key string) *float64 {
+	s, ok := d.value(key, false)
+	if !ok {
+		return nil
+	}
+
+	f, err := validateFloat64PointerElement(d.v, key)
+	if err == nil && (math.IsNaN(*f) || math.IsInf(*f, 0)) {
+		f, err = nil, xerrors.Errorf("invalid number: %q", s)
+	}
+	if err != nil {
+		d.fail(key, s, err)
+	}
+
+	return f
+}
+
+// jsonTypeName は json.Unmarshal でデコードした値のJSONの型名を返す。
+func jsonTypeName(elem interface{}) string {
+	switch elem.(type) {
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", elem)
+	}
+}
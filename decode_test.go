@@ -0,0 +1,277 @@
+package kafun
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseDecodeMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    DecodeMode
+		wantErr bool
+	}{
+		{name: "standard case: strict", s: "strict", want: DecodeStrict},
+		{name: "standard case: lenient", s: "lenient", want: DecodeLenient},
+		{name: "error case: unknown mode", s: "loose", want: DecodeStrict, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDecodeMode(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDecodeMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDecodeMode() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_decodeRecord(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		mode      DecodeMode
+		want      *HourlySokuteiData
+		wantField string
+	}{
+		{
+			name: "standard case: valid record in strict mode",
+			data: `{"SKT_CD": "00000000", "SKT_NNGP": "20210201", "SKT_HH": "1", "TDFKN_CD": "13", "KFN_NUM": "4", "AMeDAS_TP": 1.5}`,
+			mode: DecodeStrict,
+			want: &HourlySokuteiData{
+				SokuteikyokuCode:  "00000000",
+				SokuteiNengappi:   "20210201",
+				SokuteiJikoku:     "1",
+				TodofukenCode:     "13",
+				KafunNum:          4,
+				AMeDASTemperature: float64PointerHelper(t, 1.5),
+			},
+		},
+		{
+			name: "standard case: null KFN_NUM is not observed in strict mode",
+			data: `{"SKT_CD": "00000000", "SKT_NNGP": "20210201", "SKT_HH": "1", "TDFKN_CD": "13", "KFN_NUM": null}`,
+			mode: DecodeStrict,
+			want: &HourlySokuteiData{
+				SokuteikyokuCode: "00000000",
+				SokuteiNengappi:  "20210201",
+				SokuteiJikoku:    "1",
+				TodofukenCode:    "13",
+				Problems:         []FieldProblem{{Field: "KFN_NUM", Message: missingValueMessage}},
+			},
+		},
+		{
+			name:      "error case: missing KFN_NUM in strict mode",
+			data:      `{"SKT_CD": "00000000", "SKT_NNGP": "20210201", "SKT_HH": "1", "TDFKN_CD": "13"}`,
+			mode:      DecodeStrict,
+			wantField: "KFN_NUM",
+		},
+		{
+			name:      "error case: boolean value in strict mode",
+			data:      `{"SKT_CD": true, "KFN_NUM": "4"}`,
+			mode:      DecodeStrict,
+			wantField: "SKT_CD",
+		},
+		{
+			name:      "error case: invalid wind direction in strict mode",
+			data:      `{"SKT_CD": "00000000", "SKT_NNGP": "20210201", "SKT_HH": "1", "TDFKN_CD": "13", "KFN_NUM": "4", "AMeDAS_WD": "99"}`,
+			mode:      DecodeStrict,
+			wantField: "AMeDAS_WD",
+		},
+		{
+			name:      "error case: NaN temperature in strict mode",
+			data:      `{"SKT_CD": "00000000", "SKT_NNGP": "20210201", "SKT_HH": "1", "TDFKN_CD": "13", "KFN_NUM": "4", "AMeDAS_TP": "NaN"}`,
+			mode:      DecodeStrict,
+			wantField: "AMeDAS_TP",
+		},
+		{
+			name: "standard case: problems are recorded in lenient mode",
+			data: `{"SKT_CD": {"code": 1}, "SKT_NNGP": "20210201", "KFN_NUM": "-", "AMeDAS_WS": "abc", "AMeDAS_TP": "15.4"}`,
+			mode: DecodeLenient,
+			want: &HourlySokuteiData{
+				SokuteiNengappi:   "20210201",
+				AMeDASTemperature: float64PointerHelper(t, 15.4),
+				Problems: []FieldProblem{
+					{Field: "SKT_CD", Message: "unexpected object value"},
+					{Field: "KFN_NUM", Value: "-", Message: `strconv.Atoi: parsing "-": invalid syntax`},
+					{Field: "AMeDAS_WS", Value: "abc", Message: `strconv.Atoi: parsing "abc": invalid syntax`},
+				},
+			},
+		},
+		{
+			name: "standard case: missing KFN_NUM in lenient mode",
+			data: `{}`,
+			mode: DecodeLenient,
+			want: &HourlySokuteiData{
+				Problems: []FieldProblem{{Field: "KFN_NUM", Message: "missing required field"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeRecord([]byte(tt.data), tt.mode)
+			if len(tt.wantField) != 0 {
+				var decodeErr *DecodeError
+				if !errors.As(err, &decodeErr) {
+					t.Fatalf("decodeRecord() error = %v, want *DecodeError", err)
+				}
+				if decodeErr.Field != tt.wantField {
+					t.Errorf("decodeRecord() error field = %v, want %v", decodeErr.Field, tt.wantField)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeRecord() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeRecord() got = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_decodeRecord_requiredFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		field   string
+		wantErr string
+	}{
+		{
+			name:    "error case: missing SKT_CD",
+			data:    `{"SKT_NNGP": "20210201", "SKT_HH": "1", "TDFKN_CD": "13", "KFN_NUM": "4"}`,
+			field:   "SKT_CD",
+			wantErr: "failed to decode field SKT_CD: missing required field in record SKT_NNGP=20210201 SKT_HH=1",
+		},
+		{
+			name:    "error case: missing SKT_NNGP",
+			data:    `{"SKT_CD": "00000000", "SKT_HH": "1", "TDFKN_CD": "13", "KFN_NUM": "4"}`,
+			field:   "SKT_NNGP",
+			wantErr: "failed to decode field SKT_NNGP: missing required field in record SKT_CD=00000000 SKT_HH=1",
+		},
+		{
+			name:    "error case: empty SKT_HH",
+			data:    `{"SKT_CD": "00000000", "SKT_NNGP": "20210201", "SKT_HH": "", "TDFKN_CD": "13", "KFN_NUM": "4"}`,
+			field:   "SKT_HH",
+			wantErr: "failed to decode field SKT_HH: missing required field in record SKT_CD=00000000 SKT_NNGP=20210201",
+		},
+		{
+			name:    "error case: missing TDFKN_CD",
+			data:    `{"SKT_CD": "00000000", "SKT_NNGP": "20210201", "SKT_HH": "1", "KFN_NUM": "4"}`,
+			field:   "TDFKN_CD",
+			wantErr: "failed to decode field TDFKN_CD: missing required field in record SKT_CD=00000000 SKT_NNGP=20210201 SKT_HH=1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeRecord([]byte(tt.data), DecodeStrict)
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("decodeRecord() error = %v, want *DecodeError", err)
+			}
+			if decodeErr.Field != tt.field {
+				t.Errorf("decodeRecord() error field = %v, want %v", decodeErr.Field, tt.field)
+			}
+			if err.Error() != tt.wantErr {
+				t.Errorf("decodeRecord() error = %v, want %v", err, tt.wantErr)
+			}
+
+			// DecodeLenient では問題として記録しない
+			got, err := decodeRecord([]byte(tt.data), DecodeLenient)
+			if err != nil {
+				t.Fatalf("decodeRecord() lenient error = %v", err)
+			}
+			if got.hasProblem(tt.field) {
+				t.Errorf("decodeRecord() lenient problems = %v, want no problem for %v", got.Problems, tt.field)
+			}
+		})
+	}
+}
+
+func Test_decodeStream_lenient(t *testing.T) {
+	body := []byte(`[{"SKT_CD": "1", "KFN_NUM": "x"}, {"SKT_CD": "2", "KFN_NUM": "3"}]`)
+
+	var got SokuteiData
+	err := decodeStream(decodeBodyResponseFixture(t, body).Body, DecodeLenient, func(hsd *HourlySokuteiData) error {
+		got = append(got, hsd)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("decodeStream() error = %v", err)
+	}
+	if len(got) != 2 || len(got[0].Problems) != 1 || len(got[1].Problems) != 0 {
+		t.Errorf("decodeStream() got = %v", got)
+	}
+	if !got.hasProblems() {
+		t.Errorf("hasProblems() = false, want true")
+	}
+}
+
+// 不正なJSONでもパニックせず、JSONオブジェクトであれば lenient ではエラーにならないことを確認する。
+func FuzzDecodeRecord(f *testing.F) {
+	seeds := []string{
+		`{"SKT_CD": "00000000", "SKT_NNGP": "20210201", "SKT_HH": "01", "KFN_NUM": "4", "AMeDAS_TP": "15.4"}`,
+		`{"KFN_NUM": 4, "AMeDAS_WS": 1, "AMeDAS_PR": null, "AMeDAS_WD": "05"}`,
+		`{"SKT_CD": 1, "KFN_NUM": true, "AMeDAS_TP": [1], "AMeDAS_RDPR": {}}`,
+		`{"KFN_NUM": "1e400", "AMeDAS_TP": "Inf"}`,
+		`{}`,
+		`null`,
+		`[]`,
+		`"SKT_CD"`,
+		`{"KFN_NUM": "4"`,
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		strict, strictErr := decodeRecord(data, DecodeStrict)
+		lenient, lenientErr := decodeRecord(data, DecodeLenient)
+
+		var v map[string]interface{}
+		isObject := json.Unmarshal(data, &v) == nil
+		if isObject && lenientErr != nil {
+			t.Errorf("decodeRecord(%q, DecodeLenient) error = %v", data, lenientErr)
+		}
+		if isObject && strictErr != nil {
+			var decodeErr *DecodeError
+			if !errors.As(strictErr, &decodeErr) || len(decodeErr.Field) == 0 {
+				t.Errorf("decodeRecord(%q, DecodeStrict) error = %v, want *DecodeError with field", data, strictErr)
+			}
+		}
+		if strictErr == nil && len(strict.Problems) != 0 {
+			t.Errorf("decodeRecord(%q, DecodeStrict) problems = %v", data, strict.Problems)
+		}
+
+		// デコードできた測定データは出力できる
+		for _, hsd := range []*HourlySokuteiData{strict, lenient} {
+			if hsd == nil {
+				continue
+			}
+			if _, err := json.Marshal(hsd); err != nil {
+				t.Errorf("json.Marshal() error = %v", err)
+			}
+		}
+	})
+}
+
+func FuzzDecodeStream(f *testing.F) {
+	f.Add([]byte(`[{"SKT_CD": "1", "KFN_NUM": "4"}, {"KFN_NUM": "x"}]`))
+	f.Add([]byte(`[]`))
+	f.Add([]byte(`[1, "a", null]`))
+	f.Add([]byte(`{"SKT_CD": "1"}`))
+	f.Add([]byte(`[{"KFN_NUM": "4"}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, mode := range []DecodeMode{DecodeStrict, DecodeLenient} {
+			_ = decodeStream(bytes.NewReader(data), mode, func(*HourlySokuteiData) error {
+				return nil
+			})
+		}
+	})
+}
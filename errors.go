@@ -0,0 +1,108 @@
+package kafun
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/transform"
+)
+
+// APIErrorBodyLimit は APIError に保持するレスポンスボディの最大バイト数。
+const APIErrorBodyLimit = 512
+
+// APIError はAPIが200以外のステータスコードを返したことを表す。
+type APIError struct {
+	StatusCode int    // レスポンスのステータスコード
+	URL        string // リクエストしたURL
+	Body       string // レスポンスボディの先頭(UTF-8変換済み)
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("failed to http request with url=%s, status_code=%d", e.URL, e.StatusCode)
+}
+
+// newAPIError はレスポンスから APIError を作成する。レスポンスボディは先頭だけを読んで閉じる。
+func newAPIError(url string, statusCode int, body io.ReadCloser) *APIError {
+	defer body.Close()
+
+	// APIレスポンスがShift-JISなので、Goで扱えるようにUTF-8変換する。
+	snippet, _ := ioutil.ReadAll(transform.NewReader(
+		io.LimitReader(body, APIErrorBodyLimit),
+		japanese.ShiftJIS.NewDecoder(),
+	))
+
+	return &APIError{
+		StatusCode: statusCode,
+		URL:        url,
+		Body:       string(snippet),
+	}
+}
+
+// ValidationError は SearchParam の検証エラーを表す。
+type ValidationError struct {
+	Fields map[string]string // SearchParam のフィールド名と問題の内容
+}
+
+func (e *ValidationError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	problems := make([]string, 0, len(names))
+	for _, name := range names {
+		problems = append(problems, fmt.Sprintf("%s: %s", name, e.Fields[name]))
+	}
+
+	return fmt.Sprintf("invalid search parameter: %s", strings.Join(problems, ", "))
+}
+
+// newValidationError は validator の検証エラーを ValidationError に変換する。
+func newValidationError(err error) error {
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return err
+	}
+
+	fields := make(map[string]string, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		problem := fmt.Sprintf("failed on '%s' rule", fieldError.Tag())
+		if len(fieldError.Param()) != 0 {
+			problem = fmt.Sprintf("failed on '%s=%s' rule", fieldError.Tag(), fieldError.Param())
+		}
+		fields[fieldError.Field()] = problem
+	}
+
+	return &ValidationError{Fields: fields}
+}
+
+// DecodeError はAPIレスポンスのデコードエラーを表す。
+type DecodeError struct {
+	Index int    // デコードに失敗したレコードの位置(0始まり)。レコードを特定できない場合は -1
+	Field string // デコードに失敗した項目のJSONキー。項目を特定できない場合は空文字列
+	Err   error  // 失敗の原因
+}
+
+func (e *DecodeError) Error() string {
+	switch {
+	case e.Index < 0 && len(e.Field) == 0:
+		return fmt.Sprintf("failed to decode response: %v", e.Err)
+	case e.Index < 0:
+		return fmt.Sprintf("failed to decode field %s: %v", e.Field, e.Err)
+	case len(e.Field) == 0:
+		return fmt.Sprintf("failed to decode record %d: %v", e.Index, e.Err)
+	default:
+		return fmt.Sprintf("failed to decode record %d field %s: %v", e.Index, e.Field, e.Err)
+	}
+}
+
+// Unwrap は失敗の原因を返す。
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
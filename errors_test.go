@@ -0,0 +1,150 @@
+package kafun
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestClient_Search_errors(t *testing.T) {
+	sjisBody, _ := encodeUTF8ToSJIS(t, []byte("メンテナンス中"))
+	type fields struct {
+		mockServerHandlerFunc func(w http.ResponseWriter, r *http.Request)
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		param  *SearchParam
+		want   error
+	}{
+		{
+			name: "error case: api error with body snippet",
+			fields: fields{
+				mockServerHandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					w.Write(sjisBody)
+				},
+			},
+			param: validSearchParam,
+			want: &APIError{
+				StatusCode: http.StatusServiceUnavailable,
+				Body:       "メンテナンス中",
+			},
+		},
+		{
+			name: "error case: validation error of struct tags",
+			param: &SearchParam{
+				StartYM:       "2021",
+				TodofukenCode: "13",
+			},
+			want: &ValidationError{
+				Fields: map[string]string{"StartYM": "failed on 'len=6' rule"},
+			},
+		},
+		{
+			name: "error case: validation error of prefecture code",
+			param: &SearchParam{
+				StartYM:       "202102",
+				TodofukenCode: "48",
+			},
+			want: &ValidationError{
+				Fields: map[string]string{"TodofukenCode": "failed on 'todofuken' rule"},
+			},
+		},
+		{
+			name: "error case: validation error of month range",
+			param: &SearchParam{
+				StartYM:       "202103",
+				EndYM:         "202102",
+				TodofukenCode: "13",
+			},
+			want: &ValidationError{
+				Fields: map[string]string{"EndYM": "must not be before StartYM: 202103"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.fields.mockServerHandlerFunc
+			if handler == nil {
+				handler = func(w http.ResponseWriter, r *http.Request) {
+					t.Errorf("unexpected request: %s", r.URL)
+				}
+			}
+			testServer := httptest.NewServer(http.HandlerFunc(handler))
+			defer testServer.Close()
+			testServerURL, _ := url.Parse(testServer.URL)
+			c := &Client{
+				URL:        testServerURL,
+				HTTPClient: http.DefaultClient,
+			}
+
+			_, err := c.Search(ctx, tt.param)
+
+			switch want := tt.want.(type) {
+			case *APIError:
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("Search() error = %v, want *APIError", err)
+				}
+				if apiErr.StatusCode != want.StatusCode || apiErr.Body != want.Body {
+					t.Errorf("Search() error = %#v, want %#v", apiErr, want)
+				}
+				if len(apiErr.URL) == 0 {
+					t.Errorf("Search() error URL is empty")
+				}
+			case *ValidationError:
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) {
+					t.Fatalf("Search() error = %v, want *ValidationError", err)
+				}
+				if !reflect.DeepEqual(validationErr, want) {
+					t.Errorf("Search() error = %#v, want %#v", validationErr, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeError_Error(t *testing.T) {
+	cause := errors.New("cause")
+	tests := []struct {
+		name string
+		err  *DecodeError
+		want string
+	}{
+		{
+			name: "standard case: record and field",
+			err:  &DecodeError{Index: 2, Field: "KFN_NUM", Err: cause},
+			want: "failed to decode record 2 field KFN_NUM: cause",
+		},
+		{
+			name: "standard case: record only",
+			err:  &DecodeError{Index: 2, Err: cause},
+			want: "failed to decode record 2: cause",
+		},
+		{
+			name: "standard case: field only",
+			err:  &DecodeError{Index: -1, Field: "KFN_NUM", Err: cause},
+			want: "failed to decode field KFN_NUM: cause",
+		},
+		{
+			name: "standard case: whole response",
+			err:  &DecodeError{Index: -1, Err: cause},
+			want: "failed to decode response: cause",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() got = %v, want %v", got, tt.want)
+			}
+			if !errors.Is(tt.err, cause) {
+				t.Errorf("errors.Is() got = false, want true")
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package kafun
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// FillStrategy は欠測の時間を補った測定データの項目の値の決め方を表す。
+type FillStrategy int
+
+const (
+	// FillNull は値を補わず、null のままにする。花粉数はJSONでは null、CSVなどでは空の値で出力する。
+	FillNull FillStrategy = iota
+
+	// FillZero は0にする。
+	FillZero
+
+	// FillCarryForward は直前の値のある測定データの値にする。直前の測定データがない場合は null になる。
+	FillCarryForward
+
+	// FillLinear は前後の値のある測定データの値から測定日時で線形補間する。前後どちらかがない場合は null になる。
+	FillLinear
+)
+
+var fillStrategyNames = [...]string{FillNull: "null", FillZero: "zero", FillCarryForward: "carry", FillLinear: "linear"}
+
+func (s FillStrategy) String() string {
+	if s < 0 || int(s) >= len(fillStrategyNames) {
+		return fmt.Sprintf("FillStrategy(%d)", int(s))
+	}
+
+	return fillStrategyNames[s]
+}
+
+// ParseFillStrategy は "null"、"zero"、"carry"、"linear" を FillStrategy に変換する。
+func ParseFillStrategy(s string) (FillStrategy, error) {
+	for strategy, name := range fillStrategyNames {
+		if s == name {
+			return FillStrategy(strategy), nil
+		}
+	}
+
+	return FillNull, xerrors.Errorf("unknown fill strategy: %s", s)
+}
+
+// FillPolicy は欠測の時間を補った測定データの項目ごとの値の決め方を表す。
+// 風向、風速、レーダー降雨降雪の有無は補わない。ゼロ値はすべての項目を null にする。
+// 花粉数と降水量は1時間ごとに大きく変わるので、直前の値にはしない。
+type FillPolicy struct {
+	KafunNum      FillStrategy // 花粉数。FillNull、FillZero のいずれか
+	Temperature   FillStrategy // 気温。FillNull、FillCarryForward、FillLinear のいずれか
+	Precipitation FillStrategy // 降水量。FillNull、FillZero のいずれか
+}
+
+// fillPolicyKeys は ParseFillPolicy で指定する項目の名前。
+var fillPolicyKeys = []string{"kafun", "temperature", "precipitation"}
+
+// ParseFillPolicy は "kafun=zero,temperature=linear" の形式の項目ごとの指定から FillPolicy を作成する。
+// 項目は kafun、temperature、precipitation で、指定しない項目は null になる。"null" はすべての項目を null にする。
+func ParseFillPolicy(s string) (FillPolicy, error) {
+	var policy FillPolicy
+	if s == FillNull.String() {
+		return policy, nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			return FillPolicy{}, xerrors.Errorf("invalid fill policy: %q: want key=strategy", item)
+		}
+		strategy, err := ParseFillStrategy(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return FillPolicy{}, xerrors.Errorf("invalid fill policy: %q: %w", item, err)
+		}
+
+		switch strings.TrimSpace(kv[0]) {
+		case "kafun":
+			policy.KafunNum = strategy
+		case "temperature":
+			policy.Temperature = strategy
+		case "precipitation":
+			policy.Precipitation = strategy
+		default:
+			return FillPolicy{}, xerrors.Errorf(
+				"invalid fill policy: %q: unknown key, want one of %s", item, strings.Join(fillPolicyKeys, ", "),
+			)
+		}
+	}
+	if err := policy.validate(); err != nil {
+		return FillPolicy{}, err
+	}
+
+	return policy, nil
+}
+
+func (p FillPolicy) validate() error {
+	if p.KafunNum < FillNull || p.KafunNum > FillZero {
+		return xerrors.Errorf("invalid fill policy: %s is not supported for kafun", p.KafunNum)
+	}
+	if p.Temperature == FillZero || p.Temperature < FillNull || p.Temperature > FillLinear {
+		return xerrors.Errorf("invalid fill policy: %s is not supported for temperature", p.Temperature)
+	}
+	if p.Precipitation < FillNull || p.Precipitation > FillZero {
+		return xerrors.Errorf("invalid fill policy: %s is not supported for precipitation", p.Precipitation)
+	}
+
+	return nil
+}
+
+// Fill は DetectGaps と同じ期間と測定局で、測定データのない時間の測定データを policy に従って補い、
+// 元の測定データと合わせて測定局コード、測定日時の順で返す。補った測定データは Filled が true になる。
+// 測定局の情報は同じ測定局の測定データから写し、測定データのない測定局は測定局コードだけになる。
+func Fill(data SokuteiData, param *SearchParam, policy FillPolicy) (SokuteiData, error) {
+	return fill(data, param, policy, time.Now())
+}
+
+// observation は補間に使う測定日時を解釈した測定データ。
+type observation struct {
+	time time.Time
+	hsd  *HourlySokuteiData
+}
+
+func fill(data SokuteiData, param *SearchParam, policy FillPolicy, now time.Time) (SokuteiData, error) {
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	gaps, err := detectGaps(data, param, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// すでに補った測定データは値を決めるのに使わないが、同じ測定日時には補わない
+	observed := make(map[string][]observation)
+	filled := make(map[string]map[int64]bool)
+	for _, hsd := range data {
+		t, err := hsd.Time()
+		if err != nil {
+			continue
+		}
+		code := hsd.SokuteikyokuCode
+		if !hsd.Filled {
+			observed[code] = append(observed[code], observation{time: t, hsd: hsd})
+		} else {
+			if filled[code] == nil {
+				filled[code] = make(map[int64]bool)
+			}
+			filled[code][t.Unix()] = true
+		}
+	}
+
+	result := append(SokuteiData(nil), data...)
+	for _, g := range gaps {
+		code := g.SokuteikyokuCode
+		rows := observed[code]
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].time.Before(rows[j].time) })
+
+		next := 0 // rows で t 以降の最初の測定データ
+		for _, t := range g.Missing {
+			for next < len(rows) && rows[next].time.Before(t) {
+				next++
+			}
+			if next < len(rows) && rows[next].time.Equal(t) || filled[code][t.Unix()] {
+				continue
+			}
+			result = append(result, fillHour(code, t, rows, next, policy))
+		}
+	}
+	result.SortByStation()
+
+	return result, nil
+}
+
+// fillHour は測定日時 t の測定データを補う。rows[:next] は t より前、rows[next:] は t より後の測定データ。
+func fillHour(code string, t time.Time, rows []observation, next int, policy FillPolicy) *HourlySokuteiData {
+	hsd := &HourlySokuteiData{SokuteikyokuCode: code, Filled: true}
+	if len(rows) != 0 {
+		station := rows[len(rows)-1].hsd
+		hsd.AMeDASCode = station.AMeDASCode
+		hsd.SokuteikyokuName = station.SokuteikyokuName
+		hsd.SokuteiType = station.SokuteiType
+		hsd.TodofukenCode = station.TodofukenCode
+		hsd.TodofukenName = station.TodofukenName
+		hsd.SokuteiShichosonCode = station.SokuteiShichosonCode
+		hsd.SokuteiShichosonName = station.SokuteiShichosonName
+	}
+	hsd.SokuteiNengappi, hsd.SokuteiJikoku = sokuteiSlot(t)
+
+	hasTemperature := func(hsd *HourlySokuteiData) bool { return hsd.AMeDASTemperature != nil }
+
+	if policy.KafunNum == FillZero {
+		hsd.KafunNum = 0
+	} else {
+		hsd.Problems = append(hsd.Problems, FieldProblem{Field: "KFN_NUM", Message: missingValueMessage})
+	}
+
+	switch policy.Temperature {
+	case FillCarryForward:
+		if prev, ok := previousObservation(rows[:next], hasTemperature); ok {
+			v := *prev.hsd.AMeDASTemperature
+			hsd.AMeDASTemperature = &v
+		}
+	case FillLinear:
+		prev, okPrev := previousObservation(rows[:next], hasTemperature)
+		after, okNext := nextObservation(rows[next:], hasTemperature)
+		if okPrev && okNext {
+			p, n := *prev.hsd.AMeDASTemperature, *after.hsd.AMeDASTemperature
+			ratio := float64(t.Sub(prev.time)) / float64(after.time.Sub(prev.time))
+			// 気温は0.1度単位で記録されているので、補間した値も0.1度単位にする
+			v := math.Round((p+(n-p)*ratio)*10) / 10
+			hsd.AMeDASTemperature = &v
+		}
+	}
+
+	if policy.Precipitation == FillZero {
+		v := 0
+		hsd.AMeDASPrecipitation = &v
+	}
+
+	return hsd
+}
+
+// previousObservation は rows の最後から has を満たす測定データを探す。
+func previousObservation(rows []observation, has func(*HourlySokuteiData) bool) (observation, bool) {
+	for i := len(rows) - 1; i >= 0; i-- {
+		if has(rows[i].hsd) {
+			return rows[i], true
+		}
+	}
+
+	return observation{}, false
+}
+
+// nextObservation は rows の最初から has を満たす測定データを探す。
+func nextObservation(rows []observation, has func(*HourlySokuteiData) bool) (observation, bool) {
+	for _, row := range rows {
+		if has(row.hsd) {
+			return row, true
+		}
+	}
+
+	return observation{}, false
+}
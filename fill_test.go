@@ -0,0 +1,188 @@
+package kafun
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFillPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    FillPolicy
+		wantErr bool
+	}{
+		{name: "standard case: null", s: "null", want: FillPolicy{}},
+		{
+			name: "standard case: all keys",
+			s:    "kafun=zero, temperature=linear, precipitation=zero",
+			want: FillPolicy{KafunNum: FillZero, Temperature: FillLinear, Precipitation: FillZero},
+		},
+		{name: "standard case: omitted keys are null", s: "temperature=carry", want: FillPolicy{Temperature: FillCarryForward}},
+		{name: "error case: linear for kafun", s: "kafun=linear", wantErr: true},
+		{name: "error case: carry for kafun", s: "kafun=carry", wantErr: true},
+		{name: "error case: zero for temperature", s: "temperature=zero", wantErr: true},
+		{name: "error case: linear for precipitation", s: "precipitation=linear", wantErr: true},
+		{name: "error case: carry for precipitation", s: "precipitation=carry", wantErr: true},
+		{name: "error case: unknown key", s: "humidity=zero", wantErr: true},
+		{name: "error case: unknown strategy", s: "kafun=mean", wantErr: true},
+		{name: "error case: missing strategy", s: "kafun", wantErr: true},
+		{name: "error case: empty", s: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFillPolicy(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFillPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFillPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fill(t *testing.T) {
+	row := func(hour string, kafunNum int, temperature float64, precipitation int) *HourlySokuteiData {
+		return &HourlySokuteiData{
+			SokuteikyokuCode:    "1",
+			SokuteikyokuName:    "テスト観測所",
+			TodofukenCode:       "13",
+			SokuteiNengappi:     "20210201",
+			SokuteiJikoku:       hour,
+			KafunNum:            kafunNum,
+			AMeDASTemperature:   float64PointerHelper(t, temperature),
+			AMeDASPrecipitation: intPointerHelper(t, precipitation),
+		}
+	}
+	// 2時から4時と、6時より後が欠測
+	data := SokuteiData{row("05", 20, 9, 0), row("01", 10, 5, 2), row("06", 30, 10, 1)}
+	param := &SearchParam{StartYM: "202102", SokuteikyokuCode: "1"}
+	now := time.Date(2021, 2, 1, 7, 30, 0, 0, jst)
+
+	type filledValues struct {
+		Hour          string
+		KafunNum      interface{} // nil の場合は値のない花粉数
+		Temperature   interface{}
+		Precipitation interface{}
+	}
+
+	tests := []struct {
+		name   string
+		policy FillPolicy
+		want   []filledValues
+	}{
+		{
+			name:   "standard case: null",
+			policy: FillPolicy{},
+			want: []filledValues{
+				{Hour: "02"}, {Hour: "03"}, {Hour: "04"}, {Hour: "07"},
+			},
+		},
+		{
+			name:   "standard case: zero",
+			policy: FillPolicy{KafunNum: FillZero, Precipitation: FillZero},
+			want: []filledValues{
+				{Hour: "02", KafunNum: 0, Precipitation: 0},
+				{Hour: "03", KafunNum: 0, Precipitation: 0},
+				{Hour: "04", KafunNum: 0, Precipitation: 0},
+				{Hour: "07", KafunNum: 0, Precipitation: 0},
+			},
+		},
+		{
+			name:   "standard case: carry forward temperature",
+			policy: FillPolicy{Temperature: FillCarryForward},
+			want: []filledValues{
+				{Hour: "02", Temperature: 5.0},
+				{Hour: "03", Temperature: 5.0},
+				{Hour: "04", Temperature: 5.0},
+				{Hour: "07", Temperature: 10.0},
+			},
+		},
+		{
+			name:   "standard case: linear temperature",
+			policy: FillPolicy{Temperature: FillLinear},
+			want: []filledValues{
+				{Hour: "02", Temperature: 6.0},
+				{Hour: "03", Temperature: 7.0},
+				{Hour: "04", Temperature: 8.0},
+				{Hour: "07"}, // 後の測定データがないので補間しない
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fill(data, param, tt.policy, now)
+			if err != nil {
+				t.Fatalf("fill() error = %v", err)
+			}
+
+			var hours []string
+			var filled []filledValues
+			for _, hsd := range got {
+				hours = append(hours, hsd.SokuteiJikoku)
+				if !hsd.Filled {
+					continue
+				}
+				if hsd.SokuteikyokuName != "テスト観測所" || hsd.TodofukenCode != "13" || hsd.SokuteiNengappi != "20210201" {
+					t.Errorf("fill() station = %+v, want copied from observed data", hsd)
+				}
+				v := filledValues{Hour: hsd.SokuteiJikoku}
+				if !hsd.hasProblem("KFN_NUM") {
+					v.KafunNum = hsd.KafunNum
+				}
+				if hsd.AMeDASTemperature != nil {
+					v.Temperature = *hsd.AMeDASTemperature
+				}
+				if hsd.AMeDASPrecipitation != nil {
+					v.Precipitation = *hsd.AMeDASPrecipitation
+				}
+				filled = append(filled, v)
+			}
+
+			if want := []string{"01", "02", "03", "04", "05", "06", "07"}; !reflect.DeepEqual(hours, want) {
+				t.Errorf("fill() hours = %v, want %v", hours, want)
+			}
+			if !reflect.DeepEqual(filled, tt.want) {
+				t.Errorf("fill() filled = %+v, want %+v", filled, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fill_gaps(t *testing.T) {
+	data := dailyFixture(t, "1", time.Date(2021, 2, 1, 0, 0, 0, 0, jst), 5, -1, 5)
+	param := &SearchParam{StartYM: "202102", EndYM: "202102"}
+	now := time.Date(2021, 2, 4, 0, 0, 0, 0, jst)
+
+	filled, err := fill(data, param, FillPolicy{KafunNum: FillZero}, now)
+	if err != nil {
+		t.Fatalf("fill() error = %v", err)
+	}
+	if len(filled) != 3*24 {
+		t.Errorf("len(fill()) = %d, want %d", len(filled), 3*24)
+	}
+
+	// 補った測定データは欠測のままで、もう一度補っても重ならない
+	gaps, err := detectGaps(filled, param, now)
+	if err != nil {
+		t.Fatalf("detectGaps() error = %v", err)
+	}
+	if len(gaps[0].Missing) != 24 {
+		t.Errorf("len(Missing) = %d, want 24", len(gaps[0].Missing))
+	}
+	refilled, err := fill(filled, param, FillPolicy{}, now)
+	if err != nil {
+		t.Fatalf("fill() error = %v", err)
+	}
+	if len(refilled) != len(filled) {
+		t.Errorf("len(fill()) = %d, want %d", len(refilled), len(filled))
+	}
+}
+
+func Test_fill_invalidPolicy(t *testing.T) {
+	if _, err := fill(nil, &SearchParam{StartYM: "202102"}, FillPolicy{KafunNum: FillLinear}, time.Now()); err == nil {
+		t.Errorf("fill() error = nil, want error for linear kafun")
+	}
+}
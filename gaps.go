@@ -0,0 +1,166 @@
+package kafun
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GapRun は測定データのない時間が続いた期間を表す。
+type GapRun struct {
+	Start time.Time // 最初の欠測の測定日時 (Time と同じ表し方)
+	End   time.Time // 最後の欠測の測定日時
+}
+
+// Hours は期間の時間数を返す。
+func (r GapRun) Hours() int {
+	return int(r.End.Sub(r.Start)/time.Hour) + 1
+}
+
+// StationGaps は測定局ごとの欠測を表す。
+type StationGaps struct {
+	SokuteikyokuCode string // 測定局コード
+	SokuteikyokuName string // 測定局名。測定データがない場合は空文字列
+
+	Expected int         // 検索した期間の時間数
+	Missing  []time.Time // 測定データのない測定日時。古い順
+	Runs     []GapRun    // 測定データのない時間が続いた期間。古い順
+}
+
+// Present は測定データのある時間数を返す。
+func (g *StationGaps) Present() int {
+	return g.Expected - len(g.Missing)
+}
+
+// Completeness は検索した期間のうち測定データのある時間の割合(0〜1)を返す。期間がない場合は1を返す。
+func (g *StationGaps) Completeness() float64 {
+	if g.Expected == 0 {
+		return 1
+	}
+
+	return float64(g.Present()) / float64(g.Expected)
+}
+
+// LongestRun は最も長く測定データのない時間が続いた期間を返す。欠測がない場合は false を返す。
+func (g *StationGaps) LongestRun() (GapRun, bool) {
+	if len(g.Runs) == 0 {
+		return GapRun{}, false
+	}
+
+	longest := g.Runs[0]
+	for _, r := range g.Runs[1:] {
+		if r.Hours() > longest.Hours() {
+			longest = r
+		}
+	}
+
+	return longest, true
+}
+
+// DetectGaps は param の期間の1時間ごとに測定データがあるかを調べ、測定局ごとの欠測を測定局コードの順で返す。
+//
+// 期間は StartYM の月初から EndYM の月末までで、EndYM が空の場合と現在より後の時間は現在までにする。
+// 測定局は param の測定局コードを、指定されていない場合は測定データに含まれる測定局を調べる。
+// 花粉数が不正な値で記録された測定データと、Fill で補った測定データは欠測とみなす。
+func DetectGaps(data SokuteiData, param *SearchParam) ([]*StationGaps, error) {
+	return detectGaps(data, param, time.Now())
+}
+
+func detectGaps(data SokuteiData, param *SearchParam, now time.Time) ([]*StationGaps, error) {
+	start, end, err := gapPeriod(param, now)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+	present := make(map[string]map[int64]bool)
+	for _, hsd := range data {
+		t, err := hsd.Time()
+		if err != nil || hsd.Filled || hsd.hasProblem("KFN_NUM") || t.Before(start) || t.After(end) {
+			continue
+		}
+
+		code := hsd.SokuteikyokuCode
+		if present[code] == nil {
+			present[code] = make(map[int64]bool)
+		}
+		present[code][t.Unix()] = true
+		if len(hsd.SokuteikyokuName) != 0 {
+			names[code] = hsd.SokuteikyokuName
+		}
+	}
+
+	var codes []string
+	for _, code := range strings.Split(param.SokuteikyokuCode, ",") {
+		if code = strings.TrimSpace(code); len(code) != 0 {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		for code := range present {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+
+	gaps := make([]*StationGaps, 0, len(codes))
+	for _, code := range codes {
+		g := &StationGaps{SokuteikyokuCode: code, SokuteikyokuName: names[code]}
+		for t := start; !t.After(end); t = t.Add(time.Hour) {
+			g.Expected++
+			if present[code][t.Unix()] {
+				continue
+			}
+
+			g.Missing = append(g.Missing, t)
+			if n := len(g.Runs); n != 0 && g.Runs[n-1].End.Equal(t.Add(-time.Hour)) {
+				g.Runs[n-1].End = t
+			} else {
+				g.Runs = append(g.Runs, GapRun{Start: t, End: t})
+			}
+		}
+		gaps = append(gaps, g)
+	}
+
+	return gaps, nil
+}
+
+// gapPeriod は param の期間の最初と最後の測定日時を返す。最後の測定日時が最初より前の場合は期間がない。
+func gapPeriod(param *SearchParam, now time.Time) (time.Time, time.Time, error) {
+	first, err := time.ParseInLocation(yearMonthLayout, param.StartYM, jst)
+	if err != nil {
+		return time.Time{}, time.Time{}, &ValidationError{
+			Fields: map[string]string{"StartYM": fmt.Sprintf("invalid year month: %s", param.StartYM)},
+		}
+	}
+
+	end := now.In(jst).Truncate(time.Hour)
+	if len(param.EndYM) != 0 {
+		last, err := time.ParseInLocation(yearMonthLayout, param.EndYM, jst)
+		if err != nil {
+			return time.Time{}, time.Time{}, &ValidationError{
+				Fields: map[string]string{"EndYM": fmt.Sprintf("invalid year month: %s", param.EndYM)},
+			}
+		}
+		if last.Before(first) {
+			return time.Time{}, time.Time{}, &ValidationError{
+				Fields: map[string]string{"EndYM": fmt.Sprintf("must not be before StartYM: %s", param.StartYM)},
+			}
+		}
+
+		// 月末の24時は翌月1日の0時になる
+		if monthEnd := last.AddDate(0, 1, 0); monthEnd.Before(end) {
+			end = monthEnd
+		}
+	}
+
+	// 測定時刻は1〜24なので、月初の最初の測定日時は1時になる
+	return first.Add(time.Hour), end, nil
+}
+
+// sokuteiSlot は測定日時を測定年月日と2桁の測定時刻(01〜24)にする。0時は前日の24時になる。
+func sokuteiSlot(t time.Time) (string, string) {
+	t = t.In(jst).Add(-time.Hour)
+	return t.Format(sokuteiNengappiLayout), fmt.Sprintf("%02d", t.Hour()+1)
+}
@@ -0,0 +1,211 @@
+package kafun
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_detectGaps(t *testing.T) {
+	feb := func(d, h int) time.Time {
+		return time.Date(2021, 2, d, h, 0, 0, 0, jst)
+	}
+	counts := make([]int, 28)
+	counts[2] = -1 // 2月3日は測定データがない
+	month := dailyFixture(t, "1", feb(1, 0), counts...)
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, jst)
+
+	withoutHour := func(data SokuteiData, date, hour string) SokuteiData {
+		var result SokuteiData
+		for _, hsd := range data {
+			if hsd.SokuteiNengappi != date || hsd.SokuteiJikoku != hour {
+				result = append(result, hsd)
+			}
+		}
+		return result
+	}
+	withProblem := func(data SokuteiData, date, hour string) SokuteiData {
+		result := make(SokuteiData, len(data))
+		for i, hsd := range data {
+			copied := *hsd
+			if hsd.SokuteiNengappi == date && hsd.SokuteiJikoku == hour {
+				copied.Problems = []FieldProblem{{Field: "KFN_NUM", Value: "-", Message: "invalid"}}
+			}
+			result[i] = &copied
+		}
+		return result
+	}
+
+	tests := []struct {
+		name             string
+		data             SokuteiData
+		param            *SearchParam
+		now              time.Time
+		wantCodes        []string
+		wantExpected     int
+		wantMissing      int
+		wantRuns         []GapRun
+		wantCompleteness float64
+	}{
+		{
+			name:             "standard case: missing day",
+			data:             month,
+			param:            &SearchParam{StartYM: "202102", EndYM: "202102"},
+			now:              now,
+			wantCodes:        []string{"1"},
+			wantExpected:     28 * 24,
+			wantMissing:      24,
+			wantRuns:         []GapRun{{Start: feb(3, 1), End: feb(4, 0)}},
+			wantCompleteness: 27.0 / 28,
+		},
+		{
+			name:         "standard case: missing hours",
+			data:         withoutHour(withoutHour(month, "20210210", "24"), "20210228", "1"),
+			param:        &SearchParam{StartYM: "202102", EndYM: "202102"},
+			now:          now,
+			wantCodes:    []string{"1"},
+			wantExpected: 28 * 24,
+			wantMissing:  26,
+			wantRuns: []GapRun{
+				{Start: feb(3, 1), End: feb(4, 0)},
+				{Start: feb(11, 0), End: feb(11, 0)},
+				{Start: feb(28, 1), End: feb(28, 1)},
+			},
+			wantCompleteness: float64(28*24-26) / (28 * 24),
+		},
+		{
+			name:             "standard case: invalid KFN_NUM is missing",
+			data:             withProblem(dailyFixture(t, "1", feb(1, 0), 0), "20210201", "5"),
+			param:            &SearchParam{StartYM: "202102", EndYM: "202102", SokuteikyokuCode: "1"},
+			now:              feb(2, 0),
+			wantCodes:        []string{"1"},
+			wantExpected:     24,
+			wantMissing:      1,
+			wantRuns:         []GapRun{{Start: feb(1, 5), End: feb(1, 5)}},
+			wantCompleteness: 23.0 / 24,
+		},
+		{
+			name:             "standard case: requested station without data",
+			data:             dailyFixture(t, "1", feb(1, 0), 0),
+			param:            &SearchParam{StartYM: "202102", SokuteikyokuCode: "2"},
+			now:              feb(1, 10).Add(30 * time.Minute),
+			wantCodes:        []string{"2"},
+			wantExpected:     10,
+			wantMissing:      10,
+			wantRuns:         []GapRun{{Start: feb(1, 1), End: feb(1, 10)}},
+			wantCompleteness: 0,
+		},
+		{
+			name:             "standard case: data outside the period is ignored",
+			data:             dailyFixture(t, "1", time.Date(2021, 1, 31, 0, 0, 0, 0, jst), 0, 0),
+			param:            &SearchParam{StartYM: "202102", EndYM: "202102"},
+			now:              feb(2, 0),
+			wantCodes:        []string{"1"},
+			wantExpected:     24,
+			wantMissing:      0,
+			wantCompleteness: 1,
+		},
+		{
+			name:             "standard case: no period before now",
+			data:             nil,
+			param:            &SearchParam{StartYM: "202102", SokuteikyokuCode: "1"},
+			now:              feb(1, 0),
+			wantCodes:        []string{"1"},
+			wantExpected:     0,
+			wantCompleteness: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gaps, err := detectGaps(tt.data, tt.param, tt.now)
+			if err != nil {
+				t.Fatalf("detectGaps() error = %v", err)
+			}
+			var codes []string
+			for _, g := range gaps {
+				codes = append(codes, g.SokuteikyokuCode)
+			}
+			if !reflect.DeepEqual(codes, tt.wantCodes) {
+				t.Fatalf("detectGaps() codes = %v, want %v", codes, tt.wantCodes)
+			}
+
+			g := gaps[0]
+			if g.Expected != tt.wantExpected {
+				t.Errorf("Expected = %d, want %d", g.Expected, tt.wantExpected)
+			}
+			if len(g.Missing) != tt.wantMissing {
+				t.Errorf("len(Missing) = %d, want %d", len(g.Missing), tt.wantMissing)
+			}
+			if !reflect.DeepEqual(g.Runs, tt.wantRuns) {
+				t.Errorf("Runs = %v, want %v", g.Runs, tt.wantRuns)
+			}
+			if g.Completeness() != tt.wantCompleteness {
+				t.Errorf("Completeness() = %v, want %v", g.Completeness(), tt.wantCompleteness)
+			}
+		})
+	}
+}
+
+func Test_detectGaps_error(t *testing.T) {
+	tests := []struct {
+		name      string
+		param     *SearchParam
+		wantField string
+	}{
+		{name: "error case: invalid StartYM", param: &SearchParam{StartYM: "2021"}, wantField: "StartYM"},
+		{name: "error case: invalid EndYM", param: &SearchParam{StartYM: "202102", EndYM: "2021-03"}, wantField: "EndYM"},
+		{name: "error case: EndYM before StartYM", param: &SearchParam{StartYM: "202103", EndYM: "202102"}, wantField: "EndYM"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := detectGaps(nil, tt.param, time.Now())
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("detectGaps() error = %v, want *ValidationError", err)
+			}
+			if _, ok := validationErr.Fields[tt.wantField]; !ok {
+				t.Errorf("detectGaps() error fields = %v, want %s", validationErr.Fields, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestStationGaps_LongestRun(t *testing.T) {
+	at := func(h int) time.Time {
+		return time.Date(2021, 2, 1, h, 0, 0, 0, jst)
+	}
+	g := &StationGaps{Runs: []GapRun{
+		{Start: at(1), End: at(2)},
+		{Start: at(5), End: at(8)},
+		{Start: at(10), End: at(13)},
+	}}
+
+	got, ok := g.LongestRun()
+	if !ok || !reflect.DeepEqual(got, GapRun{Start: at(5), End: at(8)}) || got.Hours() != 4 {
+		t.Errorf("LongestRun() = %v, %v, want the first run of 4 hours", got, ok)
+	}
+	if _, ok := (&StationGaps{}).LongestRun(); ok {
+		t.Errorf("LongestRun() ok = true, want false without gaps")
+	}
+}
+
+func Test_sokuteiSlot(t *testing.T) {
+	tests := []struct {
+		t        time.Time
+		wantDate string
+		wantHour string
+	}{
+		{t: time.Date(2021, 2, 3, 1, 0, 0, 0, jst), wantDate: "20210203", wantHour: "01"},
+		{t: time.Date(2021, 2, 3, 23, 0, 0, 0, jst), wantDate: "20210203", wantHour: "23"},
+		{t: time.Date(2021, 3, 1, 0, 0, 0, 0, jst), wantDate: "20210228", wantHour: "24"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.t.String(), func(t *testing.T) {
+			date, hour := sokuteiSlot(tt.t)
+			if date != tt.wantDate || hour != tt.wantHour {
+				t.Errorf("sokuteiSlot() = %s %s, want %s %s", date, hour, tt.wantDate, tt.wantHour)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package kafun
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/width"
+	"golang.org/x/xerrors"
+)
+
+// csvHeaderAliases は環境省が公開した過去の花粉観測データのCSVのヘッダと、対応するAPIのJSONキー。
+// 年度によってヘッダの表記が異なるので、既知の表記をすべて登録している。
+// ヘッダは全角英数を半角にし、空白と単位の括弧書きを除いてから照合する。
+var csvHeaderAliases = map[string]string{
+	"測定局コード":      "SKT_CD",
+	"局コード":        "SKT_CD",
+	"観測所コード":      "SKT_CD",
+	"アメダスコード":     "AMeDAS_CD",
+	"アメダス地点コード":   "AMeDAS_CD",
+	"測定年月日":       "SKT_NNGP",
+	"年月日":         "SKT_NNGP",
+	"日付":          "SKT_NNGP",
+	"測定日":         "SKT_NNGP",
+	"測定時刻":        "SKT_HH",
+	"時刻":          "SKT_HH",
+	"時":           "SKT_HH",
+	"測定局名":        "SKT_NM",
+	"局名":          "SKT_NM",
+	"観測所名":        "SKT_NM",
+	"測定局種別":       "SKT_TYPE",
+	"測定局のタイプ":     "SKT_TYPE",
+	"種別":          "SKT_TYPE",
+	"都道府県コード":     "TDFKN_CD",
+	"都道府県名":       "TDFKN_NM",
+	"都道府県":        "TDFKN_NM",
+	"市区町村コード":     "SKCHSN_CD",
+	"測定局の市区町村コード": "SKCHSN_CD",
+	"市区町村名":       "SKCHSN_NM",
+	"測定局の市区町村名":   "SKCHSN_NM",
+	"市区町村":        "SKCHSN_NM",
+	"花粉数":         "KFN_NUM",
+	"花粉飛散数":       "KFN_NUM",
+	"花粉総数":        "KFN_NUM",
+	"風向":          "AMeDAS_WD",
+	"風向き":         "AMeDAS_WD",
+	"風速":          "AMeDAS_WS",
+	"気温":          "AMeDAS_TP",
+	"降水量":         "AMeDAS_PR",
+	"レーダー降水量":     "AMeDAS_RDPR",
+	"レーダー降雨降雪の有無": "AMeDAS_RDPR",
+}
+
+// APIのJSONキー。ヘッダがJSONキーの場合は大文字小文字を区別せずに照合する。
+var apiJSONKeys = []string{
+	"SKT_CD", "AMeDAS_CD", "SKT_NNGP", "SKT_HH", "SKT_NM", "SKT_TYPE", "TDFKN_CD", "TDFKN_NM",
+	"SKCHSN_CD", "SKCHSN_NM", "KFN_NUM", "AMeDAS_WD", "AMeDAS_WS", "AMeDAS_TP", "AMeDAS_PR", "AMeDAS_RDPR",
+}
+
+// CSVに必須の項目。都道府県コードは列がなくても csvTodofukenCode で補う。
+var requiredCSVColumns = []string{"SKT_CD", "SKT_NNGP", "SKT_HH", "KFN_NUM"}
+
+// 欠測を表す値。
+var missingCSVValues = map[string]bool{"-": true, "--": true, "---": true, "欠測": true, "×": true}
+
+var (
+	csvUnitPattern = regexp.MustCompile(`[(\[].*$`)
+	csvDatePattern = regexp.MustCompile(`^(\d{4})[/\-.年](\d{1,2})[/\-.月](\d{1,2})日?$`)
+	csvHourPattern = regexp.MustCompile(`^(\d{1,2})(?:時|:00)?$`)
+)
+
+// RowError はCSVの行単位の読み込みエラーを表す。
+type RowError struct {
+	Line  int    // エラーになった行番号(1始まり)
+	Field string // エラーになった項目のJSONキー。項目を特定できない場合は空文字列
+	Err   error  // 失敗の原因
+}
+
+func (e *RowError) Error() string {
+	if len(e.Field) == 0 {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+
+	return fmt.Sprintf("line %d: field %s: %v", e.Line, e.Field, e.Err)
+}
+
+// Unwrap は失敗の原因を返す。
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ParseCSV は環境省が公開した過去の花粉観測データのCSVを SokuteiData に変換する。
+//
+// 文字コードはUTF-8とShift-JISに対応し、ヘッダは年度ごとの表記の違い(「花粉数」「花粉飛散数」など)や
+// APIのJSONキーを受け付ける。読み込めない行があっても処理は続け、行番号付きの *RowError として返す。
+// 花粉数が欠測(「-」「欠測」など)の行は、花粉数を値のない項目として Problems に記録して読み込む。
+// 都道府県コードがない行は、都道府県名、測定局の一覧、市区町村コードから補い、補えない場合は *RowError にする。
+// ヘッダを読み込めない場合や必須の項目がない場合はエラーを返す。
+func ParseCSV(r io.Reader) (SokuteiData, []*RowError, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to read csv: %v", err)
+	}
+
+	if !utf8.Valid(b) {
+		if b, _, err = transform.Bytes(japanese.ShiftJIS.NewDecoder(), b); err != nil {
+			return nil, nil, xerrors.Errorf("failed to convert csv from Shift-JIS: %v", err)
+		}
+	}
+	b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(b))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return SokuteiData{}, nil, nil
+	}
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to read csv header: %v", err)
+	}
+
+	columns, err := csvColumns(header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data := SokuteiData{}
+	var rowErrors []*RowError
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				line = parseErr.Line
+			}
+			rowErrors = append(rowErrors, &RowError{Line: line, Err: err})
+			continue
+		}
+
+		if isBlankRecord(record) {
+			continue
+		}
+
+		hsd, err := csvRecordToSokuteiData(columns, record)
+		if err != nil {
+			rowErr := &RowError{Line: line, Err: err}
+			var decodeErr *DecodeError
+			if errors.As(err, &decodeErr) {
+				rowErr.Field = decodeErr.Field
+				rowErr.Err = decodeErr.Err
+			}
+			rowErrors = append(rowErrors, rowErr)
+			continue
+		}
+		data = append(data, hsd)
+	}
+
+	return data, rowErrors, nil
+}
+
+// csvColumns はヘッダの各列に対応するAPIのJSONキーを返す。対応するキーがない列は空文字列になる。
+func csvColumns(header []string) ([]string, error) {
+	columns := make([]string, len(header))
+	found := make(map[string]bool)
+
+	for i, name := range header {
+		key := csvHeaderKey(name)
+		if len(key) == 0 || found[key] {
+			continue
+		}
+		columns[i] = key
+		found[key] = true
+	}
+
+	var missing []string
+	for _, key := range requiredCSVColumns {
+		if !found[key] {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) != 0 {
+		return nil, xerrors.Errorf("csv header lacks required columns: %s", strings.Join(missing, ", "))
+	}
+
+	return columns, nil
+}
+
+// csvHeaderKey はCSVのヘッダに対応するAPIのJSONキーを返す。
+func csvHeaderKey(name string) string {
+	normalized := width.Fold.String(strings.TrimSpace(name))
+	normalized = csvUnitPattern.ReplaceAllString(normalized, "")
+	normalized = strings.Join(strings.Fields(normalized), "")
+
+	for _, key := range apiJSONKeys {
+		if strings.EqualFold(normalized, key) {
+			return key
+		}
+	}
+
+	return csvHeaderAliases[normalized]
+}
+
+// csvRecordToSokuteiData はCSVの1行を HourlySokuteiData に変換する。
+func csvRecordToSokuteiData(columns []string, record []string) (*HourlySokuteiData, error) {
+	v := make(map[string]interface{}, len(columns))
+	for i, key := range columns {
+		if len(key) == 0 || i >= len(record) {
+			continue
+		}
+
+		value := strings.TrimSpace(width.Fold.String(record[i]))
+		if missingCSVValues[value] {
+			if key == "KFN_NUM" {
+				// null の花粉数は欠測として記録される
+				v[key] = nil
+				continue
+			}
+			value = ""
+		}
+
+		switch key {
+		case "SKT_NNGP":
+			normalized, err := normalizeCSVDate(value)
+			if err != nil {
+				return nil, &DecodeError{Index: -1, Field: key, Err: err}
+			}
+			value = normalized
+		case "SKT_HH":
+			normalized, err := normalizeCSVHour(value)
+			if err != nil {
+				return nil, &DecodeError{Index: -1, Field: key, Err: err}
+			}
+			value = normalized
+		case "KFN_NUM", "AMeDAS_WS", "AMeDAS_PR", "AMeDAS_RDPR", "AMeDAS_TP":
+			value = strings.ReplaceAll(value, ",", "")
+		}
+
+		v[key] = value
+	}
+
+	code, ok := csvTodofukenCode(v)
+	if !ok {
+		return nil, &DecodeError{
+			Index: -1,
+			Field: "TDFKN_CD",
+			Err:   xerrors.Errorf("cannot determine prefecture of station %s", elementString(v["SKT_CD"])),
+		}
+	}
+	v["TDFKN_CD"] = code
+
+	hsd := &HourlySokuteiData{}
+	if err := hsd.fromMap(v); err != nil {
+		return nil, err
+	}
+
+	return hsd, nil
+}
+
+// csvTodofukenCode は行の都道府県コードを2桁のJISの都道府県コードで返す。
+// 都道府県コードがない場合は、都道府県名、測定局の一覧、市区町村コードの上2桁の順に探す。
+func csvTodofukenCode(v map[string]interface{}) (string, bool) {
+	if code := elementString(v["TDFKN_CD"]); len(code) != 0 {
+		if p, ok := LookupPrefecture(code); ok {
+			return p.Code, true
+		}
+		return code, true
+	}
+
+	if name := elementString(v["TDFKN_NM"]); len(name) != 0 {
+		if p, ok := LookupPrefecture(name); ok {
+			return p.Code, true
+		}
+	}
+
+	if s, ok := DefaultStationCatalog().Lookup(elementString(v["SKT_CD"])); ok && len(s.TodofukenCode) != 0 {
+		return s.TodofukenCode, true
+	}
+
+	if code := elementString(v["SKCHSN_CD"]); len(code) >= 5 && isPrefectureCode(code[:2]) {
+		return code[:2], true
+	}
+
+	return "", false
+}
+
+// normalizeCSVDate は yyyy/M/d などの日付を yyyyMMdd に変換する。
+func normalizeCSVDate(value string) (string, error) {
+	if len(value) == 8 {
+		if _, err := strconv.Atoi(value); err == nil {
+			return value, nil
+		}
+	}
+
+	m := csvDatePattern.FindStringSubmatch(value)
+	if m == nil {
+		return "", xerrors.Errorf("invalid date: %q", value)
+	}
+
+	month, _ := strconv.Atoi(m[2])
+	day, _ := strconv.Atoi(m[3])
+
+	return fmt.Sprintf("%s%02d%02d", m[1], month, day), nil
+}
+
+// normalizeCSVHour は 1、1時、1:00 などの時刻を2桁の時(01〜24)に変換する。
+func normalizeCSVHour(value string) (string, error) {
+	m := csvHourPattern.FindStringSubmatch(value)
+	if m == nil {
+		return "", xerrors.Errorf("invalid hour: %q", value)
+	}
+
+	hour, _ := strconv.Atoi(m[1])
+	if hour > 24 {
+		return "", xerrors.Errorf("invalid hour: %q", value)
+	}
+
+	return fmt.Sprintf("%02d", hour), nil
+}
+
+func isBlankRecord(record []string) bool {
+	for _, field := range record {
+		if len(strings.TrimSpace(field)) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,246 @@
+package kafun
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCSV(t *testing.T) {
+	type want struct {
+		data      SokuteiData
+		rowErrors []*RowError
+		wantErr   bool
+	}
+	tests := []struct {
+		name  string
+		input string
+		sjis  bool
+		want  want
+	}{
+		{
+			name: "standard case: japanese header with units in Shift-JIS",
+			input: strings.Join([]string{
+				"測定局コード,測定局名,都道府県コード,年月日,時,花粉数（個/m3）,風向,風速(m/s),気温(℃),降水量(mm)",
+				"51320100,新宿区役所第二分庁舎,13,2021/2/1,1時,１２,05,2,8.5,0",
+				"51320100,新宿区役所第二分庁舎,13,2021/2/1,2時,3,05,欠測,-,",
+			}, "\r\n"),
+			sjis: true,
+			want: want{
+				data: SokuteiData{
+					{
+						SokuteikyokuCode:    "51320100",
+						SokuteiNengappi:     "20210201",
+						SokuteiJikoku:       "01",
+						SokuteikyokuName:    "新宿区役所第二分庁舎",
+						TodofukenCode:       "13",
+						KafunNum:            12,
+						AMeDASWindDirect:    "05",
+						AMeDASWindSpeed:     intPointerHelper(t, 2),
+						AMeDASTemperature:   float64PointerHelper(t, 8.5),
+						AMeDASPrecipitation: intPointerHelper(t, 0),
+					},
+					{
+						SokuteikyokuCode: "51320100",
+						SokuteiNengappi:  "20210201",
+						SokuteiJikoku:    "02",
+						SokuteikyokuName: "新宿区役所第二分庁舎",
+						TodofukenCode:    "13",
+						KafunNum:         3,
+						AMeDASWindDirect: "05",
+					},
+				},
+			},
+		},
+		{
+			name: "standard case: api json key header variant",
+			input: strings.Join([]string{
+				"skt_cd,SKT_NNGP,SKT_HH,Tdfkn_Cd,KFN_NUM,unknown",
+				"00000001,20210201,24,1,1,x",
+			}, "\n"),
+			want: want{
+				data: SokuteiData{
+					{
+						SokuteikyokuCode: "00000001",
+						SokuteiNengappi:  "20210201",
+						SokuteiJikoku:    "24",
+						TodofukenCode:    "01",
+						KafunNum:         1,
+					},
+				},
+			},
+		},
+		{
+			name: "standard case: prefecture derived from name, station catalog and municipality code",
+			input: strings.Join([]string{
+				"局コード,都道府県,市区町村コード,日付,時刻,花粉数",
+				"00000001,東京都,,2021/2/1,1,1",
+				"51320100,,,2021/2/1,1,2",
+				"00000002,,27100,2021/2/1,1,3",
+				"00000003,,,2021/2/1,1,4",
+			}, "\n"),
+			want: want{
+				data: SokuteiData{
+					{
+						SokuteikyokuCode: "00000001",
+						SokuteiNengappi:  "20210201",
+						SokuteiJikoku:    "01",
+						TodofukenCode:    "13",
+						TodofukenName:    "東京都",
+						KafunNum:         1,
+					},
+					{SokuteikyokuCode: "51320100", SokuteiNengappi: "20210201", SokuteiJikoku: "01", TodofukenCode: "13", KafunNum: 2},
+					{
+						SokuteikyokuCode:     "00000002",
+						SokuteiNengappi:      "20210201",
+						SokuteiJikoku:        "01",
+						TodofukenCode:        "27",
+						SokuteiShichosonCode: "27100",
+						KafunNum:             3,
+					},
+				},
+				rowErrors: []*RowError{
+					{Line: 5, Field: "TDFKN_CD"},
+				},
+			},
+		},
+		{
+			name: "standard case: row errors with line numbers",
+			input: strings.Join([]string{
+				"局コード,都道府県コード,日付,時刻,花粉飛散数",
+				"00000001,13,2021-02-01,01:00,1",
+				"00000001,13,2021-02-xx,02:00,1",
+				"",
+				"00000001,13,2021-02-01,25,1",
+				"00000001,13,2021-02-01,04,x",
+				"00000001,13,2021-02-01,05,5",
+			}, "\n"),
+			want: want{
+				data: SokuteiData{
+					{SokuteikyokuCode: "00000001", SokuteiNengappi: "20210201", SokuteiJikoku: "01", TodofukenCode: "13", KafunNum: 1},
+					{SokuteikyokuCode: "00000001", SokuteiNengappi: "20210201", SokuteiJikoku: "05", TodofukenCode: "13", KafunNum: 5},
+				},
+				rowErrors: []*RowError{
+					{Line: 3, Field: "SKT_NNGP"},
+					{Line: 5, Field: "SKT_HH"},
+					{Line: 6, Field: "KFN_NUM"},
+				},
+			},
+		},
+		{
+			name: "standard case: missing pollen count is kept as not observed",
+			input: strings.Join([]string{
+				"局コード,都道府県コード,日付,時刻,花粉飛散数,気温",
+				"00000001,13,2021-02-01,01:00,-,8.5",
+				"00000001,13,2021-02-01,02:00,欠測,",
+			}, "\n"),
+			want: want{
+				data: SokuteiData{
+					{
+						SokuteikyokuCode:  "00000001",
+						SokuteiNengappi:   "20210201",
+						SokuteiJikoku:     "01",
+						TodofukenCode:     "13",
+						AMeDASTemperature: float64PointerHelper(t, 8.5),
+						Problems:          []FieldProblem{{Field: "KFN_NUM", Message: missingValueMessage}},
+					},
+					{
+						SokuteikyokuCode: "00000001",
+						SokuteiNengappi:  "20210201",
+						SokuteiJikoku:    "02",
+						TodofukenCode:    "13",
+						Problems:         []FieldProblem{{Field: "KFN_NUM", Message: missingValueMessage}},
+					},
+				},
+			},
+		},
+		{
+			name:  "error case: required column is missing",
+			input: "測定局コード,年月日,時\n00000001,20210201,01\n",
+			want: want{
+				wantErr: true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := []byte(tt.input)
+			if tt.sjis {
+				input, _ = encodeUTF8ToSJIS(t, input)
+			}
+
+			got, rowErrors, err := ParseCSV(bytes.NewReader(input))
+			if (err != nil) != tt.want.wantErr {
+				t.Fatalf("ParseCSV() error = %v, wantErr %v", err, tt.want.wantErr)
+			}
+			if tt.want.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want.data) {
+				t.Errorf("ParseCSV() got = %v, want %v", got, tt.want.data)
+			}
+			if len(rowErrors) != len(tt.want.rowErrors) {
+				t.Fatalf("ParseCSV() row errors = %v, want %v", rowErrors, tt.want.rowErrors)
+			}
+			for i, rowErr := range rowErrors {
+				if rowErr.Line != tt.want.rowErrors[i].Line || rowErr.Field != tt.want.rowErrors[i].Field {
+					t.Errorf("ParseCSV() row error = %v, want line %d field %s",
+						rowErr, tt.want.rowErrors[i].Line, tt.want.rowErrors[i].Field)
+				}
+			}
+		})
+	}
+}
+
+func Test_normalizeCSVDate(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "20210201", want: "20210201"},
+		{value: "2021/2/1", want: "20210201"},
+		{value: "2021-02-01", want: "20210201"},
+		{value: "2021年2月1日", want: "20210201"},
+		{value: "2021/02", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := normalizeCSVDate(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeCSVDate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeCSVDate() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_normalizeCSVHour(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "1", want: "01"},
+		{value: "01", want: "01"},
+		{value: "1時", want: "01"},
+		{value: "24:00", want: "24"},
+		{value: "25", wantErr: true},
+		{value: "1:30", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := normalizeCSVHour(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeCSVHour() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeCSVHour() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package kafun
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Level は花粉の飛散状況の段階を表す。花粉情報で使われる「少ない」から「極めて多い」までの5段階。
+type Level int
+
+// 花粉の飛散状況の段階。
+const (
+	LevelLow      Level = iota // 少ない
+	LevelModerate              // やや多い
+	LevelHigh                  // 多い
+	LevelVeryHigh              // 非常に多い
+	LevelExtreme               // 極めて多い
+)
+
+// 段階の日本語と英語の表記。添字は Level。
+var (
+	levelJapanese = [...]string{"少ない", "やや多い", "多い", "非常に多い", "極めて多い"}
+	levelEnglish  = [...]string{"low", "moderate", "high", "very_high", "extreme"}
+)
+
+// Japanese は段階の日本語の表記(やや多い など)を返す。
+func (l Level) Japanese() string {
+	if l < LevelLow || l > LevelExtreme {
+		return "Level(" + strconv.Itoa(int(l)) + ")"
+	}
+
+	return levelJapanese[l]
+}
+
+// English は段階の英語の表記(moderate など)を返す。
+func (l Level) English() string {
+	if l < LevelLow || l > LevelExtreme {
+		return "Level(" + strconv.Itoa(int(l)) + ")"
+	}
+
+	return levelEnglish[l]
+}
+
+// String は日本語と英語の表記を「やや多い (moderate)」の形式で返す。
+func (l Level) String() string {
+	return l.Japanese() + " (" + l.English() + ")"
+}
+
+// Thresholds は段階を分ける花粉数(個/立方メートル)の下限。
+// 順に「やや多い」「多い」「非常に多い」「極めて多い」になる最小の花粉数で、それ未満は「少ない」になる。
+type Thresholds [4]int
+
+// DefaultThresholds は1時間の花粉数(個/立方メートル)の段階の下限の近似値。
+// 少ない(0〜9)、やや多い(10〜30)、多い(31〜50)、非常に多い(51〜100)、極めて多い(101以上)。
+// 境界は環境省「花粉症環境保健マニュアル」の1日の花粉数(ダーラム法、個/平方センチメートル)の目安を
+// 借りたもので、1時間の花粉数の公式な段階ではない。公式な段階を使う場合は NewThresholds で作成する。
+var DefaultThresholds = Thresholds{10, 31, 51, 101}
+
+// NewThresholds は段階の下限から Thresholds を作成する。下限は正の数で、段階の順に大きくなければならない。
+func NewThresholds(moderate, high, veryHigh, extreme int) (Thresholds, error) {
+	t := Thresholds{moderate, high, veryHigh, extreme}
+	if err := t.validate(); err != nil {
+		return Thresholds{}, err
+	}
+
+	return t, nil
+}
+
+// ParseThresholds はカンマ区切りの4つの下限("10,31,51,101" など)から Thresholds を作成する。
+func ParseThresholds(s string) (Thresholds, error) {
+	values := strings.Split(s, ",")
+	if len(values) != len(Thresholds{}) {
+		return Thresholds{}, xerrors.Errorf("invalid level thresholds: %q: want %d values", s, len(Thresholds{}))
+	}
+
+	var t Thresholds
+	for i, v := range values {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return Thresholds{}, xerrors.Errorf("invalid level thresholds: %q: %v", s, err)
+		}
+		t[i] = n
+	}
+	if err := t.validate(); err != nil {
+		return Thresholds{}, err
+	}
+
+	return t, nil
+}
+
+func (t Thresholds) validate() error {
+	prev := 0
+	for _, n := range t {
+		if n <= prev {
+			return xerrors.Errorf("invalid level thresholds: %v: must be positive and increasing", [4]int(t))
+		}
+		prev = n
+	}
+
+	return nil
+}
+
+// Classify は花粉数の段階を返す。1日の平均値などの小数も分類できる。
+func (t Thresholds) Classify(kafunNum float64) Level {
+	level := LevelLow
+	for i, n := range t {
+		if kafunNum >= float64(n) {
+			level = Level(i + 1)
+		}
+	}
+
+	return level
+}
+
+// Level は1時間の花粉数の段階を返す。花粉数が不正な値で記録されていない場合は false を返す。
+func (hsd *HourlySokuteiData) Level(t Thresholds) (Level, bool) {
+	if hsd.hasProblem("KFN_NUM") {
+		return LevelLow, false
+	}
+
+	return t.Classify(float64(hsd.KafunNum)), true
+}
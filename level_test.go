@@ -0,0 +1,79 @@
+package kafun
+
+import (
+	"testing"
+)
+
+func TestThresholds_Classify(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds Thresholds
+		kafunNum   float64
+		want       Level
+	}{
+		{name: "standard case: zero", thresholds: DefaultThresholds, kafunNum: 0, want: LevelLow},
+		{name: "standard case: below moderate", thresholds: DefaultThresholds, kafunNum: 9.9, want: LevelLow},
+		{name: "standard case: moderate", thresholds: DefaultThresholds, kafunNum: 10, want: LevelModerate},
+		{name: "standard case: upper bound of moderate", thresholds: DefaultThresholds, kafunNum: 30, want: LevelModerate},
+		{name: "standard case: high", thresholds: DefaultThresholds, kafunNum: 31, want: LevelHigh},
+		{name: "standard case: very high", thresholds: DefaultThresholds, kafunNum: 100, want: LevelVeryHigh},
+		{name: "standard case: extreme", thresholds: DefaultThresholds, kafunNum: 101, want: LevelExtreme},
+		{name: "standard case: custom thresholds", thresholds: Thresholds{1, 2, 3, 4}, kafunNum: 3, want: LevelVeryHigh},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.thresholds.Classify(tt.kafunNum); got != tt.want {
+				t.Errorf("Classify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseThresholds(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Thresholds
+		wantErr bool
+	}{
+		{name: "standard case: standard thresholds", s: "10,31,51,101", want: DefaultThresholds},
+		{name: "standard case: spaces", s: "5, 10, 20, 40", want: Thresholds{5, 10, 20, 40}},
+		{name: "error case: too few values", s: "10,30,50", wantErr: true},
+		{name: "error case: not a number", s: "10,30,many,100", wantErr: true},
+		{name: "error case: not increasing", s: "10,30,30,100", wantErr: true},
+		{name: "error case: zero", s: "0,30,50,100", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseThresholds(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseThresholds() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseThresholds() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewThresholds(50, 30, 60, 100); err == nil {
+		t.Errorf("NewThresholds() error = nil, want error")
+	}
+}
+
+func TestLevel(t *testing.T) {
+	if got := LevelVeryHigh.String(); got != "非常に多い (very_high)" {
+		t.Errorf("String() = %v, want %v", got, "非常に多い (very_high)")
+	}
+	if got := Level(9).Japanese(); got != "Level(9)" {
+		t.Errorf("Japanese() = %v, want %v", got, "Level(9)")
+	}
+
+	hsd := &HourlySokuteiData{KafunNum: 45}
+	if got, ok := hsd.Level(DefaultThresholds); !ok || got != LevelHigh {
+		t.Errorf("HourlySokuteiData.Level() = %v, %v, want %v, true", got, ok, LevelHigh)
+	}
+	hsd.Problems = []FieldProblem{{Field: "KFN_NUM"}}
+	if _, ok := hsd.Level(DefaultThresholds); ok {
+		t.Errorf("HourlySokuteiData.Level() ok = true for invalid KFN_NUM")
+	}
+}
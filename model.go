@@ -2,9 +2,19 @@ package kafun
 
 import (
 	"encoding/json"
+	"sort"
 	"strconv"
+	"time"
+
+	"golang.org/x/xerrors"
 )
 
+// 日本標準時。測定データの日時はすべて日本標準時で表される。
+var jst = time.FixedZone("JST", 9*60*60)
+
+// 測定年月日の形式。
+const sokuteiNengappiLayout = "20060102"
+
 // HourlySokuteiData は時間毎の測定データを表します。
 // 数値型のもので、ゼロではなく、空文字列のものはnullとみなしてJSON出力時には項目を出力しない仕様にしています。
 // 詳細は https://kafun.env.go.jp/apiManual/apiPage2/api-2-3 確認してください
@@ -56,73 +66,122 @@ type HourlySokuteiData struct {
 
 	// レーダー降雨降雪の有無
 	AMeDASRadarPrecipitation *int `json:"AMeDAS_RDPR,omitempty"`
+
+	// DecodeLenient でデコードしたさいに見つかった項目の問題。問題がない場合は nil
+	Problems []FieldProblem `json:"-"`
+
+	// Fill で欠測の時間を補った測定データかどうか
+	Filled bool `json:"-"`
+
+	// CheckQuality で疑わしいとした理由。検査していない場合と疑わしくない場合は0
+	QC QCFlags `json:"-"`
 }
 
 // SokuteiData はData Search APIのレスポンスを表します。
 type SokuteiData []*HourlySokuteiData
 
-// UnmarshalJSON は HourlySokuteiData が Valid な JSON ではないために作成したカスタムUnmarshaler
-//
-// - value が 数値型の場合でもクォートされる。stringタグを使うと出力のさいにクォートついてしまうので対応
-// - 数値で空文字列が入ってくる。ゼロはあるので、こちらはnullとみなして key-valueを生成しない
-func (hsd *HourlySokuteiData) UnmarshalJSON(data []byte) error {
-	var err error
-	var v map[string]interface{}
-	if err = json.Unmarshal(data, &v); err != nil {
-		return err
+// Time は測定年月日と測定時刻から測定日時を日本標準時で返す。
+// 測定時刻はその時刻までの1時間の測定値を表す1〜24で、24時は翌日の0時になる。
+func (hsd *HourlySokuteiData) Time() (time.Time, error) {
+	date, err := time.ParseInLocation(sokuteiNengappiLayout, hsd.SokuteiNengappi, jst)
+	if err != nil {
+		return time.Time{}, xerrors.Errorf("invalid SKT_NNGP: %q", hsd.SokuteiNengappi)
 	}
 
-	hsd.SokuteikyokuCode = v["SKT_CD"].(string)
-	hsd.AMeDASCode = v["AMeDAS_CD"].(string)
-	hsd.SokuteiNengappi = v["SKT_NNGP"].(string)
-	hsd.SokuteiJikoku = v["SKT_HH"].(string)
-	hsd.SokuteikyokuName = v["SKT_NM"].(string)
-	hsd.SokuteiType = v["SKT_TYPE"].(string)
-	hsd.TodofukenCode = v["TDFKN_CD"].(string)
-	hsd.TodofukenName = v["TDFKN_NM"].(string)
-	hsd.SokuteiShichosonCode = v["SKCHSN_CD"].(string)
-	hsd.SokuteiShichosonName = v["SKCHSN_NM"].(string)
-
-	kafunNumStr := v["KFN_NUM"].(string)
-	kafunNumInt, err := strconv.Atoi(kafunNumStr)
-	if err != nil {
-		return err
+	hour, err := strconv.Atoi(hsd.SokuteiJikoku)
+	if err != nil || hour < 0 || hour > 24 {
+		return time.Time{}, xerrors.Errorf("invalid SKT_HH: %q", hsd.SokuteiJikoku)
 	}
-	hsd.KafunNum = kafunNumInt
 
-	hsd.AMeDASWindDirect = v["AMeDAS_WD"].(string)
+	return date.Add(time.Duration(hour) * time.Hour), nil
+}
 
-	intPointerElem, err := validateIntPointerElement(v, "AMeDAS_WS")
-	if err != nil {
-		return err
+// sortKey は並べ替えのために測定日時を解釈した測定データ。
+type sortKey struct {
+	hsd   *HourlySokuteiData
+	time  time.Time
+	valid bool // 測定日時を解釈できたかどうか
+}
+
+// beforeInTime は a の測定日時が b より前かどうかを返す。測定日時を解釈できない測定データは先頭に並べる。
+func (a *sortKey) beforeInTime(b *sortKey) bool {
+	if a.valid != b.valid {
+		return !a.valid
+	}
+	if !a.valid {
+		return a.hsd.SokuteiNengappi+a.hsd.SokuteiJikoku < b.hsd.SokuteiNengappi+b.hsd.SokuteiJikoku
 	}
-	hsd.AMeDASWindSpeed = intPointerElem
 
-	float64PointerElem, err := validateFloat64PointerElement(v, "AMeDAS_TP")
-	if err != nil {
-		return err
+	return a.time.Before(b.time)
+}
+
+func (a *sortKey) equalInTime(b *sortKey) bool {
+	return !a.beforeInTime(b) && !b.beforeInTime(a)
+}
+
+// sortBy は測定データを less の順に安定ソートする。
+func (sd SokuteiData) sortBy(less func(a, b *sortKey) bool) {
+	keys := make([]*sortKey, len(sd))
+	for i, hsd := range sd {
+		t, err := hsd.Time()
+		keys[i] = &sortKey{hsd: hsd, time: t, valid: err == nil}
 	}
-	hsd.AMeDASTemperature = float64PointerElem
 
-	intPointerElem, err = validateIntPointerElement(v, "AMeDAS_PR")
-	if err != nil {
-		return err
+	sort.SliceStable(keys, func(i, j int) bool {
+		return less(keys[i], keys[j])
+	})
+
+	for i, key := range keys {
+		sd[i] = key.hsd
 	}
-	hsd.AMeDASPrecipitation = intPointerElem
+}
 
-	intPointerElem, err = validateIntPointerElement(v, "AMeDAS_RDPR")
-	if err != nil {
+// SortByTime は測定データを測定日時、測定局コードの順に並べ替える。
+// 測定日時を解釈できない測定データは先頭に並べる。
+func (sd SokuteiData) SortByTime() {
+	sd.sortBy(func(a, b *sortKey) bool {
+		if !a.equalInTime(b) {
+			return a.beforeInTime(b)
+		}
+		return a.hsd.SokuteikyokuCode < b.hsd.SokuteikyokuCode
+	})
+}
+
+// SortByStation は測定データを測定局コード、測定日時の順に並べ替える。
+func (sd SokuteiData) SortByStation() {
+	sd.sortBy(func(a, b *sortKey) bool {
+		if a.hsd.SokuteikyokuCode != b.hsd.SokuteikyokuCode {
+			return a.hsd.SokuteikyokuCode < b.hsd.SokuteikyokuCode
+		}
+		return a.beforeInTime(b)
+	})
+}
+
+// UnmarshalJSON は HourlySokuteiData が Valid な JSON ではないために作成したカスタムUnmarshaler
+//
+// - value が 数値型の場合でもクォートされる。stringタグを使うと出力のさいにクォートついてしまうので対応
+// - 数値で空文字列が入ってくる。ゼロはあるので、こちらはnullとみなして key-valueを生成しない
+//
+// このパッケージが出力したJSONも読み込めるように、数値型の項目はクォートされていない数値も受け付ける。
+// 不正な値の項目は DecodeStrict と同じく *DecodeError になる。
+func (hsd *HourlySokuteiData) UnmarshalJSON(data []byte) error {
+	var v map[string]interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
 		return err
 	}
-	hsd.AMeDASRadarPrecipitation = intPointerElem
 
-	return nil
+	return hsd.fromMap(v)
+}
+
+// fromMap はJSONキーと値の対応から DecodeStrict で HourlySokuteiData を作成する。
+func (hsd *HourlySokuteiData) fromMap(v map[string]interface{}) error {
+	return hsd.decodeMap(v, DecodeStrict)
 }
 
 func validateIntPointerElement(v map[string]interface{}, key string) (*int, error) {
 	elem, ok := v[key]
 	if ok {
-		elemStr := elem.(string)
+		elemStr := elementString(elem)
 		if len(elemStr) != 0 {
 			elemInt, err := strconv.Atoi(elemStr)
 			if err != nil {
@@ -139,7 +198,7 @@ func validateIntPointerElement(v map[string]interface{}, key string) (*int, erro
 func validateFloat64PointerElement(v map[string]interface{}, key string) (*float64, error) {
 	elem, ok := v[key]
 	if ok {
-		elemStr := elem.(string)
+		elemStr := elementString(elem)
 		if len(elemStr) != 0 {
 			elemFloat64, err := strconv.ParseFloat(elemStr, 64)
 			if err != nil {
@@ -152,3 +211,16 @@ func validateFloat64PointerElement(v map[string]interface{}, key string) (*float
 
 	return nil, nil
 }
+
+// elementString は項目の値を文字列で返す。クォートされていない数値も文字列に変換し、
+// 項目がない場合やnullの場合は空文字列を返す。
+func elementString(elem interface{}) string {
+	switch e := elem.(type) {
+	case string:
+		return e
+	case float64:
+		return strconv.FormatFloat(e, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
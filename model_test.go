@@ -3,6 +3,7 @@ package kafun
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func intPointerHelper(t *testing.T, i int) *int {
@@ -99,6 +100,43 @@ func TestHourlySokuteiData_UnmarshalJSON(t *testing.T) {
 				AMeDASWindDirect:     "05",
 			},
 		},
+		{
+			name: "standard case: unquoted numbers",
+			args: args{
+				[]byte(`{
+					"SKT_CD": "00000000",
+					"AMeDAS_CD": "00000",
+					"SKT_NNGP": "00000101",
+					"SKT_HH": "01",
+					"SKT_NM": "テスト測定所",
+					"SKT_TYPE": "1",
+					"TDFKN_CD": "00",
+					"TDFKN_NM": "テスト県",
+					"SKCHSN_CD": "00000",
+					"SKCHSN_NM": "テスト市",
+					"KFN_NUM": 4,
+					"AMeDAS_WD": "05",
+					"AMeDAS_WS": 1,
+					"AMeDAS_TP": 15.4
+				}`),
+			},
+			want: &HourlySokuteiData{
+				SokuteikyokuCode:     "00000000",
+				AMeDASCode:           "00000",
+				SokuteiNengappi:      "00000101",
+				SokuteiJikoku:        "01",
+				SokuteikyokuName:     "テスト測定所",
+				SokuteiType:          "1",
+				TodofukenCode:        "00",
+				TodofukenName:        "テスト県",
+				SokuteiShichosonCode: "00000",
+				SokuteiShichosonName: "テスト市",
+				KafunNum:             4,
+				AMeDASWindDirect:     "05",
+				AMeDASWindSpeed:      intPointerHelper(t, 1),
+				AMeDASTemperature:    float64PointerHelper(t, 15.4),
+			},
+		},
 		{
 			name: "error case: KFN_NUM format is not int",
 			args: args{
@@ -370,3 +408,103 @@ func Test_validateFloat64PointerElement(t *testing.T) {
 		})
 	}
 }
+
+func TestHourlySokuteiData_Time(t *testing.T) {
+	tests := []struct {
+		name    string
+		hsd     *HourlySokuteiData
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name: "standard case: hour ending 01",
+			hsd:  &HourlySokuteiData{SokuteiNengappi: "20210201", SokuteiJikoku: "01"},
+			want: time.Date(2021, 2, 1, 1, 0, 0, 0, jst),
+		},
+		{
+			name: "standard case: hour 24 rolls over to next month",
+			hsd:  &HourlySokuteiData{SokuteiNengappi: "20210228", SokuteiJikoku: "24"},
+			want: time.Date(2021, 3, 1, 0, 0, 0, 0, jst),
+		},
+		{
+			name: "standard case: hour 24 rolls over to next year",
+			hsd:  &HourlySokuteiData{SokuteiNengappi: "20211231", SokuteiJikoku: "24"},
+			want: time.Date(2022, 1, 1, 0, 0, 0, 0, jst),
+		},
+		{
+			name:    "error case: invalid date",
+			hsd:     &HourlySokuteiData{SokuteiNengappi: "20210230", SokuteiJikoku: "01"},
+			wantErr: true,
+		},
+		{
+			name:    "error case: hour out of range",
+			hsd:     &HourlySokuteiData{SokuteiNengappi: "20210201", SokuteiJikoku: "25"},
+			wantErr: true,
+		},
+		{
+			name:    "error case: empty hour",
+			hsd:     &HourlySokuteiData{SokuteiNengappi: "20210201"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.hsd.Time()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Time() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) || (!tt.wantErr && got.Location() != jst) {
+				t.Errorf("Time() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSokuteiData_Sort(t *testing.T) {
+	newData := func() SokuteiData {
+		return SokuteiData{
+			{SokuteikyokuCode: "2", SokuteiNengappi: "20210202", SokuteiJikoku: "01"},
+			{SokuteikyokuCode: "1", SokuteiNengappi: "20210201", SokuteiJikoku: "24"},
+			{SokuteikyokuCode: "2", SokuteiNengappi: "20210201", SokuteiJikoku: "02"},
+			{SokuteikyokuCode: "1", SokuteiNengappi: "20210202", SokuteiJikoku: "01"},
+			{SokuteikyokuCode: "1", SokuteiNengappi: "invalid", SokuteiJikoku: "01"},
+		}
+	}
+	codes := func(data SokuteiData) []string {
+		var got []string
+		for _, hsd := range data {
+			got = append(got, hsd.SokuteikyokuCode+"/"+hsd.SokuteiNengappi+"/"+hsd.SokuteiJikoku)
+		}
+		return got
+	}
+
+	t.Run("SortByTime", func(t *testing.T) {
+		data := newData()
+		data.SortByTime()
+		want := []string{
+			"1/invalid/01",
+			"2/20210201/02",
+			"1/20210201/24",
+			"1/20210202/01",
+			"2/20210202/01",
+		}
+		if got := codes(data); !reflect.DeepEqual(got, want) {
+			t.Errorf("SortByTime() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("SortByStation", func(t *testing.T) {
+		data := newData()
+		data.SortByStation()
+		want := []string{
+			"1/invalid/01",
+			"1/20210201/24",
+			"1/20210202/01",
+			"2/20210201/02",
+			"2/20210202/01",
+		}
+		if got := codes(data); !reflect.DeepEqual(got, want) {
+			t.Errorf("SortByStation() = %v, want %v", got, want)
+		}
+	})
+}
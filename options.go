@@ -0,0 +1,89 @@
+package kafun
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option は NewClient で作成するクライアントの設定を変更する。
+type Option func(*Client)
+
+// Logger はクライアントのログの出力先を表す。*log.Logger はこのインターフェースを満たす。
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Cache は検索結果のキャッシュを表す。キーはリクエストURLから作られる。
+// Client は期間が先月以前で、今後データが変わらないリクエストだけをキャッシュする。
+type Cache interface {
+	// Get はキーに対応するキャッシュ済みの検索結果を返す。キャッシュがない場合は false を返す。
+	Get(key string) (SokuteiData, bool)
+
+	// Set はキーに対応する検索結果をキャッシュする。
+	Set(key string, data SokuteiData) error
+}
+
+// WithHTTPClient はリクエストに使うHTTPクライアントを指定する。nil の場合は http.DefaultClient を使う。
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.HTTPClient = httpClient
+	}
+}
+
+// WithTimeout はリクエストのタイムアウトを指定する。
+// タイムアウトはすべてのオプションを適用した後に設定するので、WithHTTPClient と指定する順序によらない。
+// 指定されたHTTPクライアントは変更せず、タイムアウトだけを変えた複製を使う。
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithUserAgent はリクエストの User-Agent ヘッダを指定する。
+func WithUserAgent(ua string) Option {
+	return func(c *Client) {
+		c.UserAgent = ua
+	}
+}
+
+// WithLogger はログの出力先を指定する。
+func WithLogger(logger Logger) Option {
+	return func(c *Client) {
+		c.Logger = logger
+	}
+}
+
+// WithRateLimiter は流量制限を指定する。
+func WithRateLimiter(limiter RateLimiter) Option {
+	return func(c *Client) {
+		c.RateLimiter = limiter
+	}
+}
+
+// WithRetryPolicy は再試行の方針を指定する。
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.RetryPolicy = policy
+	}
+}
+
+// WithCache は検索結果のキャッシュを指定する。
+func WithCache(cache Cache) Option {
+	return func(c *Client) {
+		c.Cache = cache
+	}
+}
+
+// WithDecodeMode はレスポンスの項目に不正な値があった場合の扱いを指定する。
+func WithDecodeMode(mode DecodeMode) Option {
+	return func(c *Client) {
+		c.DecodeMode = mode
+	}
+}
+
+// logf は Logger が指定されている場合にログを出力する。
+func (c *Client) logf(format string, v ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, v...)
+	}
+}
@@ -0,0 +1,158 @@
+package kafun
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryCache はテスト用のメモリ上の Cache。
+type memoryCache struct {
+	mu   sync.Mutex
+	data map[string]SokuteiData
+}
+
+func (m *memoryCache) Get(key string) (SokuteiData, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.data[key]
+	return data, ok
+}
+
+func (m *memoryCache) Set(key string, data SokuteiData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data == nil {
+		m.data = make(map[string]SokuteiData)
+	}
+	m.data[key] = data
+	return nil
+}
+
+func TestNewClient_options(t *testing.T) {
+	customHTTPClient := &http.Client{Timeout: time.Second}
+	logger := log.New(new(bytes.Buffer), "", 0)
+	limiter := NewTokenBucket(1, 1)
+	policy := NewBackoffRetryPolicy(3)
+	cache := &memoryCache{}
+
+	c, err := NewClient(
+		DefaultEndpoint,
+		WithHTTPClient(customHTTPClient),
+		WithTimeout(5*time.Second),
+		WithUserAgent("test-agent"),
+		WithLogger(logger),
+		WithRateLimiter(limiter),
+		WithRetryPolicy(policy),
+		WithCache(cache),
+		WithDecodeMode(DecodeLenient),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	if c.HTTPClient.Timeout != 5*time.Second {
+		t.Errorf("NewClient() timeout = %v, want %v", c.HTTPClient.Timeout, 5*time.Second)
+	}
+	if customHTTPClient.Timeout != time.Second {
+		t.Errorf("WithTimeout() modified given http client: timeout = %v", customHTTPClient.Timeout)
+	}
+	if c.UserAgent != "test-agent" {
+		t.Errorf("NewClient() user agent = %v, want %v", c.UserAgent, "test-agent")
+	}
+	if c.Logger != logger || c.RateLimiter != limiter || c.RetryPolicy != policy || c.Cache != cache ||
+		c.DecodeMode != DecodeLenient {
+		t.Errorf("NewClient() options are not applied: %+v", c)
+	}
+}
+
+func TestWithTimeout_defaultClient(t *testing.T) {
+	if _, err := NewClient(DefaultEndpoint, WithTimeout(time.Second)); err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if http.DefaultClient.Timeout != 0 {
+		t.Errorf("WithTimeout() modified http.DefaultClient: timeout = %v", http.DefaultClient.Timeout)
+	}
+}
+
+func TestWithTimeout_order(t *testing.T) {
+	customHTTPClient := &http.Client{Timeout: time.Second}
+	tests := []struct {
+		name string
+		opts []Option
+		want time.Duration
+	}{
+		{
+			name: "standard case: timeout before http client",
+			opts: []Option{WithTimeout(5 * time.Second), WithHTTPClient(customHTTPClient)},
+			want: 5 * time.Second,
+		},
+		{
+			name: "standard case: nil http client falls back to default client",
+			opts: []Option{WithHTTPClient(nil), WithTimeout(5 * time.Second)},
+			want: 5 * time.Second,
+		},
+		{
+			name: "standard case: nil http client without timeout",
+			opts: []Option{WithHTTPClient(nil)},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient(DefaultEndpoint, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			if c.HTTPClient == nil {
+				t.Fatalf("NewClient() http client = nil")
+			}
+			if c.HTTPClient.Timeout != tt.want {
+				t.Errorf("NewClient() timeout = %v, want %v", c.HTTPClient.Timeout, tt.want)
+			}
+		})
+	}
+	if customHTTPClient.Timeout != time.Second || http.DefaultClient.Timeout != 0 {
+		t.Errorf("WithTimeout() modified given http clients")
+	}
+}
+
+func TestClient_Search_userAgentAndCache(t *testing.T) {
+	sjisStr, _ := encodeUTF8ToSJIS(t, sokuteiDataByteOmitOptional)
+	var userAgents []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents = append(userAgents, r.UserAgent())
+		w.WriteHeader(http.StatusOK)
+		w.Write(sjisStr)
+	}))
+	defer testServer.Close()
+
+	logOut := new(bytes.Buffer)
+	c, _ := NewClient(
+		testServer.URL,
+		WithUserAgent("test-agent"),
+		WithCache(&memoryCache{}),
+		WithLogger(log.New(logOut, "", 0)),
+	)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Search(ctx, validSearchParam); err != nil {
+			t.Fatalf("Search() error = %v", err)
+		}
+	}
+
+	if len(userAgents) != 1 {
+		t.Fatalf("Search() requests = %v, want 1 (second search should be served from cache)", len(userAgents))
+	}
+	if userAgents[0] != "test-agent" {
+		t.Errorf("Search() user agent = %v, want %v", userAgents[0], "test-agent")
+	}
+	if !strings.Contains(logOut.String(), "cache hit") {
+		t.Errorf("Search() log = %v, want cache hit", logOut.String())
+	}
+}
@@ -0,0 +1,622 @@
+package kafun
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/transform"
+	"golang.org/x/xerrors"
+)
+
+// 出力形式。
+const (
+	FormatJSON   = "json"   // インデント付きのJSON配列
+	FormatNDJSON = "ndjson" // 1行に1件のJSON
+	FormatCSV    = "csv"    // カンマ区切り
+	FormatTSV    = "tsv"    // タブ区切り
+	FormatTable  = "table"  // 端末で読むための列をそろえた表
+)
+
+// isStreamingFormat は測定データを受け取ったそばから出力できる形式かどうかを返す。
+func isStreamingFormat(format string) bool {
+	switch format {
+	case FormatNDJSON, FormatCSV, FormatTSV:
+		return true
+	default:
+		return false
+	}
+}
+
+// 項目名の形式。
+const (
+	FieldNamesAPI = "api" // APIのJSONキー (SKT_CD など)
+	FieldNamesGo  = "go"  // HourlySokuteiData のフィールド名 (SokuteikyokuCode など)
+)
+
+// outputColumn は出力する列を表す。
+type outputColumn struct {
+	Name   string // 列名(APIのJSONキー)
+	GoName string // HourlySokuteiData のフィールド名
+	index  int    // HourlySokuteiData のフィールドの位置。測定データから計算する列の場合は -1
+
+	// compute は測定データから計算する列の値を返す。値がない場合は false を返す。
+	compute func(hsd *HourlySokuteiData) (interface{}, bool)
+
+	// tableValue は table 形式で compute の代わりに表示する値を返す。nil の場合は compute の値を表示する。
+	tableValue func(hsd *HourlySokuteiData) (interface{}, bool)
+}
+
+// timestampColumn は測定日時をRFC 3339で出力する列。
+var timestampColumn = &outputColumn{
+	Name:   "timestamp",
+	GoName: "Timestamp",
+	index:  -1,
+	compute: func(hsd *HourlySokuteiData) (interface{}, bool) {
+		t, err := hsd.Time()
+		if err != nil {
+			return nil, false
+		}
+		return t.Format(time.RFC3339), true
+	},
+}
+
+// windDirectionColumn は風向の英語の略記(NNE など)を出力する列。欠測の場合は値がない。
+var windDirectionColumn = &outputColumn{
+	Name:   "wind_direction",
+	GoName: "WindDirection",
+	index:  -1,
+	compute: func(hsd *HourlySokuteiData) (interface{}, bool) {
+		wd, err := hsd.WindDirection()
+		if err != nil || wd.IsMissing() {
+			return nil, false
+		}
+		return wd.English(), true
+	},
+}
+
+// levelColumn は花粉数の標準的な段階を英語の表記(moderate など)で出力する列。
+var levelColumn = newLevelColumn(DefaultThresholds)
+
+// newLevelColumn は花粉数の段階を出力する列を作成する。table 形式では日本語の表記(やや多い など)で表示する。
+func newLevelColumn(t Thresholds) *outputColumn {
+	level := func(name func(Level) string) func(*HourlySokuteiData) (interface{}, bool) {
+		return func(hsd *HourlySokuteiData) (interface{}, bool) {
+			l, ok := hsd.Level(t)
+			if !ok {
+				return nil, false
+			}
+			return name(l), true
+		}
+	}
+
+	return &outputColumn{
+		Name:       "level",
+		GoName:     "Level",
+		index:      -1,
+		compute:    level(Level.English),
+		tableValue: level(Level.Japanese),
+	}
+}
+
+// filledColumn は Fill で補った測定データかどうかを出力する列。table 形式では補った測定データだけ「補完」と表示する。
+var filledColumn = &outputColumn{
+	Name:   "filled",
+	GoName: "Filled",
+	index:  -1,
+	compute: func(hsd *HourlySokuteiData) (interface{}, bool) {
+		return hsd.Filled, true
+	},
+	tableValue: func(hsd *HourlySokuteiData) (interface{}, bool) {
+		if !hsd.Filled {
+			return nil, false
+		}
+		return "補完", true
+	},
+}
+
+// qcColumn は CheckQuality で疑わしいとした理由を英語の表記(spike;neighbor など)で出力する列。
+// 疑わしくない場合は空文字列、検査しない測定データは値がない。table 形式では日本語の表記で表示する。
+var qcColumn = newQCColumn()
+
+func newQCColumn() *outputColumn {
+	qc := func(name func(QCFlags) string) func(*HourlySokuteiData) (interface{}, bool) {
+		return func(hsd *HourlySokuteiData) (interface{}, bool) {
+			if _, err := hsd.Time(); err != nil || hsd.Filled || hsd.hasProblem("KFN_NUM") {
+				return nil, false
+			}
+			return name(hsd.QC), true
+		}
+	}
+
+	return &outputColumn{
+		Name:       "qc",
+		GoName:     "QC",
+		index:      -1,
+		compute:    qc(QCFlags.English),
+		tableValue: qc(QCFlags.Japanese),
+	}
+}
+
+// computedColumns は測定データから計算する列。-fields で指定したときや、出力の設定で追加したときに出力する。
+var computedColumns = []*outputColumn{timestampColumn, windDirectionColumn, levelColumn, filledColumn, qcColumn}
+
+// selectableColumns は -fields で指定できる列。
+var selectableColumns = append(append([]*outputColumn(nil), sokuteiDataColumns...), computedColumns...)
+
+// sokuteiDataColumns は HourlySokuteiData のJSONタグから作った、フィールド順の出力列。
+var sokuteiDataColumns = newSokuteiDataColumns()
+
+func newSokuteiDataColumns() []*outputColumn {
+	t := reflect.TypeOf(HourlySokuteiData{})
+	columns := make([]*outputColumn, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if len(name) == 0 || name == "-" {
+			continue
+		}
+		columns = append(columns, &outputColumn{Name: name, GoName: t.Field(i).Name, index: i})
+	}
+
+	return columns
+}
+
+// label は項目名の形式に応じた列名を返す。
+func (col *outputColumn) label(goNames bool) string {
+	if goNames {
+		return col.GoName
+	}
+
+	return col.Name
+}
+
+// field は測定データの列の値を返す。nil のポインタの場合や値がない場合は false を返す。
+func (col *outputColumn) field(hsd *HourlySokuteiData) (reflect.Value, bool) {
+	if col.compute != nil {
+		v, ok := col.compute(hsd)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		return reflect.ValueOf(v), true
+	}
+
+	v := reflect.ValueOf(hsd).Elem().Field(col.index)
+	if hsd.isMissing(col.Name) {
+		// 欠測した項目や、補った測定データで値を補わなかった項目
+		return v, false
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+
+	return v, true
+}
+
+// value は測定データの列の値を文字列で返す。nil のポインタや値がない場合は空文字列になる。
+func (col *outputColumn) value(hsd *HourlySokuteiData) string {
+	v, ok := col.field(hsd)
+	if !ok {
+		return ""
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// numeric は列の値が数値かどうかを返す。
+func (col *outputColumn) numeric() bool {
+	if col.compute != nil {
+		return false
+	}
+
+	t := reflect.TypeOf(HourlySokuteiData{}).Field(col.index).Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.Kind() == reflect.Int || t.Kind() == reflect.Float64
+}
+
+// selectOutputColumns はカンマ区切りの項目名に対応する出力列を返す。
+// 項目名はAPIのJSONキーとフィールド名のどちらでもよく、大文字小文字を区別しない。
+func selectOutputColumns(fields string) ([]*outputColumn, error) {
+	var columns []*outputColumn
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+
+		var found *outputColumn
+		for _, col := range selectableColumns {
+			if strings.EqualFold(field, col.Name) || strings.EqualFold(field, col.GoName) {
+				found = col
+				break
+			}
+		}
+		if found == nil {
+			return nil, xerrors.Errorf("unknown output field: %s", field)
+		}
+		columns = append(columns, found)
+	}
+
+	if len(columns) == 0 {
+		return nil, xerrors.New("no output fields")
+	}
+
+	return columns, nil
+}
+
+// outputProjection は出力する列と列名の形式を表す。
+type outputProjection struct {
+	columns []*outputColumn // 出力する列。nil の場合はすべての列
+	extra   []*outputColumn // 出力する列の後に追加する列
+	goNames bool            // 列名にフィールド名を使う
+}
+
+// selected は出力する列を返す。
+func (p *outputProjection) selected() []*outputColumn {
+	columns := p.columns
+	if columns == nil {
+		columns = sokuteiDataColumns
+	}
+	if len(p.extra) == 0 {
+		return columns
+	}
+
+	return append(append([]*outputColumn(nil), columns...), p.extra...)
+}
+
+// replace は出力する列の old を col に置き換える。
+func (p *outputProjection) replace(old, col *outputColumn) {
+	for i, c := range p.columns {
+		if c == old {
+			p.columns[i] = col
+		}
+	}
+}
+
+// addExtra は出力する列の後に列を追加する。すでに出力する列の場合は追加しない。
+func (p *outputProjection) addExtra(col *outputColumn) {
+	for _, c := range p.selected() {
+		if c == col {
+			return
+		}
+	}
+
+	p.extra = append(p.extra, col)
+}
+
+// header は出力する列の列名を返す。
+func (p *outputProjection) header() []string {
+	columns := p.selected()
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.label(p.goNames)
+	}
+
+	return header
+}
+
+// jsonRecord はJSONで出力する値を返す。すべての列をAPIのJSONキーで出力する場合は測定データそのものを返すが、
+// 欠測した花粉数を0と区別して null で出力するため、花粉数が欠測した測定データは列ごとに出力する。
+func (p *outputProjection) jsonRecord(hsd *HourlySokuteiData) interface{} {
+	if p.columns == nil && len(p.extra) == 0 && !p.goNames && !hsd.isMissing("KFN_NUM") {
+		return hsd
+	}
+
+	return &projectedRecord{hsd: hsd, projection: p}
+}
+
+// projectedRecord は選択した列だけを列の順に出力する測定データ。
+// HourlySokuteiData と同じく、nil のポインタの項目は出力しない。欠測した項目は null で出力する。
+type projectedRecord struct {
+	hsd        *HourlySokuteiData
+	projection *outputProjection
+}
+
+func (r *projectedRecord) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, col := range r.projection.selected() {
+		v, ok := col.field(r.hsd)
+		missing := !ok && col.compute == nil && r.hsd.isMissing(col.Name)
+		if !ok && !missing {
+			continue
+		}
+
+		key, err := json.Marshal(col.label(r.projection.goNames))
+		if err != nil {
+			return nil, err
+		}
+		value := []byte("null")
+		if ok {
+			if value, err = json.Marshal(v.Interface()); err != nil {
+				return nil, err
+			}
+		}
+
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// outputOptions は出力の設定を表す。
+type outputOptions struct {
+	format     string // 出力形式
+	noHeader   bool   // CSV、TSV、表でヘッダ行を出力しない
+	sjis       bool   // Shift-JISで出力する
+	width      int    // 表の最大の表示幅。0以下の場合は切り詰めない
+	fields     string // 出力する項目のカンマ区切り。空文字列の場合はすべての項目
+	fieldNames string // 項目名の形式 (api, go)。空文字列の場合は api
+	timestamp  bool   // 測定日時の timestamp 列を追加する
+
+	level           bool   // 花粉数の段階の level 列を追加する
+	levelThresholds string // 段階の下限のカンマ区切り。空文字列の場合は DefaultThresholds
+
+	filled bool // 補った測定データかどうかの filled 列を追加する
+	qc     bool // 品質管理で疑わしいとした理由の qc 列を追加する
+}
+
+// recordWriter は測定データを1件ずつ出力する。
+type recordWriter interface {
+	// Write は測定データを1件出力する。
+	Write(hsd *HourlySokuteiData) error
+
+	// Close はバッファした出力を書き出す。
+	Close() error
+}
+
+// newRecordWriter は出力形式に応じた recordWriter を作成する。
+func newRecordWriter(w io.Writer, opts *outputOptions) (recordWriter, error) {
+	projection := &outputProjection{}
+	switch opts.fieldNames {
+	case "", FieldNamesAPI:
+	case FieldNamesGo:
+		projection.goNames = true
+	default:
+		return nil, xerrors.Errorf("unknown field names: %s", opts.fieldNames)
+	}
+	if len(opts.fields) != 0 {
+		columns, err := selectOutputColumns(opts.fields)
+		if err != nil {
+			return nil, err
+		}
+		projection.columns = columns
+	}
+	if opts.timestamp {
+		projection.addExtra(timestampColumn)
+	}
+	if len(opts.levelThresholds) != 0 {
+		t, err := ParseThresholds(opts.levelThresholds)
+		if err != nil {
+			return nil, err
+		}
+		// -fields で指定した level 列も指定した下限で分類する
+		col := newLevelColumn(t)
+		projection.replace(levelColumn, col)
+		if opts.level {
+			projection.addExtra(col)
+		}
+	} else if opts.level {
+		projection.addExtra(levelColumn)
+	}
+	if opts.filled {
+		projection.addExtra(filledColumn)
+	}
+	if opts.qc {
+		projection.addExtra(qcColumn)
+	}
+
+	var closer io.Closer
+	if opts.sjis {
+		// Excelで開けるように、Shift-JISで表せない文字は置き換えて出力する
+		encoder := encoding.ReplaceUnsupported(japanese.ShiftJIS.NewEncoder())
+		sjisWriter := transform.NewWriter(w, encoder)
+		w = sjisWriter
+		closer = sjisWriter
+	}
+
+	var rw recordWriter
+	switch opts.format {
+	case "", FormatJSON:
+		rw = &jsonRecordWriter{w: w, projection: projection}
+	case FormatNDJSON:
+		rw = &ndjsonRecordWriter{encoder: json.NewEncoder(w), projection: projection}
+	case FormatCSV, FormatTSV:
+		csvWriter := csv.NewWriter(w)
+		if opts.format == FormatTSV {
+			csvWriter.Comma = '\t'
+		}
+		rw = &csvRecordWriter{w: csvWriter, projection: projection, header: !opts.noHeader}
+	case FormatTable:
+		rw = &tableRecordWriter{w: w, columns: newTableColumns(projection), maxWidth: opts.width, noHeader: opts.noHeader}
+	default:
+		return nil, xerrors.Errorf("unknown output format: %s", opts.format)
+	}
+
+	if closer != nil {
+		rw = &closingRecordWriter{recordWriter: rw, closer: closer}
+	}
+
+	return rw, nil
+}
+
+// jsonRecordWriter はすべての測定データをインデント付きのJSON配列で出力する。
+type jsonRecordWriter struct {
+	w          io.Writer
+	projection *outputProjection
+	records    []interface{}
+}
+
+func (jw *jsonRecordWriter) Write(hsd *HourlySokuteiData) error {
+	jw.records = append(jw.records, jw.projection.jsonRecord(hsd))
+	return nil
+}
+
+func (jw *jsonRecordWriter) Close() error {
+	records := jw.records
+	if records == nil {
+		// 測定データがない場合も空の配列を出力する
+		records = []interface{}{}
+	}
+
+	printJSON, err := json.MarshalIndent(records, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(jw.w, "%s", printJSON)
+	return err
+}
+
+// ndjsonRecordWriter は測定データを1件ずつ1行のJSONで出力する。
+type ndjsonRecordWriter struct {
+	encoder    *json.Encoder
+	projection *outputProjection
+}
+
+func (nw *ndjsonRecordWriter) Write(hsd *HourlySokuteiData) error {
+	return nw.encoder.Encode(nw.projection.jsonRecord(hsd))
+}
+
+func (nw *ndjsonRecordWriter) Close() error {
+	return nil
+}
+
+// csvRecordWriter は測定データをCSVないしはTSVで出力する。
+type csvRecordWriter struct {
+	w           *csv.Writer
+	projection  *outputProjection
+	header      bool
+	wroteHeader bool
+}
+
+func (cw *csvRecordWriter) Write(hsd *HourlySokuteiData) error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+
+	columns := cw.projection.selected()
+	record := make([]string, len(columns))
+	for i, col := range columns {
+		record[i] = col.value(hsd)
+	}
+
+	return cw.w.Write(record)
+}
+
+func (cw *csvRecordWriter) Close() error {
+	// 測定データがない場合もヘッダは出力する
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *csvRecordWriter) writeHeader() error {
+	if !cw.header || cw.wroteHeader {
+		return nil
+	}
+	cw.wroteHeader = true
+
+	return cw.w.Write(cw.projection.header())
+}
+
+// closingRecordWriter は出力の後に文字コード変換などの Writer を閉じる。
+type closingRecordWriter struct {
+	recordWriter
+	closer io.Closer
+}
+
+func (w *closingRecordWriter) Close() error {
+	if err := w.recordWriter.Close(); err != nil {
+		return err
+	}
+
+	return w.closer.Close()
+}
+
+// summaryReport は gaps、qc、season、stations サブコマンドの集計結果の出力を表す。
+type summaryReport struct {
+	columns   []*tableColumn // table の列
+	header    []string       // CSVの見出し。JSONキーと同じにする
+	records   interface{}    // JSONで出力する値
+	rows      [][]string     // CSVの行
+	tableRows [][]string     // table の行。nil の場合は rows を使う
+}
+
+// write は集計結果を出力形式に応じて出力する。
+func (r *summaryReport) write(w io.Writer, opts *outputOptions) error {
+	switch opts.format {
+	case "", FormatTable:
+		rows := r.tableRows
+		if rows == nil {
+			rows = r.rows
+		}
+		return renderTable(w, r.columns, rows, opts.noHeader, opts.width)
+
+	case FormatJSON:
+		b, err := json.MarshalIndent(r.records, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(b, '\n'))
+		return err
+
+	case FormatCSV:
+		csvWriter := csv.NewWriter(w)
+		if !opts.noHeader {
+			if err := csvWriter.Write(r.header); err != nil {
+				return err
+			}
+		}
+		for _, row := range r.rows {
+			if err := csvWriter.Write(row); err != nil {
+				return err
+			}
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+
+	default:
+		return xerrors.Errorf("unknown output format: %s", opts.format)
+	}
+}
+
+// orDash は空文字列の場合に表で値がないことを表す "-" を返す。
+func orDash(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+
+	return s
+}
@@ -0,0 +1,321 @@
+package kafun
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func outputFixture(t *testing.T) SokuteiData {
+	t.Helper()
+	return SokuteiData{
+		{
+			SokuteikyokuCode:     "51320100",
+			AMeDASCode:           "44132",
+			SokuteiNengappi:      "20210201",
+			SokuteiJikoku:        "01",
+			SokuteikyokuName:     "新宿区役所第二分庁舎",
+			SokuteiType:          "1",
+			TodofukenCode:        "13",
+			TodofukenName:        "東京都",
+			SokuteiShichosonCode: "13104",
+			SokuteiShichosonName: "新宿区",
+			KafunNum:             12,
+			AMeDASWindDirect:     "05",
+			AMeDASWindSpeed:      intPointerHelper(t, 2),
+			AMeDASTemperature:    float64PointerHelper(t, 8.5),
+		},
+	}
+}
+
+func Test_newRecordWriter(t *testing.T) {
+	const csvHeader = "SKT_CD,AMeDAS_CD,SKT_NNGP,SKT_HH,SKT_NM,SKT_TYPE,TDFKN_CD,TDFKN_NM,SKCHSN_CD,SKCHSN_NM," +
+		"KFN_NUM,AMeDAS_WD,AMeDAS_WS,AMeDAS_TP,AMeDAS_PR,AMeDAS_RDPR\n"
+	const csvRow = "51320100,44132,20210201,01,新宿区役所第二分庁舎,1,13,東京都,13104,新宿区,12,05,2,8.5,,\n"
+	tests := []struct {
+		name    string
+		opts    *outputOptions
+		data    SokuteiData
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "standard case: csv with header",
+			opts: &outputOptions{format: FormatCSV},
+			data: outputFixture(t),
+			want: csvHeader + csvRow,
+		},
+		{
+			name: "standard case: csv without header",
+			opts: &outputOptions{format: FormatCSV, noHeader: true},
+			data: outputFixture(t),
+			want: csvRow,
+		},
+		{
+			name: "standard case: tsv",
+			opts: &outputOptions{format: FormatTSV, noHeader: true},
+			data: outputFixture(t),
+			want: strings.ReplaceAll(csvRow, ",", "\t"),
+		},
+		{
+			name: "standard case: csv header without data",
+			opts: &outputOptions{format: FormatCSV},
+			want: csvHeader,
+		},
+		{
+			name: "standard case: ndjson",
+			opts: &outputOptions{format: FormatNDJSON},
+			data: SokuteiData{{SokuteikyokuCode: "00000001"}, {SokuteikyokuCode: "00000002"}},
+			want: `{"SKT_CD":"00000001",` + `"AMeDAS_CD":"","SKT_NNGP":"","SKT_HH":"","SKT_NM":"","SKT_TYPE":"",` +
+				`"TDFKN_CD":"","TDFKN_NM":"","SKCHSN_CD":"","SKCHSN_NM":"","KFN_NUM":0,"AMeDAS_WD":""}` + "\n" +
+				`{"SKT_CD":"00000002",`,
+		},
+		{
+			name: "standard case: json",
+			opts: &outputOptions{format: FormatJSON},
+			data: SokuteiData{{SokuteikyokuCode: "00000000"}},
+			want: "[\n\t{\n\t\t\"SKT_CD\": \"00000000\",",
+		},
+		{
+			name: "standard case: json without data",
+			opts: &outputOptions{format: FormatJSON},
+			want: "[]",
+		},
+		{
+			name: "standard case: csv with selected fields",
+			opts: &outputOptions{format: FormatCSV, fields: "SKT_NNGP, skt_hh,KFN_NUM,AMeDAS_PR"},
+			data: outputFixture(t),
+			want: "SKT_NNGP,SKT_HH,KFN_NUM,AMeDAS_PR\n20210201,01,12,\n",
+		},
+		{
+			name: "standard case: csv with go field names",
+			opts: &outputOptions{format: FormatCSV, fields: "SokuteiNengappi,KFN_NUM", fieldNames: FieldNamesGo},
+			data: outputFixture(t),
+			want: "SokuteiNengappi,KafunNum\n20210201,12\n",
+		},
+		{
+			name: "standard case: ndjson with selected fields omits null",
+			opts: &outputOptions{format: FormatNDJSON, fields: "KFN_NUM,SKT_NM,AMeDAS_TP,AMeDAS_PR"},
+			data: outputFixture(t),
+			want: `{"KFN_NUM":12,"SKT_NM":"新宿区役所第二分庁舎","AMeDAS_TP":8.5}` + "\n",
+		},
+		{
+			name: "standard case: ndjson with go field names",
+			opts: &outputOptions{format: FormatNDJSON, fieldNames: FieldNamesGo},
+			data: SokuteiData{{SokuteikyokuCode: "00000001"}},
+			want: `{"SokuteikyokuCode":"00000001","AMeDASCode":"",`,
+		},
+		{
+			name: "standard case: json with selected fields",
+			opts: &outputOptions{format: FormatJSON, fields: "SKT_CD,KFN_NUM"},
+			data: outputFixture(t),
+			want: "[\n\t{\n\t\t\"SKT_CD\": \"51320100\",\n\t\t\"KFN_NUM\": 12\n\t}\n]",
+		},
+		{
+			name: "standard case: table with selected fields",
+			opts: &outputOptions{format: FormatTable, fields: "SKCHSN_NM,KFN_NUM"},
+			data: outputFixture(t),
+			want: "SKCHSN_NM  KFN_NUM\n新宿区          12\n",
+		},
+		{
+			name: "standard case: ndjson with timestamp",
+			opts: &outputOptions{format: FormatNDJSON, fields: "SKT_CD,KFN_NUM", timestamp: true},
+			data: append(outputFixture(t), &HourlySokuteiData{SokuteikyokuCode: "00000000", SokuteiNengappi: "20210228", SokuteiJikoku: "24"}),
+			want: `{"SKT_CD":"51320100","KFN_NUM":12,"timestamp":"2021-02-01T01:00:00+09:00"}` + "\n" +
+				`{"SKT_CD":"00000000","KFN_NUM":0,"timestamp":"2021-03-01T00:00:00+09:00"}` + "\n",
+		},
+		{
+			name: "standard case: csv with timestamp field",
+			opts: &outputOptions{format: FormatCSV, fields: "timestamp,KFN_NUM", timestamp: true},
+			data: append(outputFixture(t), &HourlySokuteiData{SokuteiNengappi: "invalid"}),
+			want: "timestamp,KFN_NUM\n2021-02-01T01:00:00+09:00,12\n,0\n",
+		},
+		{
+			name: "standard case: json with timestamp appended to all fields",
+			opts: &outputOptions{format: FormatNDJSON, timestamp: true},
+			data: SokuteiData{{SokuteiNengappi: "20210201", SokuteiJikoku: "01"}},
+			want: `{"SKT_CD":"","AMeDAS_CD":"","SKT_NNGP":"20210201","SKT_HH":"01","SKT_NM":"","SKT_TYPE":"",` +
+				`"TDFKN_CD":"","TDFKN_NM":"","SKCHSN_CD":"","SKCHSN_NM":"","KFN_NUM":0,"AMeDAS_WD":"",` +
+				`"timestamp":"2021-02-01T01:00:00+09:00"}` + "\n",
+		},
+		{
+			name: "standard case: csv with wind direction field",
+			opts: &outputOptions{format: FormatCSV, fields: "AMeDAS_WD,wind_direction"},
+			data: append(outputFixture(t), &HourlySokuteiData{AMeDASWindDirect: "00"}, &HourlySokuteiData{}),
+			want: "AMeDAS_WD,wind_direction\n05,ESE\n00,CALM\n,\n",
+		},
+		{
+			name: "standard case: csv with level",
+			opts: &outputOptions{format: FormatCSV, fields: "KFN_NUM", level: true},
+			data: append(outputFixture(t), &HourlySokuteiData{KafunNum: 150}, &HourlySokuteiData{
+				Problems: []FieldProblem{{Field: "KFN_NUM", Value: "-", Message: "invalid"}},
+			}),
+			want: "KFN_NUM,level\n12,moderate\n150,extreme\n0,\n",
+		},
+		{
+			name: "standard case: ndjson with level field and custom thresholds",
+			opts: &outputOptions{format: FormatNDJSON, fields: "level,KFN_NUM", level: true, levelThresholds: "5,10,20,40"},
+			data: outputFixture(t),
+			want: `{"level":"high","KFN_NUM":12}` + "\n",
+		},
+		{
+			name: "standard case: table shows level in japanese",
+			opts: &outputOptions{format: FormatTable, fields: "KFN_NUM", level: true},
+			data: outputFixture(t),
+			want: "KFN_NUM  level\n     12  やや多い\n",
+		},
+		{
+			name: "standard case: csv with filled",
+			opts: &outputOptions{format: FormatCSV, fields: "SKT_HH,KFN_NUM,AMeDAS_TP", filled: true},
+			data: append(outputFixture(t), &HourlySokuteiData{
+				SokuteiJikoku: "02",
+				Filled:        true,
+				Problems:      []FieldProblem{{Field: "KFN_NUM", Message: "not observed"}},
+			}),
+			want: "SKT_HH,KFN_NUM,AMeDAS_TP,filled\n01,12,8.5,false\n02,,,true\n",
+		},
+		{
+			name: "standard case: ndjson with filled",
+			opts: &outputOptions{format: FormatNDJSON, fields: "SKT_HH,KFN_NUM", filled: true},
+			data: append(outputFixture(t), &HourlySokuteiData{
+				SokuteiJikoku: "02",
+				Filled:        true,
+				Problems:      []FieldProblem{{Field: "KFN_NUM", Message: "not observed"}},
+			}),
+			want: `{"SKT_HH":"01","KFN_NUM":12,"filled":false}` + "\n" + `{"SKT_HH":"02","KFN_NUM":null,"filled":true}` + "\n",
+		},
+		{
+			name: "standard case: ndjson prints missing pollen count as null",
+			opts: &outputOptions{format: FormatNDJSON},
+			data: SokuteiData{&HourlySokuteiData{
+				SokuteikyokuCode: "1",
+				SokuteiJikoku:    "03",
+				Problems:         []FieldProblem{{Field: "KFN_NUM", Value: "-", Message: missingValueMessage}},
+			}},
+			want: `{"SKT_CD":"1","AMeDAS_CD":"","SKT_NNGP":"","SKT_HH":"03","SKT_NM":"","SKT_TYPE":"","TDFKN_CD":"",` +
+				`"TDFKN_NM":"","SKCHSN_CD":"","SKCHSN_NM":"","KFN_NUM":null,"AMeDAS_WD":""}` + "\n",
+		},
+		{
+			name: "standard case: table shows filled rows",
+			opts: &outputOptions{format: FormatTable, fields: "SKT_HH,KFN_NUM", filled: true},
+			data: append(outputFixture(t), &HourlySokuteiData{SokuteiJikoku: "02", KafunNum: 0, Filled: true}),
+			want: "SKT_HH  KFN_NUM  filled\n01           12\n02            0  補完\n",
+		},
+		{
+			name: "standard case: csv with qc",
+			opts: &outputOptions{format: FormatCSV, fields: "KFN_NUM", qc: true},
+			data: append(outputFixture(t),
+				&HourlySokuteiData{SokuteiNengappi: "20210201", SokuteiJikoku: "02", KafunNum: 3000, QC: QCSpike | QCNeighbor},
+				&HourlySokuteiData{SokuteiNengappi: "20210201", SokuteiJikoku: "03", Filled: true},
+			),
+			want: "KFN_NUM,qc\n12,\n3000,spike;neighbor\n0,\n",
+		},
+		{
+			name: "standard case: table shows qc in japanese",
+			opts: &outputOptions{format: FormatTable, fields: "KFN_NUM", qc: true},
+			data: append(outputFixture(t),
+				&HourlySokuteiData{SokuteiNengappi: "20210201", SokuteiJikoku: "02", KafunNum: 3000, QC: QCSpike},
+			),
+			want: "KFN_NUM  qc\n     12\n   3000  急増\n",
+		},
+		{
+			name:    "error case: invalid level thresholds",
+			opts:    &outputOptions{format: FormatCSV, levelThresholds: "10,30,20,100"},
+			wantErr: true,
+		},
+		{
+			name:    "error case: unknown field",
+			opts:    &outputOptions{format: FormatCSV, fields: "SKT_CD,POLLEN"},
+			wantErr: true,
+		},
+		{
+			name:    "error case: no fields",
+			opts:    &outputOptions{format: FormatCSV, fields: ","},
+			wantErr: true,
+		},
+		{
+			name:    "error case: unknown field names",
+			opts:    &outputOptions{format: FormatCSV, fieldNames: "english"},
+			wantErr: true,
+		},
+		{
+			name:    "error case: unknown format",
+			opts:    &outputOptions{format: "xml"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			w, err := newRecordWriter(out, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newRecordWriter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			for _, hsd := range tt.data {
+				if err := w.Write(hsd); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if !strings.HasPrefix(out.String(), tt.want) {
+				t.Errorf("output got = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_newRecordWriter_sjis(t *testing.T) {
+	out := new(bytes.Buffer)
+	w, _ := newRecordWriter(out, &outputOptions{format: FormatCSV, noHeader: true, sjis: true})
+	for _, hsd := range outputFixture(t) {
+		w.Write(hsd)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	want, _ := encodeUTF8ToSJIS(t, []byte("51320100,44132,20210201,01,新宿区役所第二分庁舎,1,13,東京都,13104,新宿区,12,05,2,8.5,,\n"))
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("output got = %v, want %v", out.Bytes(), want)
+	}
+}
+
+func TestSummaryReport_write(t *testing.T) {
+	report := &summaryReport{
+		columns:   []*tableColumn{{Title: "コード"}, {Title: "測定局"}},
+		header:    []string{"SKT_CD", "SKT_NM"},
+		records:   []map[string]string{{"SKT_CD": "1", "SKT_NM": ""}},
+		rows:      [][]string{{"1", ""}},
+		tableRows: [][]string{{"1", "-"}},
+	}
+	tests := []struct {
+		name    string
+		opts    *outputOptions
+		want    string
+		wantErr bool
+	}{
+		{name: "table uses table rows", opts: &outputOptions{format: FormatTable, noHeader: true}, want: "1  -\n"},
+		{name: "json", opts: &outputOptions{format: FormatJSON}, want: "[\n\t{\n\t\t\"SKT_CD\": \"1\",\n\t\t\"SKT_NM\": \"\"\n\t}\n]\n"},
+		{name: "csv uses rows", opts: &outputOptions{format: FormatCSV}, want: "SKT_CD,SKT_NM\n1,\n"},
+		{name: "unknown format", opts: &outputOptions{format: FormatNDJSON}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			err := report.write(out, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if out.String() != tt.want {
+				t.Errorf("write() got = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package kafun
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/width"
+)
+
+// Prefecture は都道府県を表す。
+type Prefecture struct {
+	Code   string // JISの都道府県コード (01〜47)
+	Name   string // 名前 (東京都)
+	Kana   string // 名前のひらがな (とうきょうと)
+	Romaji string // 名前のローマ字。都府県を付けない (Tokyo)
+	Region string // 地方区分 (関東)
+}
+
+// prefectures はJISの都道府県コードの順の47都道府県。
+var prefectures = []Prefecture{
+	{Code: "01", Name: "北海道", Kana: "ほっかいどう", Romaji: "Hokkaido", Region: "北海道"},
+	{Code: "02", Name: "青森県", Kana: "あおもりけん", Romaji: "Aomori", Region: "東北"},
+	{Code: "03", Name: "岩手県", Kana: "いわてけん", Romaji: "Iwate", Region: "東北"},
+	{Code: "04", Name: "宮城県", Kana: "みやぎけん", Romaji: "Miyagi", Region: "東北"},
+	{Code: "05", Name: "秋田県", Kana: "あきたけん", Romaji: "Akita", Region: "東北"},
+	{Code: "06", Name: "山形県", Kana: "やまがたけん", Romaji: "Yamagata", Region: "東北"},
+	{Code: "07", Name: "福島県", Kana: "ふくしまけん", Romaji: "Fukushima", Region: "東北"},
+	{Code: "08", Name: "茨城県", Kana: "いばらきけん", Romaji: "Ibaraki", Region: "関東"},
+	{Code: "09", Name: "栃木県", Kana: "とちぎけん", Romaji: "Tochigi", Region: "関東"},
+	{Code: "10", Name: "群馬県", Kana: "ぐんまけん", Romaji: "Gunma", Region: "関東"},
+	{Code: "11", Name: "埼玉県", Kana: "さいたまけん", Romaji: "Saitama", Region: "関東"},
+	{Code: "12", Name: "千葉県", Kana: "ちばけん", Romaji: "Chiba", Region: "関東"},
+	{Code: "13", Name: "東京都", Kana: "とうきょうと", Romaji: "Tokyo", Region: "関東"},
+	{Code: "14", Name: "神奈川県", Kana: "かながわけん", Romaji: "Kanagawa", Region: "関東"},
+	{Code: "15", Name: "新潟県", Kana: "にいがたけん", Romaji: "Niigata", Region: "中部"},
+	{Code: "16", Name: "富山県", Kana: "とやまけん", Romaji: "Toyama", Region: "中部"},
+	{Code: "17", Name: "石川県", Kana: "いしかわけん", Romaji: "Ishikawa", Region: "中部"},
+	{Code: "18", Name: "福井県", Kana: "ふくいけん", Romaji: "Fukui", Region: "中部"},
+	{Code: "19", Name: "山梨県", Kana: "やまなしけん", Romaji: "Yamanashi", Region: "中部"},
+	{Code: "20", Name: "長野県", Kana: "ながのけん", Romaji: "Nagano", Region: "中部"},
+	{Code: "21", Name: "岐阜県", Kana: "ぎふけん", Romaji: "Gifu", Region: "中部"},
+	{Code: "22", Name: "静岡県", Kana: "しずおかけん", Romaji: "Shizuoka", Region: "中部"},
+	{Code: "23", Name: "愛知県", Kana: "あいちけん", Romaji: "Aichi", Region: "中部"},
+	{Code: "24", Name: "三重県", Kana: "みえけん", Romaji: "Mie", Region: "近畿"},
+	{Code: "25", Name: "滋賀県", Kana: "しがけん", Romaji: "Shiga", Region: "近畿"},
+	{Code: "26", Name: "京都府", Kana: "きょうとふ", Romaji: "Kyoto", Region: "近畿"},
+	{Code: "27", Name: "大阪府", Kana: "おおさかふ", Romaji: "Osaka", Region: "近畿"},
+	{Code: "28", Name: "兵庫県", Kana: "ひょうごけん", Romaji: "Hyogo", Region: "近畿"},
+	{Code: "29", Name: "奈良県", Kana: "ならけん", Romaji: "Nara", Region: "近畿"},
+	{Code: "30", Name: "和歌山県", Kana: "わかやまけん", Romaji: "Wakayama", Region: "近畿"},
+	{Code: "31", Name: "鳥取県", Kana: "とっとりけん", Romaji: "Tottori", Region: "中国"},
+	{Code: "32", Name: "島根県", Kana: "しまねけん", Romaji: "Shimane", Region: "中国"},
+	{Code: "33", Name: "岡山県", Kana: "おかやまけん", Romaji: "Okayama", Region: "中国"},
+	{Code: "34", Name: "広島県", Kana: "ひろしまけん", Romaji: "Hiroshima", Region: "中国"},
+	{Code: "35", Name: "山口県", Kana: "やまぐちけん", Romaji: "Yamaguchi", Region: "中国"},
+	{Code: "36", Name: "徳島県", Kana: "とくしまけん", Romaji: "Tokushima", Region: "四国"},
+	{Code: "37", Name: "香川県", Kana: "かがわけん", Romaji: "Kagawa", Region: "四国"},
+	{Code: "38", Name: "愛媛県", Kana: "えひめけん", Romaji: "Ehime", Region: "四国"},
+	{Code: "39", Name: "高知県", Kana: "こうちけん", Romaji: "Kochi", Region: "四国"},
+	{Code: "40", Name: "福岡県", Kana: "ふくおかけん", Romaji: "Fukuoka", Region: "九州・沖縄"},
+	{Code: "41", Name: "佐賀県", Kana: "さがけん", Romaji: "Saga", Region: "九州・沖縄"},
+	{Code: "42", Name: "長崎県", Kana: "ながさきけん", Romaji: "Nagasaki", Region: "九州・沖縄"},
+	{Code: "43", Name: "熊本県", Kana: "くまもとけん", Romaji: "Kumamoto", Region: "九州・沖縄"},
+	{Code: "44", Name: "大分県", Kana: "おおいたけん", Romaji: "Oita", Region: "九州・沖縄"},
+	{Code: "45", Name: "宮崎県", Kana: "みやざきけん", Romaji: "Miyazaki", Region: "九州・沖縄"},
+	{Code: "46", Name: "鹿児島県", Kana: "かごしまけん", Romaji: "Kagoshima", Region: "九州・沖縄"},
+	{Code: "47", Name: "沖縄県", Kana: "おきなわけん", Romaji: "Okinawa", Region: "九州・沖縄"},
+}
+
+// 都府県の接尾辞と、対応するひらがなとローマ字。北海道は接尾辞を付けずに照合する。
+var prefectureSuffixes = []struct {
+	kanji, kana, romaji string
+}{
+	{kanji: "都", kana: "と", romaji: "to"},
+	{kanji: "府", kana: "ふ", romaji: "fu"},
+	{kanji: "県", kana: "けん", romaji: "ken"},
+}
+
+// prefectureAliases は正規化した都道府県の表記と都道府県の対応。
+var prefectureAliases = newPrefectureAliases()
+
+func newPrefectureAliases() map[string]*Prefecture {
+	aliases := make(map[string]*Prefecture)
+	for i := range prefectures {
+		p := &prefectures[i]
+		romaji := strings.ToLower(p.Romaji)
+		for _, alias := range []string{p.Code, p.Name, p.Kana, romaji} {
+			aliases[alias] = p
+		}
+
+		for _, suffix := range prefectureSuffixes {
+			if strings.HasSuffix(p.Name, suffix.kanji) {
+				aliases[strings.TrimSuffix(p.Name, suffix.kanji)] = p
+				aliases[strings.TrimSuffix(p.Kana, suffix.kana)] = p
+				aliases[romaji+suffix.romaji] = p
+			}
+		}
+	}
+
+	return aliases
+}
+
+// Prefectures は47都道府県をJISの都道府県コードの順で返す。
+func Prefectures() []Prefecture {
+	return append([]Prefecture(nil), prefectures...)
+}
+
+// LookupPrefecture は都道府県コード、名前、ひらがな、カタカナ、ローマ字のいずれかから都道府県を返す。
+// 「13」「東京都」「東京」「とうきょう」「Tokyo」「tokyo-to」などを受け付け、該当する都道府県がない場合は false を返す。
+func LookupPrefecture(s string) (Prefecture, bool) {
+	key := normalizePrefectureKey(s)
+
+	if n, err := strconv.Atoi(key); err == nil {
+		key = fmt.Sprintf("%02d", n)
+	}
+
+	p, ok := prefectureAliases[key]
+	if !ok {
+		return Prefecture{}, false
+	}
+
+	return *p, true
+}
+
+// isPrefectureCode は2桁のJISの都道府県コードかどうかを返す。
+func isPrefectureCode(code string) bool {
+	if len(code) != 2 {
+		return false
+	}
+
+	p, ok := prefectureAliases[code]
+	return ok && p.Code == code
+}
+
+// normalizePrefectureKey は都道府県の表記を照合用に正規化する。
+// 全角英数を半角に、カタカナをひらがなにし、ローマ字は小文字にして空白とハイフン、Prefecture を除く。
+func normalizePrefectureKey(s string) string {
+	s = strings.ToLower(width.Fold.String(strings.TrimSpace(s)))
+	s = strings.TrimSuffix(s, "prefecture")
+	s = strings.NewReplacer(" ", "", "-", "", "　", "").Replace(s)
+
+	return strings.Map(katakanaToHiragana, s)
+}
+
+func katakanaToHiragana(r rune) rune {
+	if r >= 'ァ' && r <= 'ヶ' {
+		return r - ('ァ' - 'ぁ')
+	}
+
+	return r
+}
@@ -0,0 +1,88 @@
+package kafun
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPrefectures(t *testing.T) {
+	got := Prefectures()
+	if len(got) != 47 {
+		t.Fatalf("Prefectures() returns %d prefectures, want 47", len(got))
+	}
+	for i, p := range got {
+		if want := fmt.Sprintf("%02d", i+1); p.Code != want {
+			t.Errorf("Prefectures()[%d].Code = %v, want %v", i, p.Code, want)
+		}
+		if len(p.Name) == 0 || len(p.Kana) == 0 || len(p.Romaji) == 0 || len(p.Region) == 0 {
+			t.Errorf("Prefectures()[%d] = %+v, want all fields filled", i, p)
+		}
+	}
+
+	// 返り値を変更しても登録内容は変わらない
+	got[0].Name = "changed"
+	if p, _ := LookupPrefecture("01"); p.Name != "北海道" {
+		t.Errorf("LookupPrefecture() after modifying Prefectures() = %v, want 北海道", p.Name)
+	}
+}
+
+func TestLookupPrefecture(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   string
+		wantOK bool
+	}{
+		{input: "13", want: "13", wantOK: true},
+		{input: "1", want: "01", wantOK: true},
+		{input: "１３", want: "13", wantOK: true},
+		{input: "東京都", want: "13", wantOK: true},
+		{input: "東京", want: "13", wantOK: true},
+		{input: "とうきょうと", want: "13", wantOK: true},
+		{input: "トウキョウ", want: "13", wantOK: true},
+		{input: "tokyo", want: "13", wantOK: true},
+		{input: "Tokyo-to", want: "13", wantOK: true},
+		{input: "Kyoto", want: "26", wantOK: true},
+		{input: "京都", want: "26", wantOK: true},
+		{input: "Osaka fu", want: "27", wantOK: true},
+		{input: "北海道", want: "01", wantOK: true},
+		{input: "hokkaido", want: "01", wantOK: true},
+		{input: "Kanagawa Prefecture", want: "14", wantOK: true},
+		{input: "鹿児島", want: "46", wantOK: true},
+		{input: "0", wantOK: false},
+		{input: "48", wantOK: false},
+		{input: "", wantOK: false},
+		{input: "atlantis", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := LookupPrefecture(tt.input)
+			if ok != tt.wantOK {
+				t.Fatalf("LookupPrefecture() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got.Code != tt.want {
+				t.Errorf("LookupPrefecture() = %v, want code %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isPrefectureCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{code: "01", want: true},
+		{code: "47", want: true},
+		{code: "1", want: false},
+		{code: "00", want: false},
+		{code: "48", want: false},
+		{code: "東京", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			if got := isPrefectureCode(tt.code); got != tt.want {
+				t.Errorf("isPrefectureCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
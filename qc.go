@@ -0,0 +1,276 @@
+package kafun
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// QCFlags は花粉数の品質管理で測定データを疑わしいとした理由の組み合わせを表す。
+type QCFlags int
+
+// 疑わしいとした理由。
+const (
+	QCSpike    QCFlags = 1 << iota // 前後の時間の花粉数から大きく外れた値
+	QCFlatline                     // 0より大きい同じ値が続いた
+	QCNeighbor                     // 同じ都道府県の他の測定局の同じ時間の花粉数から大きく外れた値
+)
+
+// 理由の日本語と英語の表記。添字はビットの位置。
+var (
+	qcFlagJapanese = [...]string{"急増", "一定値", "近隣と乖離"}
+	qcFlagEnglish  = [...]string{"spike", "flatline", "neighbor"}
+)
+
+// Has は flag の理由を含むかどうかを返す。
+func (f QCFlags) Has(flag QCFlags) bool {
+	return f&flag != 0
+}
+
+// names は理由の表記を names から選んで sep で区切る。
+func (f QCFlags) names(names []string, sep string) string {
+	var result []string
+	for i, name := range names {
+		if f.Has(1 << i) {
+			result = append(result, name)
+		}
+	}
+
+	return strings.Join(result, sep)
+}
+
+// Japanese は理由の日本語の表記を「急増、近隣と乖離」の形式で返す。理由がない場合は空文字列を返す。
+func (f QCFlags) Japanese() string {
+	return f.names(qcFlagJapanese[:], "、")
+}
+
+// English は理由の英語の表記を "spike;neighbor" の形式で返す。理由がない場合は空文字列を返す。
+func (f QCFlags) English() string {
+	return f.names(qcFlagEnglish[:], ";")
+}
+
+func (f QCFlags) String() string {
+	return f.English()
+}
+
+// QCRule は花粉数の品質管理の条件を表す。
+type QCRule struct {
+	// Window は移動統計に使う前後の時間数。測定日時が前後 Window 時間以内の測定データの中央値と
+	// 中央絶対偏差(MAD)から外れを判定する。
+	Window int
+
+	// SpikeFactor は急増とみなす中央値からの差の MAD に対する倍率。MAD が1未満の場合は1として計算する。
+	SpikeFactor float64
+
+	// SpikeMin は急増とみなす花粉数の下限。少ない花粉数の揺らぎは急増とみなさない。
+	SpikeMin int
+
+	// FlatlineHours は一定値とみなす、0より大きい同じ花粉数が続く時間数。
+	FlatlineHours int
+
+	// NeighborFactor は近隣と乖離しているとみなす、同じ都道府県の他の測定局の中央値との比。
+	// 中央値の NeighborFactor 倍より多い場合と、NeighborFactor 分の1より少ない場合に乖離とみなす。
+	NeighborFactor float64
+
+	// NeighborMin は近隣と乖離しているとみなす花粉数の下限。測定局と他の測定局の中央値の多いほうと比べる。
+	NeighborMin int
+
+	// NeighborStations は近隣と比べるのに必要な、同じ時間に測定データのある他の測定局の数。
+	NeighborStations int
+}
+
+// DefaultQCRule は前後12時間の中央値から MAD の10倍を超えて100個以上になった値を急増、
+// 0より大きい同じ値が6時間続いた場合を一定値、同じ都道府県の他の3局以上の中央値と10倍を超えて違い、どちらかが100個以上の場合を乖離とする。
+// 他の測定局が2局では1局の外れた値で中央値が大きく変わるので、3局以上で比べる。
+var DefaultQCRule = QCRule{
+	Window:           12,
+	SpikeFactor:      10,
+	SpikeMin:         100,
+	FlatlineHours:    6,
+	NeighborFactor:   10,
+	NeighborMin:      100,
+	NeighborStations: 3,
+}
+
+func (r QCRule) validate() error {
+	if r.Window < 1 || r.SpikeFactor <= 0 || r.SpikeMin < 0 || r.FlatlineHours < 2 ||
+		r.NeighborFactor <= 1 || r.NeighborMin < 0 || r.NeighborStations < 1 {
+		return xerrors.Errorf("invalid qc rule: %+v", r)
+	}
+
+	return nil
+}
+
+// StationQC は測定局ごとの品質管理の結果を表す。
+type StationQC struct {
+	SokuteikyokuCode string // 測定局コード
+	SokuteikyokuName string // 測定局名
+	TodofukenCode    string // 都道府県コード(JIS)
+
+	Checked  int // 検査した測定データの数
+	Flagged  int // 疑わしいとした測定データの数
+	Spike    int // 急増とした測定データの数
+	Flatline int // 一定値とした測定データの数
+	Neighbor int // 近隣と乖離しているとした測定データの数
+}
+
+// CheckQuality は測定データの花粉数を rule に従って検査し、疑わしい測定データの QC に理由を設定して、
+// 測定局ごとの結果を測定局コードの順で返す。測定日時を解釈できない測定データ、花粉数が不正な値で
+// 記録された測定データ、Fill で補った測定データは検査せず、移動統計や近隣との比較にも使わない。
+func CheckQuality(data SokuteiData, rule QCRule) ([]*StationQC, error) {
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+
+	series := make(map[string][]observation)
+	type neighborKey struct {
+		todofukenCode string
+		time          int64
+	}
+	neighbors := make(map[neighborKey][]observation)
+	for _, hsd := range data {
+		t, err := hsd.Time()
+		if err != nil || hsd.Filled || hsd.hasProblem("KFN_NUM") {
+			continue
+		}
+		hsd.QC = 0
+		o := observation{time: t, hsd: hsd}
+		series[hsd.SokuteikyokuCode] = append(series[hsd.SokuteikyokuCode], o)
+		key := neighborKey{todofukenCode: hsd.TodofukenCode, time: t.Unix()}
+		neighbors[key] = append(neighbors[key], o)
+	}
+
+	codes := make([]string, 0, len(series))
+	for code, rows := range series {
+		codes = append(codes, code)
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].time.Before(rows[j].time) })
+		checkSpikes(rows, rule)
+		checkFlatlines(rows, rule)
+	}
+	sort.Strings(codes)
+
+	for _, rows := range neighbors {
+		checkNeighbors(rows, rule)
+	}
+
+	result := make([]*StationQC, len(codes))
+	for i, code := range codes {
+		rows := series[code]
+		last := rows[len(rows)-1].hsd
+		s := &StationQC{
+			SokuteikyokuCode: code,
+			SokuteikyokuName: last.SokuteikyokuName,
+			TodofukenCode:    last.TodofukenCode,
+			Checked:          len(rows),
+		}
+		for _, row := range rows {
+			qc := row.hsd.QC
+			if qc != 0 {
+				s.Flagged++
+			}
+			if qc.Has(QCSpike) {
+				s.Spike++
+			}
+			if qc.Has(QCFlatline) {
+				s.Flatline++
+			}
+			if qc.Has(QCNeighbor) {
+				s.Neighbor++
+			}
+		}
+		result[i] = s
+	}
+
+	return result, nil
+}
+
+// checkSpikes は測定日時の順の1つの測定局の測定データから急増を探す。
+func checkSpikes(rows []observation, rule QCRule) {
+	window := time.Duration(rule.Window) * time.Hour
+	from := 0
+	values := make([]float64, 0, 2*rule.Window)
+	for i, row := range rows {
+		for rows[from].time.Before(row.time.Add(-window)) {
+			from++
+		}
+
+		values = values[:0]
+		for j := from; j < len(rows) && !rows[j].time.After(row.time.Add(window)); j++ {
+			if j != i {
+				values = append(values, float64(rows[j].hsd.KafunNum))
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		median := medianOf(values)
+		for k, v := range values {
+			values[k] = math.Abs(v - median)
+		}
+		mad := math.Max(medianOf(values), 1)
+
+		v := row.hsd.KafunNum
+		if v >= rule.SpikeMin && float64(v) > median+rule.SpikeFactor*mad {
+			row.hsd.QC |= QCSpike
+		}
+	}
+}
+
+// checkFlatlines は測定日時の順の1つの測定局の測定データから一定値が続いた期間を探す。
+func checkFlatlines(rows []observation, rule QCRule) {
+	start := 0
+	for i := 1; i <= len(rows); i++ {
+		if i < len(rows) &&
+			rows[i].hsd.KafunNum == rows[start].hsd.KafunNum &&
+			rows[i].time.Sub(rows[i-1].time) == time.Hour {
+			continue
+		}
+
+		if rows[start].hsd.KafunNum > 0 && i-start >= rule.FlatlineHours {
+			for _, row := range rows[start:i] {
+				row.hsd.QC |= QCFlatline
+			}
+		}
+		start = i
+	}
+}
+
+// checkNeighbors は同じ都道府県の同じ測定日時の測定データを比べて、近隣と乖離した測定データを探す。
+func checkNeighbors(rows []observation, rule QCRule) {
+	others := make([]float64, 0, len(rows))
+	for _, row := range rows {
+		others = others[:0]
+		for _, other := range rows {
+			if other.hsd.SokuteikyokuCode != row.hsd.SokuteikyokuCode {
+				others = append(others, float64(other.hsd.KafunNum))
+			}
+		}
+		if len(others) < rule.NeighborStations {
+			continue
+		}
+
+		median := medianOf(others)
+		v := float64(row.hsd.KafunNum)
+		if math.Max(v, median) < float64(rule.NeighborMin) {
+			continue
+		}
+		if v > rule.NeighborFactor*math.Max(median, 1) || v*rule.NeighborFactor < median {
+			row.hsd.QC |= QCNeighbor
+		}
+	}
+}
+
+// medianOf は values の中央値を返す。values の順序は変わる。
+func medianOf(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+
+	return (values[n/2-1] + values[n/2]) / 2
+}
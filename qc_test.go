@@ -0,0 +1,187 @@
+package kafun
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// hourlyFixture は from の日の1時から1時間ごとに、花粉数が counts の値の測定データを返す。
+func hourlyFixture(t *testing.T, code string, from time.Time, counts ...int) SokuteiData {
+	t.Helper()
+	data := make(SokuteiData, len(counts))
+	for i, count := range counts {
+		data[i] = &HourlySokuteiData{
+			SokuteikyokuCode: code,
+			SokuteikyokuName: "テスト観測所" + code,
+			TodofukenCode:    "13",
+			SokuteiNengappi:  from.Format(sokuteiNengappiLayout),
+			SokuteiJikoku:    strconv.Itoa(i + 1),
+			KafunNum:         count,
+		}
+	}
+
+	return data
+}
+
+func TestQCFlags(t *testing.T) {
+	tests := []struct {
+		flags        QCFlags
+		wantEnglish  string
+		wantJapanese string
+	}{
+		{flags: 0, wantEnglish: "", wantJapanese: ""},
+		{flags: QCSpike, wantEnglish: "spike", wantJapanese: "急増"},
+		{flags: QCSpike | QCNeighbor, wantEnglish: "spike;neighbor", wantJapanese: "急増、近隣と乖離"},
+		{flags: QCFlatline | QCNeighbor, wantEnglish: "flatline;neighbor", wantJapanese: "一定値、近隣と乖離"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantEnglish, func(t *testing.T) {
+			if got := tt.flags.English(); got != tt.wantEnglish {
+				t.Errorf("English() = %q, want %q", got, tt.wantEnglish)
+			}
+			if got := tt.flags.Japanese(); got != tt.wantJapanese {
+				t.Errorf("Japanese() = %q, want %q", got, tt.wantJapanese)
+			}
+		})
+	}
+}
+
+func TestCheckQuality(t *testing.T) {
+	day := time.Date(2021, 3, 1, 0, 0, 0, 0, jst)
+
+	tests := []struct {
+		name      string
+		data      SokuteiData
+		rule      QCRule
+		wantFlags map[string][]QCFlags // 測定局コードごとの測定時刻の順の理由
+	}{
+		{
+			name: "standard case: spike surrounded by zeros",
+			data: hourlyFixture(t, "1", day, 0, 0, 1, 0, 3000, 0, 2, 0),
+			rule: DefaultQCRule,
+			wantFlags: map[string][]QCFlags{
+				"1": {0, 0, 0, 0, QCSpike, 0, 0, 0},
+			},
+		},
+		{
+			name: "standard case: high values in season are not spikes",
+			data: hourlyFixture(t, "1", day, 80, 120, 150, 90, 200, 130, 110, 70),
+			rule: DefaultQCRule,
+			wantFlags: map[string][]QCFlags{
+				"1": {0, 0, 0, 0, 0, 0, 0, 0},
+			},
+		},
+		{
+			name: "standard case: flatline",
+			data: hourlyFixture(t, "1", day, 0, 0, 0, 0, 0, 0, 0, 7, 7, 7, 7, 7, 7, 3),
+			rule: DefaultQCRule,
+			wantFlags: map[string][]QCFlags{
+				"1": {
+					0, 0, 0, 0, 0, 0, 0,
+					QCFlatline, QCFlatline, QCFlatline, QCFlatline, QCFlatline, QCFlatline,
+					0,
+				},
+			},
+		},
+		{
+			name: "standard case: flatline interrupted by missing hour",
+			data: func() SokuteiData {
+				data := hourlyFixture(t, "1", day, 7, 7, 7, 7, 7, 7)
+				data[3].SokuteiJikoku = "10"
+				return data
+			}(),
+			rule: DefaultQCRule,
+			wantFlags: map[string][]QCFlags{
+				"1": {0, 0, 0, 0, 0, 0},
+			},
+		},
+		{
+			name: "standard case: neighbor comparison",
+			data: append(append(append(
+				hourlyFixture(t, "1", day, 20, 500, 200),
+				hourlyFixture(t, "2", day, 25, 30, 180)...),
+				hourlyFixture(t, "3", day, 15, 40, 0)...),
+				hourlyFixture(t, "4", day, 30, 35, 210)...),
+			rule: QCRule{
+				Window: 1, SpikeFactor: 100, SpikeMin: 10000, FlatlineHours: 6,
+				NeighborFactor: 10, NeighborMin: 100, NeighborStations: 2,
+			},
+			wantFlags: map[string][]QCFlags{
+				"1": {0, QCNeighbor, 0},
+				"2": {0, 0, 0},
+				"3": {0, 0, QCNeighbor},
+				"4": {0, 0, 0},
+			},
+		},
+		{
+			name: "standard case: not enough neighbors",
+			data: append(hourlyFixture(t, "1", day, 500), hourlyFixture(t, "2", day, 10)...),
+			rule: DefaultQCRule,
+			wantFlags: map[string][]QCFlags{
+				"1": {0},
+				"2": {0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stations, err := CheckQuality(tt.data, tt.rule)
+			if err != nil {
+				t.Fatalf("CheckQuality() error = %v", err)
+			}
+
+			got := make(map[string][]QCFlags)
+			for _, hsd := range tt.data {
+				got[hsd.SokuteikyokuCode] = append(got[hsd.SokuteikyokuCode], hsd.QC)
+			}
+			if !reflect.DeepEqual(got, tt.wantFlags) {
+				t.Errorf("CheckQuality() flags = %v, want %v", got, tt.wantFlags)
+			}
+
+			for _, s := range stations {
+				flagged := 0
+				for _, qc := range tt.wantFlags[s.SokuteikyokuCode] {
+					if qc != 0 {
+						flagged++
+					}
+				}
+				if s.Checked != len(tt.wantFlags[s.SokuteikyokuCode]) || s.Flagged != flagged {
+					t.Errorf("CheckQuality() station %s = %+v, want %d flagged", s.SokuteikyokuCode, s, flagged)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckQuality_skipped(t *testing.T) {
+	day := time.Date(2021, 3, 1, 0, 0, 0, 0, jst)
+	data := hourlyFixture(t, "1", day, 0, 0, 3000, 0, 0)
+	data[2].Filled = true
+	data[4].Problems = []FieldProblem{{Field: "KFN_NUM", Message: "invalid"}}
+	data[0].QC = QCFlatline // 前の検査の理由は消す
+
+	stations, err := CheckQuality(data, DefaultQCRule)
+	if err != nil {
+		t.Fatalf("CheckQuality() error = %v", err)
+	}
+	for _, hsd := range data {
+		if hsd.QC != 0 {
+			t.Errorf("CheckQuality() QC = %v at %s, want no flags", hsd.QC, hsd.SokuteiJikoku)
+		}
+	}
+	if want := (&StationQC{
+		SokuteikyokuCode: "1", SokuteikyokuName: "テスト観測所1", TodofukenCode: "13", Checked: 3,
+	}); len(stations) != 1 || !reflect.DeepEqual(stations[0], want) {
+		t.Errorf("CheckQuality() = %+v, want %+v", stations, want)
+	}
+}
+
+func TestCheckQuality_invalidRule(t *testing.T) {
+	rule := DefaultQCRule
+	rule.FlatlineHours = 1
+	if _, err := CheckQuality(nil, rule); err == nil {
+		t.Errorf("CheckQuality() error = nil, want error for invalid rule")
+	}
+}
@@ -0,0 +1,81 @@
+package kafun
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter はAPIリクエストの送信間隔を制御する。
+// Client のすべてのリクエスト(再試行を含む)は送信前に Wait を呼ぶ。
+type RateLimiter interface {
+	// Wait はリクエストを送信できるようになるまで待つ。待っている間にコンテキストが終了した場合はそのエラーを返す。
+	Wait(ctx context.Context) error
+}
+
+// TokenBucket はトークンバケット方式の RateLimiter。複数のゴルーチンから同時に使える。
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // 1秒あたりに補充するトークン数
+	burst  float64 // バケットの容量
+	tokens float64 // 現在のトークン数。待ち中のリクエストがある場合は負になる
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewTokenBucket は1秒あたり rps 回、最大 burst 回まで連続してリクエストできる TokenBucket を作成する。
+// rps が0以下の場合は制限しない。burst が1未満の場合は1として扱う。
+func NewTokenBucket(rps float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &TokenBucket{
+		rate:   rps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// Wait は RateLimiter の実装。
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if b.rate <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	// トークンを先に予約し、不足分が補充されるまで待つ
+	b.tokens--
+	wait := time.Duration(0)
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+
+	if err := sleepContext(ctx, wait); err != nil {
+		// 使わなかったトークンを返す
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package kafun
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingRateLimiter は Wait が呼ばれた回数を数える RateLimiter。
+type countingRateLimiter struct {
+	count int32
+}
+
+func (l *countingRateLimiter) Wait(ctx context.Context) error {
+	atomic.AddInt32(&l.count, 1)
+	return ctx.Err()
+}
+
+func TestTokenBucket_Wait(t *testing.T) {
+	type args struct {
+		rps   float64
+		burst int
+		calls int
+	}
+	tests := []struct {
+		name        string
+		args        args
+		minDuration time.Duration
+		maxDuration time.Duration
+	}{
+		{
+			name:        "standard case: within burst",
+			args:        args{rps: 1, burst: 3, calls: 3},
+			maxDuration: 500 * time.Millisecond,
+		},
+		{
+			name:        "standard case: wait for refill over burst",
+			args:        args{rps: 20, burst: 1, calls: 3},
+			minDuration: 90 * time.Millisecond,
+			maxDuration: time.Second,
+		},
+		{
+			name:        "standard case: unlimited",
+			args:        args{rps: 0, burst: 0, calls: 100},
+			maxDuration: 500 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewTokenBucket(tt.args.rps, tt.args.burst)
+			start := time.Now()
+			for i := 0; i < tt.args.calls; i++ {
+				if err := b.Wait(ctx); err != nil {
+					t.Fatalf("Wait() error = %v", err)
+				}
+			}
+			elapsed := time.Since(start)
+			if elapsed < tt.minDuration || elapsed > tt.maxDuration {
+				t.Errorf("Wait() elapsed = %v, want between %v and %v", elapsed, tt.minDuration, tt.maxDuration)
+			}
+		})
+	}
+}
+
+func TestTokenBucket_Wait_cancel(t *testing.T) {
+	b := NewTokenBucket(0.001, 1)
+	if err := b.Wait(ctx); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+
+	cancelCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := b.Wait(cancelCtx); err == nil {
+		t.Fatalf("Wait() error = nil, want context error")
+	}
+
+	// キャンセルされた待ちのトークンは返却される
+	if b.tokens < -0.5 {
+		t.Errorf("Wait() tokens = %v, want canceled token returned", b.tokens)
+	}
+}
+
+func TestClient_Search_rateLimit(t *testing.T) {
+	sjisStr, _ := encodeUTF8ToSJIS(t, []byte(`[]`))
+	var requests int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 最初のリクエストだけ失敗させ、再試行も流量制限の対象になることを確認する
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(sjisStr)
+	}))
+	defer testServer.Close()
+	testServerURL, _ := url.Parse(testServer.URL)
+
+	limiter := &countingRateLimiter{}
+	c := &Client{
+		URL:         testServerURL,
+		HTTPClient:  http.DefaultClient,
+		Concurrency: 2,
+		RetryPolicy: &BackoffRetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+		RateLimiter: limiter,
+	}
+
+	param := &SearchParam{StartYM: "202102", EndYM: "202104", TodofukenCode: "13"}
+	if _, err := c.Search(ctx, param); err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+
+	if got, want := atomic.LoadInt32(&limiter.count), atomic.LoadInt32(&requests); got != want {
+		t.Errorf("Search() rate limiter waits = %v, want %v", got, want)
+	}
+}
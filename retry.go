@@ -0,0 +1,201 @@
+package kafun
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy はAPIリクエストを再試行するかどうかを判断する。
+type RetryPolicy interface {
+	// Retry は attempt 回目(1始まり)の試行結果を受け取り、再試行までの待ち時間と再試行するかどうかを返す。
+	// res と err はどちらかが nil になる。
+	Retry(req *http.Request, res *http.Response, err error, attempt int) (time.Duration, bool)
+}
+
+// DefaultRetryableStatusCodes は BackoffRetryPolicy が再試行するデフォルトのステータスコード。
+var DefaultRetryableStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// BackoffRetryPolicy はジッター付きの指数バックオフで再試行する RetryPolicy。
+// 再試行するのは GET と HEAD のリクエストで、通信エラーか RetryableStatusCodes のステータスコードの場合だけ。
+// レスポンスに Retry-After ヘッダがある場合はその時間だけ待つ。Retry-After が MaxBackoff より長い場合や、
+// 待ち時間がリクエストのコンテキストの期限を過ぎる場合は、再試行せずに今回のレスポンスを返す。
+type BackoffRetryPolicy struct {
+	MaxAttempts          int           // 最大試行回数(初回を含む)
+	InitialBackoff       time.Duration // 1回目の再試行までの待ち時間
+	MaxBackoff           time.Duration // 待ち時間の上限。0の場合は上限なし
+	Multiplier           float64       // 再試行ごとに待ち時間に掛ける倍率。1未満の場合は2として扱う
+	Jitter               float64       // 待ち時間をランダムに揺らす割合(0〜1)
+	RetryableStatusCodes []int         // 再試行するステータスコード。nil の場合は DefaultRetryableStatusCodes
+}
+
+// NewBackoffRetryPolicy はデフォルト値の BackoffRetryPolicy を作成する。
+func NewBackoffRetryPolicy(maxAttempts int) *BackoffRetryPolicy {
+	return &BackoffRetryPolicy{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// Retry は RetryPolicy の実装。
+func (p *BackoffRetryPolicy) Retry(req *http.Request, res *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return 0, false
+	}
+
+	if err != nil {
+		// 呼び出し元によるキャンセルやタイムアウトは再試行しない
+		if req.Context().Err() != nil {
+			return 0, false
+		}
+		return p.backoff(attempt), true
+	}
+
+	if !p.isRetryableStatus(res.StatusCode) {
+		return 0, false
+	}
+
+	wait, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+	if !ok {
+		wait = p.backoff(attempt)
+	} else if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		// サーバが指定した時間より前に再試行しても拒否されるだけなので、再試行しない
+		return 0, false
+	}
+
+	// 期限までに再試行できない場合は、待たずに今回のレスポンスを返す
+	if deadline, ok := req.Context().Deadline(); ok && time.Now().Add(wait).After(deadline) {
+		return 0, false
+	}
+
+	return wait, true
+}
+
+func (p *BackoffRetryPolicy) isRetryableStatus(statusCode int) bool {
+	codes := p.RetryableStatusCodes
+	if codes == nil {
+		codes = DefaultRetryableStatusCodes
+	}
+
+	for _, code := range codes {
+		if code == statusCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *BackoffRetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	wait := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		wait += wait * p.Jitter * (rand.Float64()*2 - 1)
+	}
+
+	return time.Duration(wait)
+}
+
+// parseRetryAfter は Retry-After ヘッダの値(秒数ないしはHTTP日付)を待ち時間に変換する。
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if len(value) == 0 {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		wait := date.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
+
+// do は RateLimiter で流量を制限し、RetryPolicy に従って再試行しながらリクエストを送信する。
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		if c.RateLimiter != nil {
+			if err := c.RateLimiter.Wait(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+
+		res, err := c.HTTPClient.Do(req)
+		if err == nil && res.StatusCode == http.StatusOK {
+			return res, nil
+		}
+
+		if c.RetryPolicy == nil {
+			return res, err
+		}
+
+		wait, retry := c.RetryPolicy.Retry(req, res, err, attempt)
+		if !retry {
+			return res, err
+		}
+
+		if err != nil {
+			c.logf("retry after %v: %s: %v", wait, req.URL, err)
+		} else {
+			c.logf("retry after %v: %s: status_code=%d", wait, req.URL, res.StatusCode)
+		}
+
+		if res != nil {
+			// コネクションを再利用できるようにボディを読み捨てる
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		if err := sleepContext(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleepContext は d だけ待つ。待っている間にコンテキストが終了した場合はそのエラーを返す。
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,293 @@
+package kafun
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyHandler は最初の failures 回のリクエストに statusCode を返し、その後は正常なレスポンスを返すモックサーバのハンドラ。
+func flakyHandler(t *testing.T, failures int32, statusCode int, retryAfter string, count *int32) http.HandlerFunc {
+	t.Helper()
+	sjisStr, _ := encodeUTF8ToSJIS(t, sokuteiDataByteOmitOptional)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(count, 1) <= failures {
+			if len(retryAfter) != 0 {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(statusCode)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(sjisStr)
+	}
+}
+
+func TestClient_Search_retry(t *testing.T) {
+	type fields struct {
+		failures   int32
+		statusCode int
+		retryAfter string
+		policy     RetryPolicy
+	}
+	type want struct {
+		requests int32
+		wantErr  bool
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		want   want
+	}{
+		{
+			name: "standard case: recover from intermittent 503",
+			fields: fields{
+				failures:   2,
+				statusCode: http.StatusServiceUnavailable,
+				policy:     &BackoffRetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Jitter: 0.5},
+			},
+			want: want{requests: 3},
+		},
+		{
+			name: "standard case: honor Retry-After",
+			fields: fields{
+				failures:   1,
+				statusCode: http.StatusTooManyRequests,
+				retryAfter: "0",
+				policy:     &BackoffRetryPolicy{MaxAttempts: 2, InitialBackoff: time.Hour},
+			},
+			want: want{requests: 2},
+		},
+		{
+			name: "error case: Retry-After beyond max backoff",
+			fields: fields{
+				failures:   1,
+				statusCode: http.StatusTooManyRequests,
+				retryAfter: "60",
+				policy:     &BackoffRetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Second},
+			},
+			want: want{requests: 1, wantErr: true},
+		},
+		{
+			name: "error case: give up after max attempts",
+			fields: fields{
+				failures:   3,
+				statusCode: http.StatusServiceUnavailable,
+				policy:     &BackoffRetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+			},
+			want: want{requests: 3, wantErr: true},
+		},
+		{
+			name: "error case: do not retry non retryable status",
+			fields: fields{
+				failures:   1,
+				statusCode: http.StatusBadRequest,
+				policy:     &BackoffRetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+			},
+			want: want{requests: 1, wantErr: true},
+		},
+		{
+			name: "error case: retry is disabled",
+			fields: fields{
+				failures:   1,
+				statusCode: http.StatusServiceUnavailable,
+			},
+			want: want{requests: 1, wantErr: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var count int32
+			testServer := httptest.NewServer(
+				flakyHandler(t, tt.fields.failures, tt.fields.statusCode, tt.fields.retryAfter, &count),
+			)
+			defer testServer.Close()
+			testServerURL, _ := url.Parse(testServer.URL)
+			c := &Client{
+				URL:         testServerURL,
+				HTTPClient:  http.DefaultClient,
+				RetryPolicy: tt.fields.policy,
+			}
+
+			_, err := c.Search(ctx, validSearchParam)
+			if (err != nil) != tt.want.wantErr {
+				t.Errorf("Search() error = %v, wantErr %v", err, tt.want.wantErr)
+			}
+			var apiErr *APIError
+			if tt.want.wantErr && (!errors.As(err, &apiErr) || apiErr.StatusCode != tt.fields.statusCode) {
+				t.Errorf("Search() error = %v, want *APIError with status %d", err, tt.fields.statusCode)
+			}
+			if got := atomic.LoadInt32(&count); got != tt.want.requests {
+				t.Errorf("Search() requests = %v, want %v", got, tt.want.requests)
+			}
+		})
+	}
+}
+
+func TestBackoffRetryPolicy_Retry(t *testing.T) {
+	getRequest, _ := http.NewRequest(http.MethodGet, DefaultEndpoint, nil)
+	deadlineCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	deadlineRequest, _ := http.NewRequestWithContext(deadlineCtx, http.MethodGet, DefaultEndpoint, nil)
+	postRequest, _ := http.NewRequest(http.MethodPost, DefaultEndpoint, nil)
+	policy := &BackoffRetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     3 * time.Second,
+		Multiplier:     2,
+	}
+	type args struct {
+		req     *http.Request
+		res     *http.Response
+		err     error
+		attempt int
+	}
+	tests := []struct {
+		name      string
+		args      args
+		wantWait  time.Duration
+		wantRetry bool
+	}{
+		{
+			name: "standard case: first backoff",
+			args: args{
+				req:     getRequest,
+				res:     &http.Response{StatusCode: http.StatusServiceUnavailable},
+				attempt: 1,
+			},
+			wantWait:  time.Second,
+			wantRetry: true,
+		},
+		{
+			name: "standard case: exponential backoff",
+			args: args{
+				req:     getRequest,
+				res:     &http.Response{StatusCode: http.StatusBadGateway},
+				attempt: 2,
+			},
+			wantWait:  2 * time.Second,
+			wantRetry: true,
+		},
+		{
+			name: "standard case: capped by max backoff",
+			args: args{
+				req:     getRequest,
+				err:     http.ErrHandlerTimeout,
+				attempt: 4,
+			},
+			wantWait:  3 * time.Second,
+			wantRetry: true,
+		},
+		{
+			name: "standard case: Retry-After seconds",
+			args: args{
+				req: getRequest,
+				res: &http.Response{
+					StatusCode: http.StatusServiceUnavailable,
+					Header:     http.Header{"Retry-After": {"2"}},
+				},
+				attempt: 1,
+			},
+			wantWait:  2 * time.Second,
+			wantRetry: true,
+		},
+		{
+			name: "standard case: Retry-After beyond max backoff",
+			args: args{
+				req: getRequest,
+				res: &http.Response{
+					StatusCode: http.StatusServiceUnavailable,
+					Header:     http.Header{"Retry-After": {"7"}},
+				},
+				attempt: 1,
+			},
+			wantRetry: false,
+		},
+		{
+			name: "standard case: Retry-After within context deadline",
+			args: args{
+				req: deadlineRequest,
+				res: &http.Response{
+					StatusCode: http.StatusTooManyRequests,
+					Header:     http.Header{"Retry-After": {"1"}},
+				},
+				attempt: 1,
+			},
+			wantWait:  time.Second,
+			wantRetry: true,
+		},
+		{
+			name: "standard case: Retry-After beyond context deadline",
+			args: args{
+				req: deadlineRequest,
+				res: &http.Response{
+					StatusCode: http.StatusTooManyRequests,
+					Header:     http.Header{"Retry-After": {"3"}},
+				},
+				attempt: 1,
+			},
+			wantRetry: false,
+		},
+		{
+			name: "standard case: max attempts reached",
+			args: args{
+				req:     getRequest,
+				res:     &http.Response{StatusCode: http.StatusServiceUnavailable},
+				attempt: 5,
+			},
+			wantRetry: false,
+		},
+		{
+			name: "standard case: not idempotent method",
+			args: args{
+				req:     postRequest,
+				res:     &http.Response{StatusCode: http.StatusServiceUnavailable},
+				attempt: 1,
+			},
+			wantRetry: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotWait, gotRetry := policy.Retry(tt.args.req, tt.args.res, tt.args.err, tt.args.attempt)
+			if gotRetry != tt.wantRetry {
+				t.Errorf("Retry() retry = %v, want %v", gotRetry, tt.wantRetry)
+			}
+			if gotWait != tt.wantWait {
+				t.Errorf("Retry() wait = %v, want %v", gotWait, tt.wantWait)
+			}
+		})
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "standard case: seconds", value: "120", want: 2 * time.Minute, wantOK: true},
+		{name: "standard case: http date", value: "Mon, 01 Feb 2021 00:00:30 GMT", want: 30 * time.Second, wantOK: true},
+		{name: "standard case: past http date", value: "Sun, 31 Jan 2021 00:00:00 GMT", want: 0, wantOK: true},
+		{name: "standard case: empty", value: "", wantOK: false},
+		{name: "error case: invalid value", value: "invalid", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if ok != tt.wantOK {
+				t.Errorf("parseRetryAfter() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("parseRetryAfter() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package kafun
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// 測定データの少ない日とみなす1日の時間数。これ未満の日は信頼性の注記に数える。
+const seasonMinDailyHours = hoursPerDay / 2
+
+// SeasonRule は飛散開始日と飛散終了日の判定の条件を表す。
+// 判定には1時間の花粉数の日平均を使うので、欠測の時間があっても日ごとの値を比べられる。
+type SeasonRule struct {
+	// Threshold は花粉が飛散した日とみなす1時間の花粉数の日平均(個/立方メートル)の下限。
+	Threshold float64
+
+	// StartDays は飛散開始とみなす、花粉が飛散した日が続く日数。
+	// 1月1日以降で、この日数だけ続いた最初の日を飛散開始日とする。
+	StartDays int
+
+	// EndDays は飛散終了とみなす、花粉が飛散しなかった日が続く日数。
+	// 花粉が飛散した最後の日の後にこの日数だけ測定データがある場合に、最後の日を飛散終了日とする。
+	EndDays int
+}
+
+// DefaultSeasonRule は日平均1個以上の日が2日続いた最初の日を飛散開始日、
+// その後に3日以上日平均1個未満の日が続く、日平均1個以上の最後の日を飛散終了日とする。
+var DefaultSeasonRule = SeasonRule{Threshold: 1, StartDays: 2, EndDays: 3}
+
+func (r SeasonRule) validate() error {
+	if r.Threshold <= 0 || r.StartDays < 1 || r.EndDays < 1 {
+		return xerrors.Errorf("invalid season rule: threshold must be positive and days must be at least 1: %+v", r)
+	}
+
+	return nil
+}
+
+// Season は測定局ごとの1年の花粉の飛散期間を表す。
+type Season struct {
+	SokuteikyokuCode string // 測定局コード
+	SokuteikyokuName string // 測定局名
+	TodofukenCode    string // 都道府県コード(JIS)
+	Year             int    // 年
+
+	Start time.Time   // 飛散開始日。判定できない場合はゼロ値
+	End   time.Time   // 飛散終了日。判定できない場合はゼロ値
+	Peak  *DailyKafun // 飛散期間で日平均が最大の日。同じ値の場合は早い日
+	Total int         // 飛散期間の花粉数の合計
+
+	// Coverage は飛散期間の時間のうち花粉数のある時間の割合(0〜1)。
+	// 飛散開始日が判定できない場合はその年の測定データの最初の日から、
+	// 飛散終了日が判定できない場合は最後の日までを飛散期間とする。
+	Coverage float64
+
+	// Notes は判定の信頼性に関わる注記。
+	Notes []string
+}
+
+// HasStart は飛散開始日を判定できたかどうかを返す。
+func (s *Season) HasStart() bool {
+	return !s.Start.IsZero()
+}
+
+// HasEnd は飛散終了日を判定できたかどうかを返す。
+func (s *Season) HasEnd() bool {
+	return !s.End.IsZero()
+}
+
+// DetectSeasons は測定データから測定局と年ごとに飛散期間を判定し、測定局コード、年の順で返す。
+func DetectSeasons(data SokuteiData, rule SeasonRule) ([]*Season, error) {
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+
+	type seasonKey struct {
+		code string
+		year int
+	}
+
+	var keys []seasonKey
+	days := make(map[seasonKey][]*DailyKafun)
+	for _, d := range data.Daily() {
+		key := seasonKey{code: d.SokuteikyokuCode, year: d.Date.Year()}
+		if _, ok := days[key]; !ok {
+			keys = append(keys, key)
+		}
+		days[key] = append(days[key], d)
+	}
+
+	// Daily は測定局コード、測定年月日の順なので keys もその順になる
+	seasons := make([]*Season, len(keys))
+	for i, key := range keys {
+		seasons[i] = detectSeason(key.year, days[key], rule)
+	}
+
+	return seasons, nil
+}
+
+// detectSeason は1つの測定局の1年分の日ごとの集計から飛散期間を判定する。days は測定年月日の順。
+func detectSeason(year int, days []*DailyKafun, rule SeasonRule) *Season {
+	last := days[len(days)-1]
+	season := &Season{
+		SokuteikyokuCode: last.SokuteikyokuCode,
+		SokuteikyokuName: last.SokuteikyokuName,
+		TodofukenCode:    last.TodofukenCode,
+		Year:             year,
+	}
+
+	active := func(d *DailyKafun) bool {
+		return d.Hours > 0 && d.Mean() >= rule.Threshold
+	}
+
+	first, end := 0, len(days)-1
+	start := -1
+	for i := range days {
+		if consecutiveDays(days[i:], rule.StartDays, active) {
+			start = i
+			break
+		}
+	}
+
+	if start < 0 {
+		season.addNote("no %d consecutive days with daily mean of %g or more", rule.StartDays, rule.Threshold)
+	} else {
+		season.Start = days[start].Date
+		first = start
+		if start == 0 && !isNewYearsDay(days[0].Date) {
+			season.addNote("season may have started before the first day with data (%s)", formatDate(days[0].Date))
+		}
+
+		lastActive := start
+		for i := start; i < len(days); i++ {
+			if active(days[i]) {
+				lastActive = i
+			}
+		}
+
+		inactive := func(d *DailyKafun) bool { return !active(d) }
+		if consecutiveDays(days[lastActive+1:], rule.EndDays, inactive) &&
+			days[lastActive+1].Date.Sub(days[lastActive].Date) == 24*time.Hour {
+			season.End = days[lastActive].Date
+			end = lastActive
+		} else {
+			season.addNote(
+				"season end not confirmed: less than %d days of data after the last day with daily mean of %g or more (%s)",
+				rule.EndDays,
+				rule.Threshold,
+				formatDate(days[lastActive].Date),
+			)
+		}
+	}
+
+	period := days[first : end+1]
+	hours, lowHours := 0, 0
+	for _, d := range period {
+		season.Total += d.Total
+		hours += d.Hours
+		if d.Hours < seasonMinDailyHours {
+			lowHours++
+		}
+		if season.Peak == nil || d.Mean() > season.Peak.Mean() {
+			season.Peak = d
+		}
+	}
+
+	periodDays := int(period[len(period)-1].Date.Sub(period[0].Date).Hours()/24) + 1
+	season.Coverage = float64(hours) / float64(periodDays*hoursPerDay)
+	if missing := periodDays - len(period); missing > 0 {
+		season.addNote("%d days without data in the season", missing)
+	}
+	if lowHours > 0 {
+		season.addNote("%d days with less than %d hours of data in the season", lowHours, seasonMinDailyHours)
+	}
+
+	return season
+}
+
+// consecutiveDays は days の先頭から n 日が連続した日付で、すべて cond を満たすかどうかを返す。
+func consecutiveDays(days []*DailyKafun, n int, cond func(*DailyKafun) bool) bool {
+	if len(days) < n {
+		return false
+	}
+
+	for i := 0; i < n; i++ {
+		if !cond(days[i]) {
+			return false
+		}
+		if i > 0 && days[i].Date.Sub(days[i-1].Date) != 24*time.Hour {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *Season) addNote(format string, v ...interface{}) {
+	s.Notes = append(s.Notes, fmt.Sprintf(format, v...))
+}
+
+func isNewYearsDay(t time.Time) bool {
+	return t.Month() == time.January && t.Day() == 1
+}
+
+// formatDate は日付を yyyy-MM-dd で返す。
+func formatDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,152 @@
+package kafun
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// dailyFixture は from から1日ごとに、1時間の花粉数が counts の値の24時間分の測定データを返す。
+// counts の値が負の日は測定データを作らない。
+func dailyFixture(t *testing.T, code string, from time.Time, counts ...int) SokuteiData {
+	t.Helper()
+	var data SokuteiData
+	for i, count := range counts {
+		if count < 0 {
+			continue
+		}
+		date := from.AddDate(0, 0, i).Format(sokuteiNengappiLayout)
+		for hour := 1; hour <= hoursPerDay; hour++ {
+			data = append(data, &HourlySokuteiData{
+				SokuteikyokuCode: code,
+				SokuteiNengappi:  date,
+				SokuteiJikoku:    strconv.Itoa(hour),
+				KafunNum:         count,
+			})
+		}
+	}
+
+	return data
+}
+
+func TestDetectSeasons(t *testing.T) {
+	feb := func(d int) time.Time {
+		return time.Date(2021, 2, d, 0, 0, 0, 0, jst)
+	}
+
+	tests := []struct {
+		name      string
+		data      SokuteiData
+		rule      SeasonRule
+		wantStart time.Time
+		wantEnd   time.Time
+		wantPeak  time.Time
+		wantTotal int
+		wantNotes []string
+	}{
+		{
+			name:      "standard case: start and end",
+			data:      dailyFixture(t, "1", feb(1), 0, 1, 0, 2, 3, 10, 0, 2, 0, 0, 0),
+			rule:      DefaultSeasonRule,
+			wantStart: feb(4),
+			wantEnd:   feb(8),
+			wantPeak:  feb(6),
+			wantTotal: (2 + 3 + 10 + 0 + 2) * 24,
+		},
+		{
+			name:      "standard case: season started before data",
+			data:      dailyFixture(t, "1", feb(1), 5, 5, 0, 0, 0),
+			rule:      DefaultSeasonRule,
+			wantStart: feb(1),
+			wantEnd:   feb(2),
+			wantPeak:  feb(1),
+			wantTotal: 10 * 24,
+			wantNotes: []string{"season may have started before the first day with data (2021-02-01)"},
+		},
+		{
+			name:      "standard case: end not confirmed and missing day",
+			data:      dailyFixture(t, "1", feb(1), 0, 3, -1, 4, 4, 0),
+			rule:      DefaultSeasonRule,
+			wantStart: feb(4),
+			wantPeak:  feb(4),
+			wantTotal: 8 * 24,
+			wantNotes: []string{
+				"season end not confirmed: less than 3 days of data after the last day with daily mean of 1 or more (2021-02-05)",
+			},
+		},
+		{
+			name:      "standard case: gap in the season",
+			data:      dailyFixture(t, "1", feb(1), 2, 2, -1, 2, 0, 0, 0),
+			rule:      SeasonRule{Threshold: 1, StartDays: 2, EndDays: 3},
+			wantStart: feb(1),
+			wantEnd:   feb(4),
+			wantPeak:  feb(1),
+			wantTotal: 6 * 24,
+			wantNotes: []string{
+				"season may have started before the first day with data (2021-02-01)",
+				"1 days without data in the season",
+			},
+		},
+		{
+			name:      "standard case: no season",
+			data:      dailyFixture(t, "1", feb(1), 0, 1, 0),
+			rule:      DefaultSeasonRule,
+			wantPeak:  feb(2),
+			wantTotal: 24,
+			wantNotes: []string{"no 2 consecutive days with daily mean of 1 or more"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seasons, err := DetectSeasons(tt.data, tt.rule)
+			if err != nil {
+				t.Fatalf("DetectSeasons() error = %v", err)
+			}
+			if len(seasons) != 1 {
+				t.Fatalf("DetectSeasons() got %d seasons, want 1", len(seasons))
+			}
+
+			s := seasons[0]
+			if !s.Start.Equal(tt.wantStart) || !s.End.Equal(tt.wantEnd) || !s.Peak.Date.Equal(tt.wantPeak) {
+				t.Errorf("DetectSeasons() start, end, peak = %v, %v, %v, want %v, %v, %v",
+					s.Start, s.End, s.Peak.Date, tt.wantStart, tt.wantEnd, tt.wantPeak)
+			}
+			if s.Total != tt.wantTotal {
+				t.Errorf("DetectSeasons() total = %v, want %v", s.Total, tt.wantTotal)
+			}
+			if !reflect.DeepEqual(s.Notes, tt.wantNotes) {
+				t.Errorf("DetectSeasons() notes = %q, want %q", s.Notes, tt.wantNotes)
+			}
+		})
+	}
+}
+
+func TestDetectSeasons_stationsAndCoverage(t *testing.T) {
+	data := append(
+		dailyFixture(t, "2", time.Date(2021, 2, 1, 0, 0, 0, 0, jst), 0, 1, 1, 0, 0, 0),
+		dailyFixture(t, "1", time.Date(2021, 2, 1, 0, 0, 0, 0, jst), 0, 1, 1, 0, 0, 0)...,
+	)
+	// 測定局1は2日目の12時までしか測定データがない
+	data = data[:len(data)-6*24+36]
+
+	seasons, err := DetectSeasons(data, DefaultSeasonRule)
+	if err != nil {
+		t.Fatalf("DetectSeasons() error = %v", err)
+	}
+	if len(seasons) != 2 || seasons[0].SokuteikyokuCode != "1" || seasons[1].SokuteikyokuCode != "2" {
+		t.Fatalf("DetectSeasons() got = %+v, want seasons of station 1 and 2", seasons)
+	}
+	if seasons[0].Coverage != 0.75 {
+		t.Errorf("DetectSeasons() coverage = %v, want 0.75", seasons[0].Coverage)
+	}
+	if seasons[1].Coverage != 1 {
+		t.Errorf("DetectSeasons() coverage = %v, want 1", seasons[1].Coverage)
+	}
+}
+
+func TestDetectSeasons_invalidRule(t *testing.T) {
+	if _, err := DetectSeasons(nil, SeasonRule{Threshold: 1, StartDays: 0, EndDays: 3}); err == nil {
+		t.Errorf("DetectSeasons() error = nil, want error")
+	}
+}
@@ -0,0 +1,244 @@
+package kafun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultConcurrency は NewClient で作成したクライアントの分割リクエストの同時実行数。
+const DefaultConcurrency = 4
+
+// 年月(yyyyMM)のレイアウト。
+const yearMonthLayout = "200601"
+
+// MergeOrder は分割リクエストの結果を結合する順序を表す。
+type MergeOrder int
+
+const (
+	// MergeOrderRequest は分割したリクエストの順(年月、測定局の順)に結合する。
+	MergeOrderRequest MergeOrder = iota
+
+	// MergeOrderCompletion はレスポンスを受信した順に結合する。
+	MergeOrderCompletion
+)
+
+// SubSearchError は分割したリクエストの失敗を表す。
+type SubSearchError struct {
+	Param *SearchParam // 失敗したリクエストのパラメータ
+	Err   error        // 失敗の原因
+}
+
+func (e *SubSearchError) Error() string {
+	return fmt.Sprintf(
+		"failed to search with startYM=%s, endYM=%s, todofukenCode=%s, sokuteikyokuCode=%s: %v",
+		e.Param.StartYM,
+		e.Param.EndYM,
+		e.Param.TodofukenCode,
+		e.Param.SokuteikyokuCode,
+		e.Err,
+	)
+}
+
+// Unwrap は失敗の原因を返す。
+func (e *SubSearchError) Unwrap() error {
+	return e.Err
+}
+
+// PartialSearchError は一部の分割リクエストが失敗したことを表す。
+// Client.AllowPartial が true の場合に、取得できたデータとともに返される。
+type PartialSearchError struct {
+	Errors []*SubSearchError // 失敗したリクエスト(分割した順)
+}
+
+func (e *PartialSearchError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, subErr := range e.Errors {
+		messages = append(messages, subErr.Error())
+	}
+
+	return fmt.Sprintf("%d sub requests failed: %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// As は失敗した分割リクエストのエラーを順に調べ、target に当てはまる最初のエラーを target に設定する。
+// errors.As で個々の失敗の原因(*APIError など)を取り出せる。
+func (e *PartialSearchError) As(target interface{}) bool {
+	for _, subErr := range e.Errors {
+		if errors.As(subErr, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Is は失敗した分割リクエストのエラーのいずれかが target に当てはまるかどうかを返す。
+func (e *PartialSearchError) Is(target error) bool {
+	for _, subErr := range e.Errors {
+		if errors.Is(subErr, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitSearchParam は検索パラメータを月ごとに分割する。
+// byStation が true の場合は、カンマ区切りで指定された測定局ごとにも分割する。
+// 終了年月が指定されていない場合は月ごとの分割はしない。
+func splitSearchParam(param *SearchParam, byStation bool) ([]*SearchParam, error) {
+	months := [][2]string{{param.StartYM, param.EndYM}}
+
+	if len(param.EndYM) != 0 {
+		start, err := time.Parse(yearMonthLayout, param.StartYM)
+		if err != nil {
+			return nil, &ValidationError{Fields: map[string]string{"StartYM": fmt.Sprintf("invalid year month: %s", param.StartYM)}}
+		}
+
+		end, err := time.Parse(yearMonthLayout, param.EndYM)
+		if err != nil {
+			return nil, &ValidationError{Fields: map[string]string{"EndYM": fmt.Sprintf("invalid year month: %s", param.EndYM)}}
+		}
+
+		if end.Before(start) {
+			return nil, &ValidationError{Fields: map[string]string{"EndYM": fmt.Sprintf("must not be before StartYM: %s", param.StartYM)}}
+		}
+
+		months = months[:0]
+		for m := start; !m.After(end); m = m.AddDate(0, 1, 0) {
+			ym := m.Format(yearMonthLayout)
+			months = append(months, [2]string{ym, ym})
+		}
+	}
+
+	stations := []string{param.SokuteikyokuCode}
+	if byStation && strings.Contains(param.SokuteikyokuCode, ",") {
+		stations = stations[:0]
+		for _, code := range strings.Split(param.SokuteikyokuCode, ",") {
+			if code = strings.TrimSpace(code); len(code) != 0 {
+				stations = append(stations, code)
+			}
+		}
+	}
+
+	params := make([]*SearchParam, 0, len(months)*len(stations))
+	for _, month := range months {
+		for _, station := range stations {
+			params = append(params, &SearchParam{
+				StartYM:          month[0],
+				EndYM:            month[1],
+				TodofukenCode:    param.TodofukenCode,
+				SokuteikyokuCode: station,
+			})
+		}
+	}
+
+	return params, nil
+}
+
+// searchAll は分割したリクエストを Concurrency の数まで並行して実行し、結果を結合する。
+func (c *Client) searchAll(ctx context.Context, params []*SearchParam) (SokuteiData, error) {
+	concurrency := c.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(params) {
+		concurrency = len(params)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		index int
+		data  SokuteiData
+		err   error
+	}
+
+	jobs := make(chan int)
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				data, err := c.search(ctx, params[index])
+				results <- result{index: index, data: data, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for index := range params {
+			select {
+			case jobs <- index:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	collected := make([]SokuteiData, len(params))
+	failed := make([]*SubSearchError, len(params))
+	var completed SokuteiData
+	var firstErr error
+
+	for r := range results {
+		if r.err != nil {
+			subErr := &SubSearchError{Param: params[r.index], Err: r.err}
+			if !c.AllowPartial {
+				if firstErr == nil {
+					firstErr = subErr
+					cancel()
+				}
+				continue
+			}
+			failed[r.index] = subErr
+			continue
+		}
+
+		collected[r.index] = r.data
+		completed = append(completed, r.data...)
+	}
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	// 呼び出し元のコンテキストがキャンセルされた場合、残りのリクエストは実行されていない
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	response := completed
+	if c.Order == MergeOrderRequest {
+		response = nil
+		for _, data := range collected {
+			response = append(response, data...)
+		}
+	}
+
+	var failures []*SubSearchError
+	for _, subErr := range failed {
+		if subErr != nil {
+			failures = append(failures, subErr)
+		}
+	}
+
+	if len(failures) != 0 {
+		return response, &PartialSearchError{Errors: failures}
+	}
+
+	return response, nil
+}
@@ -0,0 +1,284 @@
+package kafun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// monthlyHandler は Start_YM と SKT_CD をそのまま測定年月日と測定局コードに入れた1件のデータを返すモックサーバのハンドラ。
+// SKT_CD の指定がない場合の測定局コードは 00000000 にする。
+// failStartYM に一致する Start_YM のリクエストには 500 を返す。
+func monthlyHandler(t *testing.T, failStartYM string) http.HandlerFunc {
+	t.Helper()
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("Start_YM") == failStartYM {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		sokuteikyokuCode := q.Get("SKT_CD")
+		if len(sokuteikyokuCode) == 0 {
+			sokuteikyokuCode = "00000000"
+		}
+		body := fmt.Sprintf(`[{
+			"SKT_CD": "%s",
+			"AMeDAS_CD": "00000",
+			"SKT_NNGP": "%s01",
+			"SKT_HH": "01",
+			"SKT_NM": "テスト観測所",
+			"SKT_TYPE": "0",
+			"TDFKN_CD": "%s",
+			"TDFKN_NM": "テスト都道府県",
+			"SKCHSN_CD": "000000",
+			"SKCHSN_NM": "テスト市町村",
+			"KFN_NUM": "0",
+			"AMeDAS_WD": "00"
+		}]`, sokuteikyokuCode, q.Get("Start_YM"), q.Get("TDFKN_CD"))
+		sjisStr, _ := encodeUTF8ToSJIS(t, []byte(body))
+		w.WriteHeader(http.StatusOK)
+		w.Write(sjisStr)
+	}
+}
+
+func Test_splitSearchParam(t *testing.T) {
+	type args struct {
+		param     *SearchParam
+		byStation bool
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []*SearchParam
+		wantErr bool
+	}{
+		{
+			name: "standard case: split by month across year",
+			args: args{
+				param: &SearchParam{StartYM: "202012", EndYM: "202102", TodofukenCode: "13"},
+			},
+			want: []*SearchParam{
+				{StartYM: "202012", EndYM: "202012", TodofukenCode: "13"},
+				{StartYM: "202101", EndYM: "202101", TodofukenCode: "13"},
+				{StartYM: "202102", EndYM: "202102", TodofukenCode: "13"},
+			},
+		},
+		{
+			name: "standard case: omit endYM",
+			args: args{
+				param: &SearchParam{StartYM: "202102", TodofukenCode: "13"},
+			},
+			want: []*SearchParam{
+				{StartYM: "202102", TodofukenCode: "13"},
+			},
+		},
+		{
+			name: "standard case: split by month and station",
+			args: args{
+				param: &SearchParam{
+					StartYM:          "202102",
+					EndYM:            "202103",
+					TodofukenCode:    "13",
+					SokuteikyokuCode: "00000001, 00000002",
+				},
+				byStation: true,
+			},
+			want: []*SearchParam{
+				{StartYM: "202102", EndYM: "202102", TodofukenCode: "13", SokuteikyokuCode: "00000001"},
+				{StartYM: "202102", EndYM: "202102", TodofukenCode: "13", SokuteikyokuCode: "00000002"},
+				{StartYM: "202103", EndYM: "202103", TodofukenCode: "13", SokuteikyokuCode: "00000001"},
+				{StartYM: "202103", EndYM: "202103", TodofukenCode: "13", SokuteikyokuCode: "00000002"},
+			},
+		},
+		{
+			name: "standard case: keep multiple stations in one request",
+			args: args{
+				param: &SearchParam{
+					StartYM:          "202102",
+					EndYM:            "202102",
+					TodofukenCode:    "13",
+					SokuteikyokuCode: "00000001,00000002",
+				},
+			},
+			want: []*SearchParam{
+				{StartYM: "202102", EndYM: "202102", TodofukenCode: "13", SokuteikyokuCode: "00000001,00000002"},
+			},
+		},
+		{
+			name: "error case: invalid month",
+			args: args{
+				param: &SearchParam{StartYM: "202113", EndYM: "202201", TodofukenCode: "13"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error case: month 00 is not a year month",
+			args: args{
+				param: &SearchParam{StartYM: "000000", EndYM: "000001", TodofukenCode: "01"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "error case: endYM is before startYM",
+			args: args{
+				param: &SearchParam{StartYM: "202103", EndYM: "202102", TodofukenCode: "13"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitSearchParam(tt.args.param, tt.args.byStation)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitSearchParam() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitSearchParam() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_searchAll(t *testing.T) {
+	type fields struct {
+		concurrency    int
+		splitByStation bool
+		allowPartial   bool
+		failStartYM    string
+	}
+	type want struct {
+		nengappi      []string
+		stations      []string
+		failedStartYM []string
+		wantErr       bool
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		param  *SearchParam
+		want   want
+	}{
+		{
+			name:   "standard case: merge months in request order",
+			fields: fields{concurrency: 3},
+			param:  &SearchParam{StartYM: "202102", EndYM: "202106", TodofukenCode: "13"},
+			want: want{
+				nengappi: []string{"20210201", "20210301", "20210401", "20210501", "20210601"},
+				stations: []string{"00000000", "00000000", "00000000", "00000000", "00000000"},
+			},
+		},
+		{
+			name:   "standard case: merge stations in request order",
+			fields: fields{concurrency: 2, splitByStation: true},
+			param: &SearchParam{
+				StartYM:          "202102",
+				EndYM:            "202103",
+				TodofukenCode:    "13",
+				SokuteikyokuCode: "00000001,00000002",
+			},
+			want: want{
+				nengappi: []string{"20210201", "20210201", "20210301", "20210301"},
+				stations: []string{"00000001", "00000002", "00000001", "00000002"},
+			},
+		},
+		{
+			name:   "standard case: partial failure is reported with successful data",
+			fields: fields{concurrency: 2, allowPartial: true, failStartYM: "202103"},
+			param:  &SearchParam{StartYM: "202102", EndYM: "202104", TodofukenCode: "13"},
+			want: want{
+				nengappi:      []string{"20210201", "20210401"},
+				stations:      []string{"00000000", "00000000"},
+				failedStartYM: []string{"202103"},
+				wantErr:       true,
+			},
+		},
+		{
+			name:   "error case: failure aborts search",
+			fields: fields{concurrency: 2, failStartYM: "202103"},
+			param:  &SearchParam{StartYM: "202102", EndYM: "202104", TodofukenCode: "13"},
+			want: want{
+				wantErr: true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testServer := httptest.NewServer(monthlyHandler(t, tt.fields.failStartYM))
+			defer testServer.Close()
+			testServerURL, _ := url.Parse(testServer.URL)
+			c := &Client{
+				URL:            testServerURL,
+				HTTPClient:     http.DefaultClient,
+				Concurrency:    tt.fields.concurrency,
+				SplitByStation: tt.fields.splitByStation,
+				AllowPartial:   tt.fields.allowPartial,
+			}
+
+			got, err := c.Search(ctx, tt.param)
+			if (err != nil) != tt.want.wantErr {
+				t.Fatalf("Search() error = %v, wantErr %v", err, tt.want.wantErr)
+			}
+
+			var partialErr *PartialSearchError
+			if errors.As(err, &partialErr) {
+				var failedStartYM []string
+				for _, subErr := range partialErr.Errors {
+					failedStartYM = append(failedStartYM, subErr.Param.StartYM)
+				}
+				if !reflect.DeepEqual(failedStartYM, tt.want.failedStartYM) {
+					t.Errorf("Search() failed startYM = %v, want %v", failedStartYM, tt.want.failedStartYM)
+				}
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+					t.Errorf("Search() error = %v, want to unwrap *APIError with status 500", err)
+				}
+			} else if len(tt.want.failedStartYM) != 0 {
+				t.Errorf("Search() error = %v, want *PartialSearchError", err)
+			}
+
+			var nengappi, stations []string
+			for _, hsd := range got {
+				nengappi = append(nengappi, hsd.SokuteiNengappi)
+				stations = append(stations, hsd.SokuteikyokuCode)
+			}
+			if !reflect.DeepEqual(nengappi, tt.want.nengappi) {
+				t.Errorf("Search() nengappi = %v, want %v", nengappi, tt.want.nengappi)
+			}
+			if !reflect.DeepEqual(stations, tt.want.stations) {
+				t.Errorf("Search() stations = %v, want %v", stations, tt.want.stations)
+			}
+		})
+	}
+}
+
+func TestPartialSearchError_As(t *testing.T) {
+	err := error(&PartialSearchError{Errors: []*SubSearchError{
+		{Param: &SearchParam{StartYM: "202102"}, Err: context.DeadlineExceeded},
+		{Param: &SearchParam{StartYM: "202103"}, Err: &APIError{StatusCode: http.StatusServiceUnavailable}},
+	}})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("errors.As() = %v, want *APIError with status 503", apiErr)
+	}
+	var subErr *SubSearchError
+	if !errors.As(err, &subErr) || subErr.Param.StartYM != "202102" {
+		t.Errorf("errors.As() = %v, want the first *SubSearchError", subErr)
+	}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		t.Errorf("errors.As() = true, want false for *ValidationError")
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("errors.Is() = false, want true for context.DeadlineExceeded")
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Errorf("errors.Is() = true, want false for context.Canceled")
+	}
+}
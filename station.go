@@ -0,0 +1,237 @@
+package kafun
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// Station は測定局を表す。測定局の項目は HourlySokuteiData と同じJSONキーで表す。
+type Station struct {
+	// 測定局コード
+	Code string `json:"SKT_CD"`
+
+	// 測定局名
+	Name string `json:"SKT_NM"`
+
+	// 測定局名のひらがな。不明な場合は空文字列
+	Kana string `json:"kana,omitempty"`
+
+	// 測定局名のローマ字。不明な場合は空文字列
+	Romaji string `json:"romaji,omitempty"`
+
+	// 測定局のタイプ
+	Type string `json:"SKT_TYPE,omitempty"`
+
+	// 都道府県コード(JIS)
+	TodofukenCode string `json:"TDFKN_CD"`
+
+	// 都道府県名
+	TodofukenName string `json:"TDFKN_NM,omitempty"`
+
+	// 測定局の市区町村コード
+	ShichosonCode string `json:"SKCHSN_CD,omitempty"`
+
+	// 測定局の市区町村名
+	ShichosonName string `json:"SKCHSN_NM,omitempty"`
+
+	// 対応するアメダスコード
+	AMeDASCode string `json:"AMeDAS_CD,omitempty"`
+
+	// 緯度(度)。不明な場合は nil
+	Latitude *float64 `json:"latitude,omitempty"`
+
+	// 経度(度)。不明な場合は nil
+	Longitude *float64 `json:"longitude,omitempty"`
+}
+
+// stationsJSON はパッケージに埋め込んだ測定局の一覧。
+// 測定局の情報は公開されている一覧がないため、確認できた測定局だけを登録している。
+// 測定データから一覧を作り直す場合は kafun stations build を使う。make stations はAPIから47都道府県の測定データを取得して作り直す。
+//
+//go:embed data/stations.json
+var stationsJSON []byte
+
+var (
+	defaultStationCatalog     *StationCatalog
+	defaultStationCatalogOnce sync.Once
+)
+
+// DefaultStationCatalog はパッケージに埋め込んだ測定局の一覧を返す。
+func DefaultStationCatalog() *StationCatalog {
+	defaultStationCatalogOnce.Do(func() {
+		catalog, err := LoadStationCatalog(bytes.NewReader(stationsJSON))
+		if err != nil {
+			panic(xerrors.Errorf("failed to load embedded station catalog: %w", err))
+		}
+		defaultStationCatalog = catalog
+	})
+
+	return defaultStationCatalog
+}
+
+// StationCatalog は測定局コードの順に並べた測定局の一覧を表す。
+type StationCatalog struct {
+	stations []Station
+	byCode   map[string]int
+}
+
+// NewStationCatalog は stations から StationCatalog を作成する。同じ測定局コードの測定局は後のものを使う。
+func NewStationCatalog(stations []Station) *StationCatalog {
+	byCode := make(map[string]Station, len(stations))
+	for _, s := range stations {
+		byCode[s.Code] = s
+	}
+
+	sorted := make([]Station, 0, len(byCode))
+	for _, s := range byCode {
+		sorted = append(sorted, s)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Code < sorted[j].Code
+	})
+
+	catalog := &StationCatalog{stations: sorted, byCode: make(map[string]int, len(sorted))}
+	for i, s := range sorted {
+		catalog.byCode[s.Code] = i
+	}
+
+	return catalog
+}
+
+// LoadStationCatalog は測定局のJSON配列を読み込んで StationCatalog を作成する。
+func LoadStationCatalog(r io.Reader) (*StationCatalog, error) {
+	var stations []Station
+	if err := json.NewDecoder(r).Decode(&stations); err != nil {
+		return nil, xerrors.Errorf("failed to decode station catalog: %v", err)
+	}
+
+	for i, s := range stations {
+		if len(s.Code) == 0 {
+			return nil, xerrors.Errorf("station %d lacks SKT_CD", i)
+		}
+	}
+
+	return NewStationCatalog(stations), nil
+}
+
+// StationsFromSokuteiData は測定データに含まれる測定局を返す。
+// 同じ測定局の測定データが複数ある場合は後の測定データの項目を使う。測定データに緯度と経度はないので nil になる。
+func StationsFromSokuteiData(data SokuteiData) []Station {
+	stations := make([]Station, 0)
+	index := make(map[string]int)
+	for _, hsd := range data {
+		if len(hsd.SokuteikyokuCode) == 0 {
+			continue
+		}
+
+		s := Station{
+			Code:          hsd.SokuteikyokuCode,
+			Name:          hsd.SokuteikyokuName,
+			Type:          hsd.SokuteiType,
+			TodofukenCode: hsd.TodofukenCode,
+			TodofukenName: hsd.TodofukenName,
+			ShichosonCode: hsd.SokuteiShichosonCode,
+			ShichosonName: hsd.SokuteiShichosonName,
+			AMeDASCode:    hsd.AMeDASCode,
+		}
+		if i, ok := index[s.Code]; ok {
+			stations[i] = s
+			continue
+		}
+		index[s.Code] = len(stations)
+		stations = append(stations, s)
+	}
+
+	return stations
+}
+
+// Stations は測定局を測定局コードの順で返す。
+func (sc *StationCatalog) Stations() []Station {
+	return append([]Station(nil), sc.stations...)
+}
+
+// Len は測定局の数を返す。
+func (sc *StationCatalog) Len() int {
+	return len(sc.stations)
+}
+
+// Lookup は測定局コードに対応する測定局を返す。
+func (sc *StationCatalog) Lookup(code string) (Station, bool) {
+	i, ok := sc.byCode[code]
+	if !ok {
+		return Station{}, false
+	}
+
+	return sc.stations[i], true
+}
+
+// ByPrefecture は都道府県コードの測定局を測定局コードの順で返す。
+func (sc *StationCatalog) ByPrefecture(todofukenCode string) []Station {
+	var stations []Station
+	for _, s := range sc.stations {
+		if s.TodofukenCode == todofukenCode {
+			stations = append(stations, s)
+		}
+	}
+
+	return stations
+}
+
+// Merge は stations で測定局を更新した StationCatalog を返す。sc は変更しない。
+// 一覧にある測定局は空でない項目だけを更新するので、測定データにない緯度と経度は残る。
+func (sc *StationCatalog) Merge(stations []Station) *StationCatalog {
+	merged := sc.Stations()
+	for _, s := range stations {
+		i, ok := sc.byCode[s.Code]
+		if !ok {
+			merged = append(merged, s)
+			continue
+		}
+		merged[i] = mergeStation(merged[i], s)
+	}
+
+	return NewStationCatalog(merged)
+}
+
+func mergeStation(base, s Station) Station {
+	update := func(dst *string, src string) {
+		if len(src) != 0 {
+			*dst = src
+		}
+	}
+
+	update(&base.Name, s.Name)
+	update(&base.Kana, s.Kana)
+	update(&base.Romaji, s.Romaji)
+	update(&base.Type, s.Type)
+	update(&base.TodofukenCode, s.TodofukenCode)
+	update(&base.TodofukenName, s.TodofukenName)
+	update(&base.ShichosonCode, s.ShichosonCode)
+	update(&base.ShichosonName, s.ShichosonName)
+	update(&base.AMeDASCode, s.AMeDASCode)
+	if s.Latitude != nil {
+		base.Latitude = s.Latitude
+	}
+	if s.Longitude != nil {
+		base.Longitude = s.Longitude
+	}
+
+	return base
+}
+
+// WriteJSON は測定局の一覧をインデント付きのJSON配列で出力する。LoadStationCatalog で読み込める。
+func (sc *StationCatalog) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(sc.stations, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
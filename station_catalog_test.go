@@ -0,0 +1,31 @@
+//go:build catalog
+
+package kafun
+
+import "testing"
+
+// minCatalogStations は組み込みの測定局の一覧に登録されているべき測定局の数の下限。
+// 花粉観測システムは全国の約120地点で観測している。
+const minCatalogStations = 100
+
+// TestDefaultStationCatalog_coverage は make stations で作り直した組み込みの一覧を検証する。
+// 組み込みの一覧はまだ全国の測定局を含まないので、go test -tags catalog の場合だけ実行する。
+// 全国の一覧を組み込んだらビルドタグを外す。
+func TestDefaultStationCatalog_coverage(t *testing.T) {
+	catalog := DefaultStationCatalog()
+	if catalog.Len() < minCatalogStations {
+		t.Errorf("DefaultStationCatalog() has %d stations, want at least %d", catalog.Len(), minCatalogStations)
+	}
+
+	for _, p := range Prefectures() {
+		if len(catalog.ByPrefecture(p.Code)) == 0 {
+			t.Errorf("DefaultStationCatalog() has no station in %s (%s)", p.Name, p.Code)
+		}
+	}
+
+	for _, s := range catalog.Stations() {
+		if len(s.Name) == 0 || !isPrefectureCode(s.TodofukenCode) {
+			t.Errorf("DefaultStationCatalog() station = %+v, want name and prefecture code", s)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package kafun
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// 測定局の検索の一致の種類ごとの点数。
+const (
+	stationScoreExact        = 100 // 測定局名、ひらがな、ローマ字と一致
+	stationScorePrefix       = 80  // 測定局名、ひらがな、ローマ字の前方と一致
+	stationScoreContains     = 60  // 測定局名、ひらがな、ローマ字の一部と一致
+	stationScoreMunicipality = 40  // 市区町村名と一致ないしは市区町村名の一部と一致
+	stationScorePrefecture   = 30  // 都道府県と一致
+	stationScoreTypo         = 20  // ローマ字の単語と1文字違い
+	stationScoreSubsequence  = 10  // 測定局名などが3文字以上の検索語の文字を順に含む
+)
+
+// StationMatch は測定局の検索結果を表す。
+type StationMatch struct {
+	Station Station // 測定局
+	Score   int     // 一致の度合い。大きいほどよく一致する
+}
+
+// AmbiguousStationError は測定局名に一致する測定局を1つに絞り込めないエラーを表す。
+type AmbiguousStationError struct {
+	Query      string    // 測定局名
+	Candidates []Station // 一致した測定局。一致の度合いの順
+}
+
+func (e *AmbiguousStationError) Error() string {
+	names := make([]string, len(e.Candidates))
+	for i, s := range e.Candidates {
+		names[i] = fmt.Sprintf("%s %s", s.Code, s.Name)
+	}
+
+	return fmt.Sprintf("station name %s is ambiguous: did you mean %s?", e.Query, strings.Join(names, ", "))
+}
+
+// StationNotFoundError は測定局名に一致する測定局がないエラーを表す。
+type StationNotFoundError struct {
+	Query string // 測定局名
+}
+
+func (e *StationNotFoundError) Error() string {
+	return fmt.Sprintf("no station matches %s", e.Query)
+}
+
+// Search は測定局名、ひらがな、ローマ字、市区町村名、都道府県で測定局を検索し、一致の度合いの順で返す。
+// 全角と半角、カタカナとひらがな、大文字と小文字、空白の有無、ローマ字の表記の揺れ(shi/si、長音など)を区別しない。
+func (sc *StationCatalog) Search(query string) []StationMatch {
+	key := normalizeStationKey(query)
+	if len(key) == 0 {
+		return nil
+	}
+
+	prefecture, isPrefecture := LookupPrefecture(query)
+
+	var matches []StationMatch
+	for _, s := range sc.stations {
+		score := scoreStation(s, key)
+		if isPrefecture && s.TodofukenCode == prefecture.Code && score < stationScorePrefecture {
+			score = stationScorePrefecture
+		}
+		if score > 0 {
+			matches = append(matches, StationMatch{Station: s, Score: score})
+		}
+	}
+
+	// 同じ程度に一致する場合は測定局名の短い、より検索語に近い測定局を先にする
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return len([]rune(matches[i].Station.Name)) < len([]rune(matches[j].Station.Name))
+	})
+
+	return matches
+}
+
+// Resolve は測定局名に一致する測定局を1つ返す。
+// 測定局名、ひらがな、ローマ字と完全ないしは前方で一致する測定局が1つだけの場合か、
+// 完全に一致する測定局が1つだけの場合に解決できる。都道府県や市区町村名だけの一致、
+// 綴りの誤りなどの弱い一致は解決せず、候補として *AmbiguousStationError で返す。
+// 一致する測定局がない場合は *StationNotFoundError を返す。
+func (sc *StationCatalog) Resolve(query string) (Station, error) {
+	matches := sc.Search(query)
+	if len(matches) == 0 {
+		return Station{}, &StationNotFoundError{Query: query}
+	}
+	if matches[0].Score >= stationScorePrefix {
+		if len(matches) == 1 || matches[1].Score < stationScorePrefix {
+			return matches[0].Station, nil
+		}
+		if matches[0].Score == stationScoreExact && matches[1].Score < stationScoreExact {
+			return matches[0].Station, nil
+		}
+	}
+
+	// 候補は同じ程度に一致する測定局に絞る
+	var candidates []Station
+	for _, m := range matches {
+		if m.Score < matches[0].Score && len(candidates) >= 2 {
+			break
+		}
+		candidates = append(candidates, m.Station)
+		if len(candidates) == 10 {
+			break
+		}
+	}
+
+	return Station{}, &AmbiguousStationError{Query: query, Candidates: candidates}
+}
+
+// scoreStation は正規化した検索語に対する測定局の一致の度合いを返す。一致しない場合は0を返す。
+func scoreStation(s Station, key string) int {
+	score := 0
+	raise := func(n int) {
+		if n > score {
+			score = n
+		}
+	}
+	names := []string{normalizeStationKey(s.Name), normalizeStationKey(s.Kana), normalizeStationKey(s.Romaji)}
+	for _, name := range names {
+		switch {
+		case len(name) == 0:
+		case name == key:
+			raise(stationScoreExact)
+		case strings.HasPrefix(name, key):
+			raise(stationScorePrefix)
+		case strings.Contains(name, key):
+			raise(stationScoreContains)
+		case len([]rune(key)) >= 3 && isSubsequence(key, name):
+			raise(stationScoreSubsequence)
+		}
+	}
+
+	if municipality := normalizeStationKey(s.ShichosonName); len(municipality) != 0 {
+		if strings.Contains(municipality, key) || strings.Contains(key, municipality) {
+			raise(stationScoreMunicipality)
+		}
+	}
+
+	// ローマ字の綴りの誤りは単語ごとに照合する
+	if len([]rune(key)) >= 4 {
+		for _, word := range strings.Fields(s.Romaji) {
+			if levenshtein(key, normalizeStationKey(word)) <= 1 {
+				raise(stationScoreTypo)
+			}
+		}
+	}
+
+	return score
+}
+
+// ローマ字の表記の揺れをそろえる置換。ヘボン式を訓令式にそろえてから長音の表記の違いを吸収する。
+var (
+	romajiConsonantReplacer = strings.NewReplacer(
+		"shi", "si", "sha", "sya", "shu", "syu", "sho", "syo",
+		"chi", "ti", "cha", "tya", "chu", "tyu", "cho", "tyo",
+		"tsu", "tu", "fu", "hu",
+		"jya", "zya", "jyu", "zyu", "jyo", "zyo",
+		"ji", "zi", "ja", "zya", "ju", "zyu", "jo", "zyo",
+	)
+	romajiVowelReplacer = strings.NewReplacer("ou", "o", "oo", "o", "uu", "u")
+)
+
+// normalizeStationKey は測定局の検索のために文字列を正規化する。
+// 全角英数を半角に、半角カタカナを全角にしてからカタカナをひらがなにし、大文字を小文字にして空白と記号を除く。ローマ字は表記の揺れをそろえる。
+func normalizeStationKey(s string) string {
+	s = strings.ToLower(norm.NFKC.String(s))
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '-' || r == '・' || r == '_' {
+			return -1
+		}
+		return katakanaToHiragana(r)
+	}, s)
+
+	if isASCII(s) {
+		s = romajiVowelReplacer.Replace(romajiConsonantReplacer.Replace(s))
+	}
+
+	return s
+}
+
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isSubsequence は key の文字が s に順に含まれるかどうかを返す。
+func isSubsequence(key, s string) bool {
+	rs := []rune(s)
+	i := 0
+	for _, r := range key {
+		for i < len(rs) && rs[i] != r {
+			i++
+		}
+		if i == len(rs) {
+			return false
+		}
+		i++
+	}
+
+	return true
+}
+
+// levenshtein は2つの文字列の編集距離を返す。
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			// 削除、挿入、置換のうち最小の編集距離
+			curr[j] = prev[j] + 1
+			if n := curr[j-1] + 1; n < curr[j] {
+				curr[j] = n
+			}
+			if n := prev[j-1] + cost; n < curr[j] {
+				curr[j] = n
+			}
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,256 @@
+package kafun
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func stationSearchFixture(t *testing.T) *StationCatalog {
+	t.Helper()
+	return NewStationCatalog([]Station{
+		{
+			Code:          "51320100",
+			Name:          "新宿区役所第二分庁舎",
+			Kana:          "しんじゅくくやくしょだいにぶんちょうしゃ",
+			Romaji:        "Shinjuku Kuyakusho Daini Bunchosha",
+			TodofukenCode: "13",
+			ShichosonName: "新宿区",
+		},
+		{
+			Code:          "00000002",
+			Name:          "新宿御苑",
+			Kana:          "しんじゅくぎょえん",
+			Romaji:        "Shinjuku Gyoen",
+			TodofukenCode: "13",
+			ShichosonName: "新宿区",
+		},
+		{
+			Code:          "00000003",
+			Name:          "千代田",
+			Kana:          "ちよだ",
+			Romaji:        "Chiyoda",
+			TodofukenCode: "13",
+			ShichosonName: "千代田区",
+		},
+		{
+			Code:          "00000004",
+			Name:          "横浜",
+			Kana:          "よこはま",
+			Romaji:        "Yokohama",
+			TodofukenCode: "14",
+			ShichosonName: "横浜市",
+		},
+	})
+}
+
+func Test_normalizeStationKey(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "新宿 御苑", want: "新宿御苑"},
+		{input: "シンジュク", want: "しんじゅく"},
+		{input: "ｼﾝｼﾞｭｸ", want: "しんじゅく"},
+		{input: "Shinjuku", want: "sinzyuku"},
+		{input: "shinjyuku", want: "sinzyuku"},
+		{input: "Ｃｈｉｙｏｄａ", want: "tiyoda"},
+		{input: "Tokyo", want: "tokyo"},
+		{input: "toukyou", want: "tokyo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := normalizeStationKey(tt.input); got != tt.want {
+				t.Errorf("normalizeStationKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStationCatalog_Search(t *testing.T) {
+	catalog := stationSearchFixture(t)
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "新宿", want: []string{"00000002", "51320100"}},
+		{query: "新宿御苑", want: []string{"00000002"}},
+		{query: "しんじゅくぎょえん", want: []string{"00000002"}},
+		{query: "シンジュク", want: []string{"00000002", "51320100"}},
+		{query: "gyoen", want: []string{"00000002"}},
+		{query: "chiyouda", want: []string{"00000003"}},
+		{query: "yokohma", want: []string{"00000004"}},
+		{query: "神奈川", want: []string{"00000004"}},
+		{query: "大阪", want: nil},
+		{query: "", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var got []string
+			for _, m := range catalog.Search(tt.query) {
+				got = append(got, m.Station.Code)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStationCatalog_Resolve(t *testing.T) {
+	catalog := stationSearchFixture(t)
+	tests := []struct {
+		query          string
+		want           string
+		wantCandidates []string
+		wantNotFound   bool
+	}{
+		{query: "新宿御苑", want: "00000002"},
+		{query: "chiyoda", want: "00000003"},
+		{query: "よこはま", want: "00000004"},
+		{query: "新宿", wantCandidates: []string{"00000002", "51320100"}},
+		{query: "札幌", wantNotFound: true},
+		// 都道府県だけ、綴りの誤りだけの一致は1局でも解決しない
+		{query: "kanagawa", wantCandidates: []string{"00000004"}},
+		{query: "yokohma", wantCandidates: []string{"00000004"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := catalog.Resolve(tt.query)
+
+			var ambiguousErr *AmbiguousStationError
+			var notFoundErr *StationNotFoundError
+			switch {
+			case tt.wantCandidates != nil:
+				if !errors.As(err, &ambiguousErr) {
+					t.Fatalf("Resolve() error = %v, want *AmbiguousStationError", err)
+				}
+				var candidates []string
+				for _, s := range ambiguousErr.Candidates {
+					candidates = append(candidates, s.Code)
+				}
+				if !reflect.DeepEqual(candidates, tt.wantCandidates) {
+					t.Errorf("Resolve() candidates = %v, want %v", candidates, tt.wantCandidates)
+				}
+			case tt.wantNotFound:
+				if !errors.As(err, &notFoundErr) {
+					t.Fatalf("Resolve() error = %v, want *StationNotFoundError", err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("Resolve() error = %v", err)
+				}
+				if got.Code != tt.want {
+					t.Errorf("Resolve() = %v, want %v", got.Code, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// sameNameFixture は同じ名前の測定局が別の都道府県にある測定局の一覧を返す。
+func sameNameFixture(t *testing.T) *StationCatalog {
+	t.Helper()
+	return NewStationCatalog([]Station{
+		{Code: "00001301", Name: "府中", Kana: "ふちゅう", Romaji: "Fuchu", TodofukenCode: "13", ShichosonName: "府中市"},
+		{Code: "00003401", Name: "府中", Kana: "ふちゅう", Romaji: "Fuchu", TodofukenCode: "34", ShichosonName: "府中市"},
+		{Code: "00001401", Name: "横浜", Kana: "よこはま", Romaji: "Yokohama", TodofukenCode: "14", ShichosonName: "横浜市"},
+		{Code: "00002801", Name: "神戸", Kana: "こうべ", Romaji: "Kobe", TodofukenCode: "28", ShichosonName: "神戸市"},
+	})
+}
+
+func TestStationCatalog_Resolve_sameName(t *testing.T) {
+	catalog := sameNameFixture(t)
+	tests := []struct {
+		name           string
+		todofukenCode  string
+		query          string
+		want           string
+		wantCandidates []string
+	}{
+		{name: "name in two prefectures", query: "府中", wantCandidates: []string{"00001301", "00003401"}},
+		{name: "kana in two prefectures", query: "ふちゅう", wantCandidates: []string{"00001301", "00003401"}},
+		{name: "typo in two prefectures", query: "fucho", wantCandidates: []string{"00001301", "00003401"}},
+		{name: "narrowed by prefecture", todofukenCode: "34", query: "府中", want: "00003401"},
+		{name: "romaji narrowed by prefecture", todofukenCode: "13", query: "fuchu", want: "00001301"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := catalog
+			if len(tt.todofukenCode) != 0 {
+				c = NewStationCatalog(catalog.ByPrefecture(tt.todofukenCode))
+			}
+			got, err := c.Resolve(tt.query)
+
+			if tt.wantCandidates != nil {
+				var ambiguousErr *AmbiguousStationError
+				if !errors.As(err, &ambiguousErr) {
+					t.Fatalf("Resolve() error = %v, want *AmbiguousStationError", err)
+				}
+				var candidates []string
+				for _, s := range ambiguousErr.Candidates {
+					candidates = append(candidates, s.Code)
+				}
+				if !reflect.DeepEqual(candidates, tt.wantCandidates) {
+					t.Errorf("Resolve() candidates = %v, want %v", candidates, tt.wantCandidates)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if got.Code != tt.want {
+				t.Errorf("Resolve() = %v, want %v", got.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestStationCatalog_Search_typoDistance(t *testing.T) {
+	catalog := sameNameFixture(t)
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  string
+		wantScore int
+	}{
+		{name: "deletion", query: "yokohma", wantCode: "00001401", wantScore: stationScoreTypo},
+		{name: "insertion", query: "yokohamma", wantCode: "00001401", wantScore: stationScoreTypo},
+		{name: "substitution", query: "kobi", wantCode: "00002801", wantScore: stationScoreTypo},
+		{name: "two edits", query: "yokahoma"},
+		{name: "long vowel is not a typo", query: "koube", wantCode: "00002801", wantScore: stationScoreExact},
+		{name: "short query is not checked for typos", query: "kbe", wantCode: "00002801", wantScore: stationScoreSubsequence},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := catalog.Search(tt.query)
+			if len(tt.wantCode) == 0 {
+				if len(matches) != 0 {
+					t.Errorf("Search(%q) = %v, want no match", tt.query, matches)
+				}
+				return
+			}
+			if len(matches) != 1 || matches[0].Station.Code != tt.wantCode || matches[0].Score != tt.wantScore {
+				t.Errorf("Search(%q) = %v, want %s with score %d", tt.query, matches, tt.wantCode, tt.wantScore)
+			}
+		})
+	}
+}
+
+func Test_levenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "abc", b: "", want: 3},
+		{a: "yokohama", b: "yokohma", want: 1},
+		{a: "kitten", b: "sitting", want: 3},
+		{a: "新宿", b: "新宿区", want: 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package kafun
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDefaultStationCatalog(t *testing.T) {
+	catalog := DefaultStationCatalog()
+	if catalog.Len() == 0 {
+		t.Fatalf("DefaultStationCatalog() is empty")
+	}
+
+	got, ok := catalog.Lookup("51320100")
+	if !ok {
+		t.Fatalf("Lookup(51320100) not found")
+	}
+	want := Station{
+		Code:          "51320100",
+		Name:          "新宿区役所第二分庁舎",
+		Kana:          "しんじゅくくやくしょだいにぶんちょうしゃ",
+		Romaji:        "Shinjuku Kuyakusho Daini Bunchosha",
+		TodofukenCode: "13",
+		TodofukenName: "東京都",
+		ShichosonCode: "13104",
+		ShichosonName: "新宿区",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lookup(51320100) = %+v, want %+v", got, want)
+	}
+
+	for _, s := range catalog.Stations() {
+		if _, ok := LookupPrefecture(s.TodofukenCode); !ok {
+			t.Errorf("station %s has unknown TDFKN_CD: %s", s.Code, s.TodofukenCode)
+		}
+	}
+}
+
+func TestLoadStationCatalog(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "standard case: sorted by code and deduplicated",
+			input: `[{"SKT_CD": "2", "SKT_NM": "B"}, {"SKT_CD": "1", "SKT_NM": "A"}, {"SKT_CD": "2", "SKT_NM": "C"}]`,
+			want:  []string{"1:A", "2:C"},
+		},
+		{
+			name:  "standard case: empty",
+			input: `[]`,
+		},
+		{
+			name:    "error case: not array",
+			input:   `{"SKT_CD": "1"}`,
+			wantErr: true,
+		},
+		{
+			name:    "error case: lacks code",
+			input:   `[{"SKT_NM": "A"}]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			catalog, err := LoadStationCatalog(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadStationCatalog() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var got []string
+			for _, s := range catalog.Stations() {
+				got = append(got, s.Code+":"+s.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadStationCatalog() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStationsFromSokuteiData(t *testing.T) {
+	data := SokuteiData{
+		{SokuteikyokuCode: "00000002", SokuteikyokuName: "旧名", TodofukenCode: "13"},
+		{SokuteikyokuCode: "00000001", SokuteikyokuName: "テスト観測所1", TodofukenCode: "13", AMeDASCode: "44132"},
+		{SokuteikyokuCode: "00000002", SokuteikyokuName: "新名", TodofukenCode: "13"},
+		{SokuteikyokuName: "コードなし"},
+	}
+
+	want := []Station{
+		{Code: "00000002", Name: "新名", TodofukenCode: "13"},
+		{Code: "00000001", Name: "テスト観測所1", TodofukenCode: "13", AMeDASCode: "44132"},
+	}
+	if got := StationsFromSokuteiData(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("StationsFromSokuteiData() = %+v, want %+v", got, want)
+	}
+}
+
+func TestStationCatalog_Merge(t *testing.T) {
+	latitude, longitude := 35.69, 139.70
+	catalog := NewStationCatalog([]Station{
+		{Code: "1", Name: "A", TodofukenCode: "13", Latitude: &latitude, Longitude: &longitude},
+		{Code: "3", Name: "C", TodofukenCode: "14"},
+	})
+
+	merged := catalog.Merge([]Station{
+		{Code: "1", Name: "A2", TodofukenCode: "13", AMeDASCode: "44132"},
+		{Code: "2", Name: "B", TodofukenCode: "13"},
+	})
+
+	want := []Station{
+		{Code: "1", Name: "A2", TodofukenCode: "13", AMeDASCode: "44132", Latitude: &latitude, Longitude: &longitude},
+		{Code: "2", Name: "B", TodofukenCode: "13"},
+		{Code: "3", Name: "C", TodofukenCode: "14"},
+	}
+	if got := merged.Stations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge() = %+v, want %+v", got, want)
+	}
+	if got, _ := catalog.Lookup("1"); got.Name != "A" {
+		t.Errorf("Merge() modified receiver: %+v", got)
+	}
+
+	if got := merged.ByPrefecture("13"); len(got) != 2 || got[0].Code != "1" || got[1].Code != "2" {
+		t.Errorf("ByPrefecture() = %+v, want stations 1 and 2", got)
+	}
+}
+
+func TestStationCatalog_WriteJSON(t *testing.T) {
+	latitude := 35.69
+	catalog := NewStationCatalog([]Station{{Code: "1", Name: "A", TodofukenCode: "13", Latitude: &latitude}})
+
+	var buf bytes.Buffer
+	if err := catalog.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	loaded, err := LoadStationCatalog(&buf)
+	if err != nil {
+		t.Fatalf("LoadStationCatalog() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Stations(), catalog.Stations()) {
+		t.Errorf("WriteJSON() round trip = %+v, want %+v", loaded.Stations(), catalog.Stations())
+	}
+}
@@ -0,0 +1,250 @@
+package kafun
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// StatsGroup は集計する単位の種類。
+type StatsGroup string
+
+// 集計する単位。
+const (
+	GroupStation    StatsGroup = "station"    // 測定局
+	GroupPrefecture StatsGroup = "prefecture" // 都道府県
+)
+
+// StatsPeriod は集計する期間の種類。
+type StatsPeriod string
+
+// 集計する期間。期間は測定年月日で区切り、24時の測定データは測定年月日の期間に含める。
+const (
+	PeriodDay    StatsPeriod = "day"    // 測定年月日
+	PeriodWeek   StatsPeriod = "week"   // 月曜日から日曜日までの週
+	PeriodMonth  StatsPeriod = "month"  // 月
+	PeriodSeason StatsPeriod = "season" // 春(3〜5月)、夏(6〜8月)、秋(9〜11月)、冬(12〜2月)
+)
+
+// 季節の名前。添字は3月から数えた季節の順。
+var seasonNames = [...]string{"spring", "summer", "autumn", "winter"}
+
+// FieldStats は期間の1時間の値の集計を表す。
+type FieldStats struct {
+	Sum      float64   // 値の合計
+	Hours    int       // 値のある時間数
+	Max      float64   // 1時間の値の最大値
+	MaxTime  time.Time // 値が最大になった測定日時 (Time と同じ表し方)。同じ値の場合は早い時刻
+	Expected int       // 期間の時間数に測定局の数を掛けた、値があるべき時間数
+}
+
+// Mean は1時間の値の平均値を返す。値のある時間がない場合は false を返す。
+func (f *FieldStats) Mean() (float64, bool) {
+	if f.Hours == 0 {
+		return 0, false
+	}
+
+	return f.Sum / float64(f.Hours), true
+}
+
+// Coverage は値があるべき時間のうち値のある時間の割合(0〜1)を返す。値があるべき時間がない場合は0を返す。
+func (f *FieldStats) Coverage() float64 {
+	if f.Expected == 0 {
+		return 0
+	}
+
+	return float64(f.Hours) / float64(f.Expected)
+}
+
+func (f *FieldStats) add(v float64, t time.Time) {
+	if f.Hours == 0 || v > f.Max || (v == f.Max && t.Before(f.MaxTime)) {
+		f.Max = v
+		f.MaxTime = t
+	}
+	f.Sum += v
+	f.Hours++
+}
+
+// Stats は測定局ないしは都道府県ごとの期間の集計を表す。
+type Stats struct {
+	Group  StatsGroup  // 集計した単位
+	Period StatsPeriod // 集計した期間の種類
+
+	Code          string    // 測定局コードないしは都道府県コード
+	Name          string    // 測定局名ないしは都道府県名
+	TodofukenCode string    // 都道府県コード(JIS)
+	Start         time.Time // 期間の最初の日の0時(日本標準時)
+	End           time.Time // 期間の次の日の0時(日本標準時)
+	Stations      int       // 集計した測定局の数
+
+	KafunNum      FieldStats // 花粉数
+	Temperature   FieldStats // 気温
+	Precipitation FieldStats // 降水量
+}
+
+// Label は期間を 2021-02-01 (日)、2021-W05 (週)、2021-02 (月)、2021-spring (季節) の形式で返す。
+// 冬は12月の年で表す。
+func (s *Stats) Label() string {
+	switch s.Period {
+	case PeriodWeek:
+		year, week := s.Start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case PeriodMonth:
+		return s.Start.Format("2006-01")
+	case PeriodSeason:
+		return fmt.Sprintf("%d-%s", s.Start.Year(), seasonNames[(int(s.Start.Month())-3+12)%12/3])
+	default:
+		return formatDate(s.Start)
+	}
+}
+
+// Aggregate は測定データを測定局ないしは都道府県と期間ごとに集計し、コード、期間の順で返す。
+//
+// 花粉数、気温、降水量の合計、平均値、最大値と最大になった測定日時、値のある時間の割合を集計する。
+// 値があるべき時間数は期間の時間数に期間の測定データがある測定局の数を掛けたもので、現在より後の時間は含めない。
+// 測定日時を解釈できない測定データと、Fill で補った測定データは集計しない。花粉数が欠測ないしは不正な値で
+// 記録された測定データは、花粉数だけを集計しない。同じ測定局の同じ測定時刻の測定データは後のものを使う。
+func (sd SokuteiData) Aggregate(group StatsGroup, period StatsPeriod) ([]*Stats, error) {
+	return sd.aggregate(group, period, time.Now())
+}
+
+func (sd SokuteiData) aggregate(group StatsGroup, period StatsPeriod, now time.Time) ([]*Stats, error) {
+	switch group {
+	case GroupStation, GroupPrefecture:
+	default:
+		return nil, xerrors.Errorf("unknown stats group: %s", group)
+	}
+	switch period {
+	case PeriodDay, PeriodWeek, PeriodMonth, PeriodSeason:
+	default:
+		return nil, xerrors.Errorf("unknown stats period: %s", period)
+	}
+
+	// 同じ測定局の同じ測定時刻の測定データは後のものを使う
+	type slotKey struct {
+		code string
+		date string
+		hour int
+	}
+	slots := make(map[slotKey]*HourlySokuteiData)
+	var order []slotKey
+	for _, hsd := range sd {
+		if _, err := hsd.Time(); err != nil || hsd.Filled {
+			continue
+		}
+		hour, _ := strconv.Atoi(hsd.SokuteiJikoku)
+		if hour == 0 {
+			// 0時は前日の24時と同じ測定時刻だが、Daily と同じく測定年月日の日に含める
+			hour = hoursPerDay
+		}
+		key := slotKey{code: hsd.SokuteikyokuCode, date: hsd.SokuteiNengappi, hour: hour}
+		if _, ok := slots[key]; !ok {
+			order = append(order, key)
+		}
+		slots[key] = hsd
+	}
+
+	type statsKey struct {
+		code  string
+		start time.Time
+	}
+	groups := make(map[statsKey]*Stats)
+	stations := make(map[statsKey]map[string]bool)
+	for _, key := range order {
+		hsd := slots[key]
+		t, _ := hsd.Time()
+		date, _ := time.ParseInLocation(sokuteiNengappiLayout, hsd.SokuteiNengappi, jst)
+		start, end := statsPeriod(period, date)
+
+		code, name := hsd.SokuteikyokuCode, hsd.SokuteikyokuName
+		if group == GroupPrefecture {
+			code, name = hsd.TodofukenCode, hsd.TodofukenName
+			if p, ok := LookupPrefecture(code); ok {
+				name = p.Name
+			}
+		}
+
+		k := statsKey{code: code, start: start}
+		s, ok := groups[k]
+		if !ok {
+			s = &Stats{Group: group, Period: period, Code: code, Start: start, End: end}
+			groups[k] = s
+			stations[k] = make(map[string]bool)
+		}
+		if len(name) != 0 {
+			s.Name = name
+		}
+		if len(hsd.TodofukenCode) != 0 {
+			s.TodofukenCode = hsd.TodofukenCode
+		}
+		stations[k][hsd.SokuteikyokuCode] = true
+
+		if !hsd.hasProblem("KFN_NUM") {
+			s.KafunNum.add(float64(hsd.KafunNum), t)
+		}
+		if hsd.AMeDASTemperature != nil {
+			s.Temperature.add(*hsd.AMeDASTemperature, t)
+		}
+		if hsd.AMeDASPrecipitation != nil {
+			s.Precipitation.add(float64(*hsd.AMeDASPrecipitation), t)
+		}
+	}
+
+	result := make([]*Stats, 0, len(groups))
+	for k, s := range groups {
+		s.Stations = len(stations[k])
+		expected := statsExpectedHours(s.Start, s.End, now) * s.Stations
+		s.KafunNum.Expected = expected
+		s.Temperature.Expected = expected
+		s.Precipitation.Expected = expected
+		result = append(result, s)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Code != result[j].Code {
+			return result[i].Code < result[j].Code
+		}
+		return result[i].Start.Before(result[j].Start)
+	})
+
+	return result, nil
+}
+
+// statsPeriod は date を含む期間の最初の日の0時と次の期間の最初の日の0時を返す。
+func statsPeriod(period StatsPeriod, date time.Time) (time.Time, time.Time) {
+	switch period {
+	case PeriodWeek:
+		// 月曜日を週の最初の日にする
+		start := date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7)
+	case PeriodMonth:
+		start := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, jst)
+		return start, start.AddDate(0, 1, 0)
+	case PeriodSeason:
+		// 1、2月は前年の12月から始まる冬にする
+		month := (int(date.Month())-3+12)%12/3*3 + 3
+		year := date.Year()
+		if date.Month() < time.March {
+			year--
+		}
+		start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, jst)
+		return start, start.AddDate(0, 3, 0)
+	default:
+		return date, date.AddDate(0, 0, 1)
+	}
+}
+
+// statsExpectedHours は期間の1測定局あたりの時間数を返す。現在より後の時間は含めない。
+func statsExpectedHours(start, end, now time.Time) int {
+	if now.Before(end) {
+		end = now.Truncate(time.Hour)
+	}
+	if !end.After(start) {
+		return 0
+	}
+
+	return int(end.Sub(start) / time.Hour)
+}
@@ -0,0 +1,138 @@
+package kafun
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_statsPeriod(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, jst)
+	}
+	tests := []struct {
+		period    StatsPeriod
+		date      time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+		wantLabel string
+	}{
+		{period: PeriodDay, date: day(2021, 2, 3), wantStart: day(2021, 2, 3), wantEnd: day(2021, 2, 4), wantLabel: "2021-02-03"},
+		{period: PeriodWeek, date: day(2021, 2, 3), wantStart: day(2021, 2, 1), wantEnd: day(2021, 2, 8), wantLabel: "2021-W05"},
+		{period: PeriodWeek, date: day(2021, 2, 7), wantStart: day(2021, 2, 1), wantEnd: day(2021, 2, 8), wantLabel: "2021-W05"},
+		{period: PeriodWeek, date: day(2021, 1, 2), wantStart: day(2020, 12, 28), wantEnd: day(2021, 1, 4), wantLabel: "2020-W53"},
+		{period: PeriodMonth, date: day(2021, 2, 28), wantStart: day(2021, 2, 1), wantEnd: day(2021, 3, 1), wantLabel: "2021-02"},
+		{period: PeriodSeason, date: day(2021, 3, 1), wantStart: day(2021, 3, 1), wantEnd: day(2021, 6, 1), wantLabel: "2021-spring"},
+		{period: PeriodSeason, date: day(2021, 11, 30), wantStart: day(2021, 9, 1), wantEnd: day(2021, 12, 1), wantLabel: "2021-autumn"},
+		{period: PeriodSeason, date: day(2021, 2, 10), wantStart: day(2020, 12, 1), wantEnd: day(2021, 3, 1), wantLabel: "2020-winter"},
+		{period: PeriodSeason, date: day(2021, 12, 10), wantStart: day(2021, 12, 1), wantEnd: day(2022, 3, 1), wantLabel: "2021-winter"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.period)+" "+formatDate(tt.date), func(t *testing.T) {
+			start, end := statsPeriod(tt.period, tt.date)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("statsPeriod() = %v, %v, want %v, %v", start, end, tt.wantStart, tt.wantEnd)
+			}
+			s := &Stats{Period: tt.period, Start: start}
+			if got := s.Label(); got != tt.wantLabel {
+				t.Errorf("Label() = %v, want %v", got, tt.wantLabel)
+			}
+		})
+	}
+}
+
+func TestSokuteiData_Aggregate(t *testing.T) {
+	temperature := func(f float64) *float64 { return &f }
+	precipitation := func(i int) *int { return &i }
+	data := SokuteiData{
+		{SokuteikyokuCode: "1", SokuteikyokuName: "A", TodofukenCode: "13", SokuteiNengappi: "20210201", SokuteiJikoku: "01",
+			KafunNum: 10, AMeDASTemperature: temperature(5.5), AMeDASPrecipitation: precipitation(1)},
+		{SokuteikyokuCode: "1", SokuteikyokuName: "A", TodofukenCode: "13", SokuteiNengappi: "20210201", SokuteiJikoku: "02",
+			KafunNum: 40, AMeDASTemperature: temperature(6.5)},
+		// 同じ測定時刻は後の測定データを使う
+		{SokuteikyokuCode: "1", SokuteikyokuName: "A", TodofukenCode: "13", SokuteiNengappi: "20210201", SokuteiJikoku: "01",
+			KafunNum: 20, AMeDASTemperature: temperature(4.5), AMeDASPrecipitation: precipitation(1)},
+		{SokuteikyokuCode: "1", TodofukenCode: "13", SokuteiNengappi: "20210201", SokuteiJikoku: "03",
+			Problems: []FieldProblem{{Field: "KFN_NUM", Value: "-", Message: missingValueMessage}}, AMeDASTemperature: temperature(3)},
+		{SokuteikyokuCode: "1", TodofukenCode: "13", SokuteiNengappi: "20210201", SokuteiJikoku: "04", KafunNum: 99, Filled: true},
+		{SokuteikyokuCode: "1", TodofukenCode: "13", SokuteiNengappi: "invalid", SokuteiJikoku: "01", KafunNum: 99},
+		{SokuteikyokuCode: "1", SokuteikyokuName: "A", TodofukenCode: "13", SokuteiNengappi: "20210208", SokuteiJikoku: "24", KafunNum: 4},
+		{SokuteikyokuCode: "2", SokuteikyokuName: "B", TodofukenCode: "13", SokuteiNengappi: "20210202", SokuteiJikoku: "01", KafunNum: 40},
+	}
+	now := time.Date(2021, 3, 1, 0, 0, 0, 0, jst)
+
+	t.Run("station by week", func(t *testing.T) {
+		got, err := data.aggregate(GroupStation, PeriodWeek, now)
+		if err != nil {
+			t.Fatalf("aggregate() error = %v", err)
+		}
+		if len(got) != 3 {
+			t.Fatalf("aggregate() got %d stats, want 3", len(got))
+		}
+
+		s := got[0]
+		if s.Code != "1" || s.Name != "A" || s.TodofukenCode != "13" || s.Label() != "2021-W05" || s.Stations != 1 {
+			t.Errorf("aggregate()[0] = %+v", s)
+		}
+		wantMaxTime := time.Date(2021, 2, 1, 2, 0, 0, 0, jst)
+		if s.KafunNum.Sum != 60 || s.KafunNum.Hours != 2 || s.KafunNum.Max != 40 || !s.KafunNum.MaxTime.Equal(wantMaxTime) {
+			t.Errorf("aggregate()[0].KafunNum = %+v", s.KafunNum)
+		}
+		if mean, ok := s.KafunNum.Mean(); !ok || mean != 30 {
+			t.Errorf("KafunNum.Mean() = %v, %v, want 30, true", mean, ok)
+		}
+		if s.KafunNum.Expected != 7*24 || s.KafunNum.Coverage() != 2.0/(7*24) {
+			t.Errorf("KafunNum expected = %v, coverage = %v", s.KafunNum.Expected, s.KafunNum.Coverage())
+		}
+		if s.Temperature.Hours != 3 || s.Temperature.Max != 6.5 || s.Temperature.Sum != 14 {
+			t.Errorf("aggregate()[0].Temperature = %+v", s.Temperature)
+		}
+		if s.Precipitation.Hours != 1 || s.Precipitation.Sum != 1 {
+			t.Errorf("aggregate()[0].Precipitation = %+v", s.Precipitation)
+		}
+
+		// 24時の測定データは測定年月日の週に含める
+		if got[1].Code != "1" || got[1].Label() != "2021-W06" || got[1].KafunNum.Sum != 4 {
+			t.Errorf("aggregate()[1] = %+v", got[1])
+		}
+		if got[2].Code != "2" || got[2].Label() != "2021-W05" {
+			t.Errorf("aggregate()[2] = %+v", got[2])
+		}
+	})
+
+	t.Run("prefecture by month", func(t *testing.T) {
+		got, err := data.aggregate(GroupPrefecture, PeriodMonth, time.Date(2021, 2, 10, 12, 30, 0, 0, jst))
+		if err != nil {
+			t.Fatalf("aggregate() error = %v", err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("aggregate() got %d stats, want 1", len(got))
+		}
+
+		s := got[0]
+		if s.Code != "13" || s.Name != "東京都" || s.Stations != 2 || s.KafunNum.Sum != 104 || s.KafunNum.Max != 40 {
+			t.Errorf("aggregate()[0] = %+v", s)
+		}
+		// 現在より後の時間は値があるべき時間に含めない
+		if want := (9*24 + 12) * 2; s.KafunNum.Expected != want {
+			t.Errorf("KafunNum.Expected = %v, want %v", s.KafunNum.Expected, want)
+		}
+	})
+
+	t.Run("empty field", func(t *testing.T) {
+		if _, ok := (&FieldStats{}).Mean(); ok {
+			t.Errorf("Mean() ok = true for no hours")
+		}
+		if coverage := (&FieldStats{}).Coverage(); coverage != 0 {
+			t.Errorf("Coverage() = %v, want 0", coverage)
+		}
+	})
+
+	t.Run("unknown group and period", func(t *testing.T) {
+		if _, err := data.Aggregate("city", PeriodDay); err == nil {
+			t.Errorf("Aggregate() error = nil for unknown group")
+		}
+		if _, err := data.Aggregate(GroupStation, "year"); err == nil {
+			t.Errorf("Aggregate() error = nil for unknown period")
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package kafun
+
+import (
+	"context"
+	"time"
+)
+
+// SearchIter は Search と同じ検索を行い、測定データを1件デコードするごとに fn に渡す。
+// レスポンス全体をメモリに保持しないので、大量のデータをそのまま保存先に流す用途に使う。
+//
+// 分割したリクエストは年月(と測定局)の順に1つずつ実行するので、fn には Search の
+// MergeOrderRequest と同じ順でデータが渡される。キャッシュ済みのデータは使うが、新たにキャッシュはしない。
+// fn がエラーを返した場合は残りの処理を中断してそのエラーを返す。
+func (c *Client) SearchIter(ctx context.Context, param *SearchParam, fn func(*HourlySokuteiData) error) error {
+	if err := validateSearchParam(param); err != nil {
+		return err
+	}
+
+	params, err := splitSearchParam(param, c.SplitByStation)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range params {
+		var fnErr error
+		err := c.searchIter(ctx, p, func(hsd *HourlySokuteiData) error {
+			fnErr = fn(hsd)
+			return fnErr
+		})
+		if fnErr != nil {
+			return fnErr
+		}
+		if err != nil {
+			return &SubSearchError{Param: p, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// searchIter は分割済みのパラメータで data_search API を1回コールし、レスポンスを逐次デコードする。
+func (c *Client) searchIter(ctx context.Context, param *SearchParam, fn func(*HourlySokuteiData) error) error {
+	req, err := c.newSearchRequest(ctx, param)
+	if err != nil {
+		return err
+	}
+
+	if c.Cache != nil && isCompletedPeriod(param, time.Now()) {
+		if response, ok := c.Cache.Get(req.URL.String()); ok {
+			c.logf("cache hit: %s", req.URL)
+			for _, hsd := range response {
+				if err := fn(hsd); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	res, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return decodeStream(res.Body, c.DecodeMode, fn)
+}
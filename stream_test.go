@@ -0,0 +1,131 @@
+package kafun
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClient_SearchIter(t *testing.T) {
+	errStop := errors.New("stop")
+	type args struct {
+		param   *SearchParam
+		stopAt  int
+		failYM  string
+		invalid bool
+	}
+	type want struct {
+		nengappi []string
+		requests int32
+		err      error
+		wantErr  bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			name: "standard case: stream months in order",
+			args: args{
+				param: &SearchParam{StartYM: "202102", EndYM: "202104", TodofukenCode: "13"},
+			},
+			want: want{
+				nengappi: []string{"20210201", "20210301", "20210401"},
+				requests: 3,
+			},
+		},
+		{
+			name: "standard case: callback error stops iteration",
+			args: args{
+				param:  &SearchParam{StartYM: "202102", EndYM: "202104", TodofukenCode: "13"},
+				stopAt: 2,
+			},
+			want: want{
+				nengappi: []string{"20210201", "20210301"},
+				requests: 2,
+				err:      errStop,
+				wantErr:  true,
+			},
+		},
+		{
+			name: "error case: api error in second month",
+			args: args{
+				param:  &SearchParam{StartYM: "202102", EndYM: "202104", TodofukenCode: "13"},
+				failYM: "202103",
+			},
+			want: want{
+				nengappi: []string{"20210201"},
+				requests: 2,
+				wantErr:  true,
+			},
+		},
+		{
+			name: "error case: invalid parameter",
+			args: args{
+				param: &SearchParam{},
+			},
+			want: want{
+				wantErr: true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			handler := monthlyHandler(t, tt.args.failYM)
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				handler(w, r)
+			}))
+			defer testServer.Close()
+			testServerURL, _ := url.Parse(testServer.URL)
+			c := &Client{
+				URL:         testServerURL,
+				HTTPClient:  http.DefaultClient,
+				Concurrency: DefaultConcurrency,
+			}
+
+			var nengappi []string
+			err := c.SearchIter(ctx, tt.args.param, func(hsd *HourlySokuteiData) error {
+				nengappi = append(nengappi, hsd.SokuteiNengappi)
+				if len(nengappi) == tt.args.stopAt {
+					return errStop
+				}
+				return nil
+			})
+			if (err != nil) != tt.want.wantErr {
+				t.Fatalf("SearchIter() error = %v, wantErr %v", err, tt.want.wantErr)
+			}
+			if tt.want.err != nil && err != tt.want.err {
+				t.Errorf("SearchIter() error = %v, want %v", err, tt.want.err)
+			}
+			if !reflect.DeepEqual(nengappi, tt.want.nengappi) {
+				t.Errorf("SearchIter() nengappi = %v, want %v", nengappi, tt.want.nengappi)
+			}
+			if got := atomic.LoadInt32(&requests); got != tt.want.requests {
+				t.Errorf("SearchIter() requests = %v, want %v", got, tt.want.requests)
+			}
+		})
+	}
+}
+
+func Test_decodeStream_decodeError(t *testing.T) {
+	body := bytes.Replace(sokuteiDataByteOmitOptional, []byte(`"KFN_NUM": "0"`), []byte(`"KFN_NUM": "x"`), 1)
+	err := decodeStream(decodeBodyResponseFixture(t, body).Body, DecodeStrict, func(*HourlySokuteiData) error {
+		return nil
+	})
+
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("decodeStream() error = %v, want *DecodeError", err)
+	}
+	if decodeErr.Index != 0 {
+		t.Errorf("decodeStream() error index = %v, want 0", decodeErr.Index)
+	}
+}
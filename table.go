@@ -0,0 +1,248 @@
+package kafun
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+)
+
+// table 形式の列の区切り。
+const tableSeparator = "  "
+
+// 切り詰めるときに最低限残す列の表示幅。
+const tableMinColumnWidth = 6
+
+// 切り詰めたことを表す文字。
+const tableEllipsis = "…"
+
+// tableColumn は table 形式で出力する列を表す。
+type tableColumn struct {
+	Title       string        // 見出し
+	column      *outputColumn // 値を取り出す出力列
+	alignRight  bool          // 右寄せにする
+	truncatable bool          // 表示幅が足りない場合に切り詰める
+}
+
+// defaultTableColumns は table 形式で出力する列。
+var defaultTableColumns = []*tableColumn{
+	{Title: "日付", column: lookupOutputColumn("SKT_NNGP")},
+	{Title: "時", column: lookupOutputColumn("SKT_HH"), alignRight: true},
+	{Title: "測定局", column: lookupOutputColumn("SKT_NM"), truncatable: true},
+	{Title: "花粉数", column: lookupOutputColumn("KFN_NUM"), alignRight: true},
+	{Title: "気温", column: lookupOutputColumn("AMeDAS_TP"), alignRight: true},
+	{Title: "風向", column: windDirectionJapaneseColumn},
+	{Title: "風速", column: lookupOutputColumn("AMeDAS_WS"), alignRight: true},
+}
+
+// windDirectionJapaneseColumn は風向の日本語の表記を表示する列。解釈できない風向コードはそのまま表示する。
+var windDirectionJapaneseColumn = &outputColumn{
+	Name:   "AMeDAS_WD",
+	GoName: "AMeDASWindDirect",
+	index:  -1,
+	compute: func(hsd *HourlySokuteiData) (interface{}, bool) {
+		wd, err := hsd.WindDirection()
+		if err != nil {
+			return hsd.AMeDASWindDirect, true
+		}
+		if wd.IsMissing() {
+			return nil, false
+		}
+		return wd.Japanese(), true
+	},
+}
+
+// 表示幅が足りない場合に切り詰める名称の列。
+var tableTruncatableColumns = map[string]bool{"SKT_NM": true, "TDFKN_NM": true, "SKCHSN_NM": true}
+
+// newTableColumns は出力する項目に応じた table 形式の列を返す。
+// 項目が指定されていない場合は defaultTableColumns を使い、指定されている場合や追加した列は列名を見出しにする。
+func newTableColumns(projection *outputProjection) []*tableColumn {
+	var columns []*tableColumn
+	selected := projection.selected()
+	if projection.columns == nil {
+		columns = append(columns, defaultTableColumns...)
+		selected = projection.extra
+	}
+
+	for _, col := range selected {
+		column := col
+		if col.tableValue != nil {
+			column = &outputColumn{Name: col.Name, GoName: col.GoName, index: -1, compute: col.tableValue}
+		}
+		columns = append(columns, &tableColumn{
+			Title:       col.label(projection.goNames),
+			column:      column,
+			alignRight:  col.numeric(),
+			truncatable: tableTruncatableColumns[col.Name],
+		})
+	}
+
+	return columns
+}
+
+// lookupOutputColumn は列名に対応する出力列を返す。
+func lookupOutputColumn(name string) *outputColumn {
+	for _, col := range sokuteiDataColumns {
+		if col.Name == name {
+			return col
+		}
+	}
+
+	panic(fmt.Sprintf("unknown output column: %s", name))
+}
+
+// tableRecordWriter は測定データを列をそろえた表で出力する。
+// 列の幅を決めるために、すべての測定データを受け取ってから Close で出力する。
+type tableRecordWriter struct {
+	w        io.Writer
+	columns  []*tableColumn
+	maxWidth int // 表の最大の表示幅。0以下の場合は切り詰めない
+	noHeader bool
+	rows     [][]string
+}
+
+func (tw *tableRecordWriter) Write(hsd *HourlySokuteiData) error {
+	row := make([]string, len(tw.columns))
+	for i, col := range tw.columns {
+		row[i] = col.column.value(hsd)
+	}
+	tw.rows = append(tw.rows, row)
+
+	return nil
+}
+
+func (tw *tableRecordWriter) Close() error {
+	return renderTable(tw.w, tw.columns, tw.rows, tw.noHeader, tw.maxWidth)
+}
+
+// renderTable は列の見出しと各行のセルを、表示幅で列をそろえて出力する。
+// maxWidth が0より大きい場合は、表示幅に収まるように fitTableWidths で列を切り詰める。
+func renderTable(w io.Writer, columns []*tableColumn, rows [][]string, noHeader bool, maxWidth int) error {
+	if !noHeader {
+		header := make([]string, len(columns))
+		for i, col := range columns {
+			header[i] = col.Title
+		}
+		rows = append([][]string{header}, rows...)
+	}
+
+	widths := make([]int, len(columns))
+	for _, row := range rows {
+		for i, cell := range row {
+			if w := displayWidth(cell); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	widths = fitTableWidths(columns, widths, maxWidth)
+
+	var b strings.Builder
+	for _, row := range rows {
+		b.Reset()
+		for i, colWidth := range widths {
+			if i > 0 {
+				b.WriteString(tableSeparator)
+			}
+			cell := truncateToWidth(row[i], colWidth)
+			padding := strings.Repeat(" ", colWidth-displayWidth(cell))
+			if columns[i].alignRight {
+				b.WriteString(padding + cell)
+			} else {
+				b.WriteString(cell + padding)
+			}
+		}
+		if _, err := fmt.Fprintln(w, strings.TrimRight(b.String(), " ")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fitTableWidths は表の表示幅が maxWidth に収まるように各列の幅を返す。
+// 切り詰められる列を tableMinColumnWidth まで狭め、それでも収まらない場合は右の列から省く。
+func fitTableWidths(columns []*tableColumn, widths []int, maxWidth int) []int {
+	total := func(widths []int) int {
+		sum := len(tableSeparator) * (len(widths) - 1)
+		for _, w := range widths {
+			sum += w
+		}
+		return sum
+	}
+
+	if maxWidth <= 0 || total(widths) <= maxWidth {
+		return widths
+	}
+
+	fitted := append([]int(nil), widths...)
+	for i, col := range columns {
+		excess := total(fitted) - maxWidth
+		if excess <= 0 {
+			break
+		}
+		if !col.truncatable || fitted[i] <= tableMinColumnWidth {
+			continue
+		}
+		fitted[i] -= excess
+		if fitted[i] < tableMinColumnWidth {
+			fitted[i] = tableMinColumnWidth
+		}
+	}
+
+	for len(fitted) > 1 && total(fitted) > maxWidth {
+		fitted = fitted[:len(fitted)-1]
+	}
+
+	return fitted
+}
+
+// displayWidth は端末での文字列の表示幅を返す。東アジアの全角文字と広い文字は2桁として数える。
+func displayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+
+	return w
+}
+
+func runeWidth(r rune) int {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// truncateToWidth は表示幅が w を超える文字列を切り詰め、末尾を tableEllipsis にする。
+// w が tableEllipsis の表示幅より小さい場合は tableEllipsis を付けずに w 以内に切り詰める。
+func truncateToWidth(s string, w int) string {
+	if displayWidth(s) <= w {
+		return s
+	}
+
+	ellipsis := tableEllipsis
+	if w < displayWidth(ellipsis) {
+		ellipsis = ""
+	}
+
+	limit := w - displayWidth(ellipsis)
+	var b strings.Builder
+	used := 0
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		rw := runeWidth(r)
+		if used+rw > limit {
+			break
+		}
+		b.WriteRune(r)
+		used += rw
+		s = s[size:]
+	}
+
+	return b.String() + ellipsis
+}
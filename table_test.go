@@ -0,0 +1,176 @@
+package kafun
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func Test_displayWidth(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want int
+	}{
+		{name: "ascii", s: "Shinjuku", want: 8},
+		{name: "kanji", s: "新宿区", want: 6},
+		{name: "halfwidth katakana", s: "ｼﾝｼﾞｭｸ", want: 6},
+		{name: "fullwidth digits", s: "１２", want: 4},
+		{name: "mixed", s: "A棟1", want: 4},
+		{name: "empty", s: "", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := displayWidth(tt.s); got != tt.want {
+				t.Errorf("displayWidth(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_truncateToWidth(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		w    int
+		want string
+	}{
+		{name: "fits", s: "新宿区", w: 6, want: "新宿区"},
+		{name: "kanji", s: "新宿区役所第二分庁舎", w: 6, want: "新宿…"},
+		{name: "does not split wide rune", s: "新宿区役所第二分庁舎", w: 7, want: "新宿区…"},
+		{name: "ascii", s: "Shinjuku", w: 6, want: "Shinj…"},
+		{name: "only ellipsis fits", s: "新宿区", w: 1, want: "…"},
+		{name: "narrower than ellipsis", s: "新宿区", w: 0, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateToWidth(tt.s, tt.w)
+			if got != tt.want {
+				t.Errorf("truncateToWidth() = %q, want %q", got, tt.want)
+			}
+			if displayWidth(got) > tt.w {
+				t.Errorf("truncateToWidth() width = %v, want <= %v", displayWidth(got), tt.w)
+			}
+		})
+	}
+}
+
+func Test_fitTableWidths(t *testing.T) {
+	columns := []*tableColumn{{Title: "a"}, {Title: "b", truncatable: true}, {Title: "c"}}
+	tests := []struct {
+		name     string
+		widths   []int
+		maxWidth int
+		want     []int
+	}{
+		{name: "no limit", widths: []int{8, 20, 4}, maxWidth: 0, want: []int{8, 20, 4}},
+		{name: "fits", widths: []int{8, 20, 4}, maxWidth: 36, want: []int{8, 20, 4}},
+		{name: "truncate flexible column", widths: []int{8, 20, 4}, maxWidth: 30, want: []int{8, 14, 4}},
+		{name: "drop right columns", widths: []int{8, 20, 4}, maxWidth: 16, want: []int{8, 6}},
+		{name: "keep first column", widths: []int{8, 20, 4}, maxWidth: 4, want: []int{8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fitTableWidths(columns, tt.widths, tt.maxWidth); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fitTableWidths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_tableRecordWriter_windLabel(t *testing.T) {
+	data := SokuteiData{
+		{SokuteiNengappi: "20210201", SokuteiJikoku: "01", SokuteikyokuName: "A", AMeDASWindDirect: "00"},
+		{SokuteiNengappi: "20210201", SokuteiJikoku: "02", SokuteikyokuName: "B", AMeDASWindDirect: "16"},
+		{SokuteiNengappi: "20210201", SokuteiJikoku: "03", SokuteikyokuName: "C"},
+		{SokuteiNengappi: "20210201", SokuteiJikoku: "04", SokuteikyokuName: "D", AMeDASWindDirect: "99"},
+	}
+	// 静穏は「静穏」、風向コードのない欠測は空、未知の風向コードはそのまま表示する
+	want := "日付      時  測定局  花粉数  気温  風向  風速\n" +
+		"20210201  01  A            0        静穏\n" +
+		"20210201  02  B            0        北\n" +
+		"20210201  03  C            0\n" +
+		"20210201  04  D            0        99\n"
+
+	var buf bytes.Buffer
+	rw, err := newRecordWriter(&buf, &outputOptions{format: FormatTable})
+	if err != nil {
+		t.Fatalf("newRecordWriter() error = %v", err)
+	}
+	for _, hsd := range data {
+		if err := rw.Write(hsd); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got := buf.String(); got != want {
+		t.Errorf("output = \n%s\nwant\n%s", got, want)
+	}
+}
+
+func Test_tableRecordWriter(t *testing.T) {
+	data := append(outputFixture(t), &HourlySokuteiData{
+		SokuteiNengappi:  "20210201",
+		SokuteiJikoku:    "02",
+		SokuteikyokuName: "Sample",
+		KafunNum:         1234,
+	})
+
+	tests := []struct {
+		name string
+		opts *outputOptions
+		want string
+	}{
+		{
+			name: "standard case: aligned by display width",
+			opts: &outputOptions{format: FormatTable},
+			want: "日付      時  測定局                花粉数  気温  風向    風速\n" +
+				"20210201  01  新宿区役所第二分庁舎      12   8.5  東南東     2\n" +
+				"20210201  02  Sample                  1234\n",
+		},
+		{
+			name: "standard case: truncated to width",
+			opts: &outputOptions{format: FormatTable, width: 42},
+			want: "日付      時  測定局  花粉数  気温  風向\n" +
+				"20210201  01  新宿…       12   8.5  東南東\n" +
+				"20210201  02  Sample    1234\n",
+		},
+		{
+			name: "standard case: wind column dropped when label does not fit",
+			opts: &outputOptions{format: FormatTable, width: 40},
+			want: "日付      時  測定局  花粉数  気温\n" +
+				"20210201  01  新宿…       12   8.5\n" +
+				"20210201  02  Sample    1234\n",
+		},
+		{
+			name: "standard case: without header",
+			opts: &outputOptions{format: FormatTable, noHeader: true},
+			want: "20210201  01  新宿区役所第二分庁舎    12  8.5  東南東  2\n" +
+				"20210201  02  Sample                1234\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			rw, err := newRecordWriter(&buf, tt.opts)
+			if err != nil {
+				t.Fatalf("newRecordWriter() error = %v", err)
+			}
+			for _, hsd := range data {
+				if err := rw.Write(hsd); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if err := rw.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("output = \n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package kafun
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/width"
+	"golang.org/x/xerrors"
+)
+
+// WindDirection はアメダスの風向を表す。
+// 値はアメダスの風向コードで、1(北北東)から時計回りに16(北)までの16方位と、0(静穏)を表す。
+type WindDirection int
+
+// 風向。
+const (
+	WindMissing WindDirection = -1 // 欠測
+	WindCalm    WindDirection = 0  // 静穏
+	WindNNE     WindDirection = 1  // 北北東
+	WindNE      WindDirection = 2  // 北東
+	WindENE     WindDirection = 3  // 東北東
+	WindE       WindDirection = 4  // 東
+	WindESE     WindDirection = 5  // 東南東
+	WindSE      WindDirection = 6  // 南東
+	WindSSE     WindDirection = 7  // 南南東
+	WindS       WindDirection = 8  // 南
+	WindSSW     WindDirection = 9  // 南南西
+	WindSW      WindDirection = 10 // 南西
+	WindWSW     WindDirection = 11 // 西南西
+	WindW       WindDirection = 12 // 西
+	WindWNW     WindDirection = 13 // 西北西
+	WindNW      WindDirection = 14 // 北西
+	WindNNW     WindDirection = 15 // 北北西
+	WindN       WindDirection = 16 // 北
+)
+
+// 風向の日本語と英語の表記。添字は風向コード。
+var (
+	windDirectionJapanese = [...]string{
+		"静穏", "北北東", "北東", "東北東", "東", "東南東", "南東", "南南東", "南",
+		"南南西", "南西", "西南西", "西", "西北西", "北西", "北北西", "北",
+	}
+	windDirectionEnglish = [...]string{
+		"CALM", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S",
+		"SSW", "SW", "WSW", "W", "WNW", "NW", "NNW", "N",
+	}
+)
+
+// ParseWindDirection はAPIの風向コード("05" など)を WindDirection に変換する。
+// 空文字列や欠測を表す値は WindMissing になる。風向コードの範囲外の値はエラーを返す。
+func ParseWindDirection(code string) (WindDirection, error) {
+	code = strings.TrimSpace(width.Fold.String(code))
+	if len(code) == 0 || missingCSVValues[code] {
+		return WindMissing, nil
+	}
+
+	n, err := strconv.Atoi(code)
+	if err != nil || n < int(WindCalm) || n > int(WindN) {
+		return WindMissing, xerrors.Errorf("invalid wind direction: %q", code)
+	}
+
+	return WindDirection(n), nil
+}
+
+// IsCalm は静穏かどうかを返す。
+func (wd WindDirection) IsCalm() bool {
+	return wd == WindCalm
+}
+
+// IsMissing は欠測かどうかを返す。風向コードの範囲外の値も欠測とみなす。
+func (wd WindDirection) IsMissing() bool {
+	return wd < WindCalm || wd > WindN
+}
+
+// Japanese は風向の日本語の表記(北北東 など)を返す。
+func (wd WindDirection) Japanese() string {
+	if wd.IsMissing() {
+		return "欠測"
+	}
+
+	return windDirectionJapanese[wd]
+}
+
+// English は風向の英語の略記(NNE など)を返す。静穏は CALM、欠測は MISSING になる。
+func (wd WindDirection) English() string {
+	if wd.IsMissing() {
+		return "MISSING"
+	}
+
+	return windDirectionEnglish[wd]
+}
+
+// String は日本語と英語の表記を「北北東 (NNE)」の形式で返す。
+func (wd WindDirection) String() string {
+	return wd.Japanese() + " (" + wd.English() + ")"
+}
+
+// Degrees は風が吹いてくる方位を北から時計回りの角度で返す。北は360度になる。
+// 静穏と欠測の場合は false を返す。
+func (wd WindDirection) Degrees() (float64, bool) {
+	if wd.IsCalm() || wd.IsMissing() {
+		return 0, false
+	}
+
+	return float64(wd) * 22.5, true
+}
+
+// WindDirection は風向コード AMeDASWindDirect を WindDirection に変換して返す。
+func (hsd *HourlySokuteiData) WindDirection() (WindDirection, error) {
+	return ParseWindDirection(hsd.AMeDASWindDirect)
+}
@@ -0,0 +1,64 @@
+package kafun
+
+import "testing"
+
+func TestParseWindDirection(t *testing.T) {
+	tests := []struct {
+		code    string
+		want    WindDirection
+		wantErr bool
+	}{
+		{code: "00", want: WindCalm},
+		{code: "01", want: WindNNE},
+		{code: "05", want: WindESE},
+		{code: "16", want: WindN},
+		{code: "８", want: WindS},
+		{code: "", want: WindMissing},
+		{code: "--", want: WindMissing},
+		{code: "17", want: WindMissing, wantErr: true},
+		{code: "NE", want: WindMissing, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			got, err := ParseWindDirection(tt.code)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWindDirection() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseWindDirection() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindDirection(t *testing.T) {
+	tests := []struct {
+		wd          WindDirection
+		wantString  string
+		wantDegrees float64
+		wantOK      bool
+	}{
+		{wd: WindNNE, wantString: "北北東 (NNE)", wantDegrees: 22.5, wantOK: true},
+		{wd: WindE, wantString: "東 (E)", wantDegrees: 90, wantOK: true},
+		{wd: WindSW, wantString: "南西 (SW)", wantDegrees: 225, wantOK: true},
+		{wd: WindN, wantString: "北 (N)", wantDegrees: 360, wantOK: true},
+		{wd: WindCalm, wantString: "静穏 (CALM)"},
+		{wd: WindMissing, wantString: "欠測 (MISSING)"},
+		{wd: WindDirection(99), wantString: "欠測 (MISSING)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantString, func(t *testing.T) {
+			if got := tt.wd.String(); got != tt.wantString {
+				t.Errorf("String() = %v, want %v", got, tt.wantString)
+			}
+			got, ok := tt.wd.Degrees()
+			if got != tt.wantDegrees || ok != tt.wantOK {
+				t.Errorf("Degrees() = %v, %v, want %v, %v", got, ok, tt.wantDegrees, tt.wantOK)
+			}
+		})
+	}
+
+	if !WindCalm.IsCalm() || WindCalm.IsMissing() || !WindMissing.IsMissing() || WindN.IsCalm() {
+		t.Errorf("IsCalm() or IsMissing() returns unexpected value")
+	}
+}